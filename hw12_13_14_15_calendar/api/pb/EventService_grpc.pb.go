@@ -19,10 +19,25 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	EventService_CreateEvent_FullMethodName = "/event.EventService/CreateEvent"
-	EventService_UpdateEvent_FullMethodName = "/event.EventService/UpdateEvent"
-	EventService_DeleteEvent_FullMethodName = "/event.EventService/DeleteEvent"
-	EventService_ListEvents_FullMethodName  = "/event.EventService/ListEvents"
+	EventService_CreateEvent_FullMethodName     = "/event.EventService/CreateEvent"
+	EventService_GetEvent_FullMethodName        = "/event.EventService/GetEvent"
+	EventService_UpdateEvent_FullMethodName     = "/event.EventService/UpdateEvent"
+	EventService_DeleteEvent_FullMethodName     = "/event.EventService/DeleteEvent"
+	EventService_ListEvents_FullMethodName      = "/event.EventService/ListEvents"
+	EventService_SuggestTags_FullMethodName     = "/event.EventService/SuggestTags"
+	EventService_WatchEvents_FullMethodName     = "/event.EventService/WatchEvents"
+	EventService_BatchEvents_FullMethodName     = "/event.EventService/BatchEvents"
+	EventService_ListTrash_FullMethodName       = "/event.EventService/ListTrash"
+	EventService_RestoreEvent_FullMethodName    = "/event.EventService/RestoreEvent"
+	EventService_GetEventHistory_FullMethodName = "/event.EventService/GetEventHistory"
+	EventService_CreateCalendar_FullMethodName  = "/event.EventService/CreateCalendar"
+	EventService_GetCalendar_FullMethodName     = "/event.EventService/GetCalendar"
+	EventService_UpdateCalendar_FullMethodName  = "/event.EventService/UpdateCalendar"
+	EventService_DeleteCalendar_FullMethodName  = "/event.EventService/DeleteCalendar"
+	EventService_ListCalendars_FullMethodName   = "/event.EventService/ListCalendars"
+	EventService_ShareCalendar_FullMethodName   = "/event.EventService/ShareCalendar"
+	EventService_UnshareCalendar_FullMethodName = "/event.EventService/UnshareCalendar"
+	EventService_ListShares_FullMethodName      = "/event.EventService/ListShares"
 )
 
 // EventServiceClient is the client API for EventService service.
@@ -30,9 +45,24 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type EventServiceClient interface {
 	CreateEvent(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*Response, error)
+	GetEvent(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	UpdateEvent(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Response, error)
 	DeleteEvent(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error)
 	ListEvents(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	SuggestTags(ctx context.Context, in *SuggestTagsRequest, opts ...grpc.CallOption) (*SuggestTagsResponse, error)
+	WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EventChange], error)
+	BatchEvents(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	RestoreEvent(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*Response, error)
+	GetEventHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	CreateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*Response, error)
+	GetCalendar(ctx context.Context, in *GetCalendarRequest, opts ...grpc.CallOption) (*CalendarResponse, error)
+	UpdateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*Response, error)
+	DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*Response, error)
+	ListCalendars(ctx context.Context, in *ListCalendarsRequest, opts ...grpc.CallOption) (*ListCalendarsResponse, error)
+	ShareCalendar(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*Response, error)
+	UnshareCalendar(ctx context.Context, in *UnshareRequest, opts ...grpc.CallOption) (*Response, error)
+	ListShares(ctx context.Context, in *ListSharesRequest, opts ...grpc.CallOption) (*ListSharesResponse, error)
 }
 
 type eventServiceClient struct {
@@ -53,6 +83,16 @@ func (c *eventServiceClient) CreateEvent(ctx context.Context, in *CreateRequest,
 	return out, nil
 }
 
+func (c *eventServiceClient) GetEvent(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, EventService_GetEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eventServiceClient) UpdateEvent(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Response, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Response)
@@ -83,14 +123,178 @@ func (c *eventServiceClient) ListEvents(ctx context.Context, in *ListRequest, op
 	return out, nil
 }
 
+func (c *eventServiceClient) SuggestTags(ctx context.Context, in *SuggestTagsRequest, opts ...grpc.CallOption) (*SuggestTagsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SuggestTagsResponse)
+	err := c.cc.Invoke(ctx, EventService_SuggestTags_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) WatchEvents(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EventChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventService_ServiceDesc.Streams[0], EventService_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, EventChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_WatchEventsClient = grpc.ServerStreamingClient[EventChange]
+
+func (c *eventServiceClient) BatchEvents(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, EventService_BatchEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, EventService_ListTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) RestoreEvent(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_RestoreEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) GetEventHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, EventService_GetEventHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) CreateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_CreateCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) GetCalendar(ctx context.Context, in *GetCalendarRequest, opts ...grpc.CallOption) (*CalendarResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CalendarResponse)
+	err := c.cc.Invoke(ctx, EventService_GetCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) UpdateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_UpdateCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) DeleteCalendar(ctx context.Context, in *DeleteCalendarRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_DeleteCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ListCalendars(ctx context.Context, in *ListCalendarsRequest, opts ...grpc.CallOption) (*ListCalendarsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCalendarsResponse)
+	err := c.cc.Invoke(ctx, EventService_ListCalendars_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ShareCalendar(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_ShareCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) UnshareCalendar(ctx context.Context, in *UnshareRequest, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, EventService_UnshareCalendar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventServiceClient) ListShares(ctx context.Context, in *ListSharesRequest, opts ...grpc.CallOption) (*ListSharesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSharesResponse)
+	err := c.cc.Invoke(ctx, EventService_ListShares_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EventServiceServer is the server API for EventService service.
 // All implementations must embed UnimplementedEventServiceServer
 // for forward compatibility.
 type EventServiceServer interface {
 	CreateEvent(context.Context, *CreateRequest) (*Response, error)
+	GetEvent(context.Context, *GetRequest) (*GetResponse, error)
 	UpdateEvent(context.Context, *UpdateRequest) (*Response, error)
 	DeleteEvent(context.Context, *DeleteRequest) (*Response, error)
 	ListEvents(context.Context, *ListRequest) (*ListResponse, error)
+	SuggestTags(context.Context, *SuggestTagsRequest) (*SuggestTagsResponse, error)
+	WatchEvents(*WatchRequest, grpc.ServerStreamingServer[EventChange]) error
+	BatchEvents(context.Context, *BatchRequest) (*BatchResponse, error)
+	ListTrash(context.Context, *ListTrashRequest) (*ListResponse, error)
+	RestoreEvent(context.Context, *RestoreRequest) (*Response, error)
+	GetEventHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	CreateCalendar(context.Context, *CalendarRequest) (*Response, error)
+	GetCalendar(context.Context, *GetCalendarRequest) (*CalendarResponse, error)
+	UpdateCalendar(context.Context, *CalendarRequest) (*Response, error)
+	DeleteCalendar(context.Context, *DeleteCalendarRequest) (*Response, error)
+	ListCalendars(context.Context, *ListCalendarsRequest) (*ListCalendarsResponse, error)
+	ShareCalendar(context.Context, *ShareRequest) (*Response, error)
+	UnshareCalendar(context.Context, *UnshareRequest) (*Response, error)
+	ListShares(context.Context, *ListSharesRequest) (*ListSharesResponse, error)
 	mustEmbedUnimplementedEventServiceServer()
 }
 
@@ -104,6 +308,9 @@ type UnimplementedEventServiceServer struct{}
 func (UnimplementedEventServiceServer) CreateEvent(context.Context, *CreateRequest) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateEvent not implemented")
 }
+func (UnimplementedEventServiceServer) GetEvent(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEvent not implemented")
+}
 func (UnimplementedEventServiceServer) UpdateEvent(context.Context, *UpdateRequest) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateEvent not implemented")
 }
@@ -113,6 +320,48 @@ func (UnimplementedEventServiceServer) DeleteEvent(context.Context, *DeleteReque
 func (UnimplementedEventServiceServer) ListEvents(context.Context, *ListRequest) (*ListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListEvents not implemented")
 }
+func (UnimplementedEventServiceServer) SuggestTags(context.Context, *SuggestTagsRequest) (*SuggestTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SuggestTags not implemented")
+}
+func (UnimplementedEventServiceServer) WatchEvents(*WatchRequest, grpc.ServerStreamingServer[EventChange]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedEventServiceServer) BatchEvents(context.Context, *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchEvents not implemented")
+}
+func (UnimplementedEventServiceServer) ListTrash(context.Context, *ListTrashRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedEventServiceServer) RestoreEvent(context.Context, *RestoreRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreEvent not implemented")
+}
+func (UnimplementedEventServiceServer) GetEventHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEventHistory not implemented")
+}
+func (UnimplementedEventServiceServer) CreateCalendar(context.Context, *CalendarRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) GetCalendar(context.Context, *GetCalendarRequest) (*CalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) UpdateCalendar(context.Context, *CalendarRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) DeleteCalendar(context.Context, *DeleteCalendarRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) ListCalendars(context.Context, *ListCalendarsRequest) (*ListCalendarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCalendars not implemented")
+}
+func (UnimplementedEventServiceServer) ShareCalendar(context.Context, *ShareRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) UnshareCalendar(context.Context, *UnshareRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnshareCalendar not implemented")
+}
+func (UnimplementedEventServiceServer) ListShares(context.Context, *ListSharesRequest) (*ListSharesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListShares not implemented")
+}
 func (UnimplementedEventServiceServer) mustEmbedUnimplementedEventServiceServer() {}
 func (UnimplementedEventServiceServer) testEmbeddedByValue()                      {}
 
@@ -152,6 +401,24 @@ func _EventService_CreateEvent_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventService_GetEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).GetEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_GetEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).GetEvent(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _EventService_UpdateEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateRequest)
 	if err := dec(in); err != nil {
@@ -206,6 +473,251 @@ func _EventService_ListEvents_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventService_SuggestTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).SuggestTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_SuggestTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).SuggestTags(ctx, req.(*SuggestTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventServiceServer).WatchEvents(m, &grpc.GenericServerStream[WatchRequest, EventChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_WatchEventsServer = grpc.ServerStreamingServer[EventChange]
+
+func _EventService_BatchEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).BatchEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_BatchEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).BatchEvents(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ListTrash(ctx, req.(*ListTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_RestoreEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).RestoreEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_RestoreEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).RestoreEvent(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_GetEventHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).GetEventHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_GetEventHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).GetEventHistory(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_CreateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).CreateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_CreateCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).CreateCalendar(ctx, req.(*CalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_GetCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).GetCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_GetCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).GetCalendar(ctx, req.(*GetCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_UpdateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).UpdateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_UpdateCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).UpdateCalendar(ctx, req.(*CalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_DeleteCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).DeleteCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_DeleteCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).DeleteCalendar(ctx, req.(*DeleteCalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ListCalendars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCalendarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ListCalendars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_ListCalendars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ListCalendars(ctx, req.(*ListCalendarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ShareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ShareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_ShareCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ShareCalendar(ctx, req.(*ShareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_UnshareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnshareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).UnshareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_UnshareCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).UnshareCalendar(ctx, req.(*UnshareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventService_ListShares_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSharesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).ListShares(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventService_ListShares_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).ListShares(ctx, req.(*ListSharesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // EventService_ServiceDesc is the grpc.ServiceDesc for EventService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -217,6 +729,10 @@ var EventService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateEvent",
 			Handler:    _EventService_CreateEvent_Handler,
 		},
+		{
+			MethodName: "GetEvent",
+			Handler:    _EventService_GetEvent_Handler,
+		},
 		{
 			MethodName: "UpdateEvent",
 			Handler:    _EventService_UpdateEvent_Handler,
@@ -229,7 +745,65 @@ var EventService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListEvents",
 			Handler:    _EventService_ListEvents_Handler,
 		},
+		{
+			MethodName: "SuggestTags",
+			Handler:    _EventService_SuggestTags_Handler,
+		},
+		{
+			MethodName: "BatchEvents",
+			Handler:    _EventService_BatchEvents_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _EventService_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreEvent",
+			Handler:    _EventService_RestoreEvent_Handler,
+		},
+		{
+			MethodName: "GetEventHistory",
+			Handler:    _EventService_GetEventHistory_Handler,
+		},
+		{
+			MethodName: "CreateCalendar",
+			Handler:    _EventService_CreateCalendar_Handler,
+		},
+		{
+			MethodName: "GetCalendar",
+			Handler:    _EventService_GetCalendar_Handler,
+		},
+		{
+			MethodName: "UpdateCalendar",
+			Handler:    _EventService_UpdateCalendar_Handler,
+		},
+		{
+			MethodName: "DeleteCalendar",
+			Handler:    _EventService_DeleteCalendar_Handler,
+		},
+		{
+			MethodName: "ListCalendars",
+			Handler:    _EventService_ListCalendars_Handler,
+		},
+		{
+			MethodName: "ShareCalendar",
+			Handler:    _EventService_ShareCalendar_Handler,
+		},
+		{
+			MethodName: "UnshareCalendar",
+			Handler:    _EventService_UnshareCalendar_Handler,
+		},
+		{
+			MethodName: "ListShares",
+			Handler:    _EventService_ListShares_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _EventService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "EventService.proto",
 }
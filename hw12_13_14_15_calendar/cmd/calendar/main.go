@@ -10,12 +10,17 @@ import (
 	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	blobstore "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/store"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/metrics"
 	internalserver "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/server"
 	internalstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	memorystorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+	sqlitestorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sqlite"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
 )
 
 func main() {
@@ -34,21 +39,70 @@ func main() {
 	}()
 
 	cfg := config.NewConfig()
-	logg := logger.New(cfg.Logger)
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), cfg, flag.Args()[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		return
+	}
+	logg := logger.New(cfg.Logger).With("service", "calendar")
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "calendar")
+	if err != nil {
+		panic(fmt.Sprintf("init tracing error: %v", err))
+	}
 
 	var storage app.Storage
 
 	switch cfg.App.Storage {
 	case "memory":
-		storage = memorystorage.New()
+		memStorage, err := memorystorage.Open(cfg.Memory)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		storage = memStorage
 	case "sql":
-		storage = sqlstorage.New(cfg.Database)
+		sqlStorage := sqlstorage.New(cfg.Database)
+		if err := sqlStorage.Connect(context.Background()); err != nil {
+			panic(err.Error())
+		}
+
+		storage = sqlStorage
+	case "sqlite":
+		sqliteStorage := sqlitestorage.New(cfg.SQLite)
+		if err := sqliteStorage.Connect(context.Background()); err != nil {
+			panic(err.Error())
+		}
+
+		storage = sqliteStorage
 	default:
 		panic(fmt.Sprintf("%s: %v: %s", cfg.App.Storage, internalstorage.ErrStorageNotExist, cfg.App.Storage))
 	}
 
+	storage = metrics.NewStorage(storage)
+
+	healthChecker := health.New()
+	healthChecker.AddCheck("storage", storage.Ping)
+
 	calendar := app.New(logg, storage)
-	server := internalserver.New(cfg, logg, calendar)
+
+	blobs, err := blobstore.Open(cfg.Attachments)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if blobs != nil {
+		calendar.EnableAttachments(blobs, app.AttachmentLimits{
+			MaxSize:     cfg.Attachments.MaxSize,
+			MaxPerEvent: cfg.Attachments.MaxPerEvent,
+		})
+	}
+	server := internalserver.New(cfg, logg, calendar, healthChecker)
+	metricsServer := metrics.NewServer(cfg.Metrics, logg)
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
@@ -67,6 +121,20 @@ func main() {
 		if err := server.Stop(ctx); err != nil {
 			logg.Error("failed to stop http server: " + err.Error())
 		}
+
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server: " + err.Error())
+		}
+
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to shutdown tracing: " + err.Error())
+		}
+	}()
+
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
 	}()
 
 	logg.Info("calendar is running...")
@@ -77,3 +145,4 @@ func main() {
 		os.Exit(1) //nolint:gocritic
 	}
 }
+
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	sqlstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var (
+	ErrMigrateUsage      = errors.New("usage: calendar -config <file> migrate up|down|status")
+	ErrMigrateNotSupport = errors.New("migrate is only supported by the sql storage, sqlite migrates itself")
+)
+
+// runMigrate implements `calendar migrate up|down|status` for the database of the config.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		return ErrMigrateUsage
+	}
+
+	if cfg.App.Storage != "sql" {
+		return ErrMigrateNotSupport
+	}
+
+	storage := sqlstorage.New(cfg.Database)
+	defer storage.Close(ctx)
+
+	if err := storage.Open(ctx); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := storage.MigrateUp(ctx)
+		for _, migration := range applied {
+			fmt.Println("applied", migration.Name)
+		}
+
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+
+		return err
+	case "down":
+		migration, err := storage.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("rolled back", migration.Name)
+
+		return nil
+	case "status":
+		return printMigrationStatus(ctx, storage)
+	default:
+		return ErrMigrateUsage
+	}
+}
+
+func printMigrationStatus(ctx context.Context, storage *sqlstorage.Storage) error {
+	statuses, err := storage.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\n", appliedAt, status.Name)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/dateparse"
+	"github.com/google/uuid"
+)
+
+const (
+	// serverLayout is the date format of the API, the server keeps dates in UTC.
+	serverLayout = "2006-01-02 15:04"
+	dayLayout    = "2006-01-02"
+
+	defaultDuration = time.Hour
+	defaultRadius   = 1000
+)
+
+var ErrEmptyTitle = errors.New("title is required")
+
+// eventFlags are the event fields shared by create and update.
+type eventFlags struct {
+	title       string
+	start       string
+	end         string
+	description string
+	notifyDays  int
+	calendarID  string
+	category    string
+	color       string
+	tags        string
+	address     string
+	point       string
+	meetingURL  string
+}
+
+func newEventFlagSet(name string, ef *eventFlags) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	flags.StringVar(&ef.title, "title", "", "event title")
+	flags.StringVar(&ef.start, "start", "", "start date")
+	flags.StringVar(&ef.end, "end", "", "end date")
+	flags.StringVar(&ef.description, "description", "", "event description")
+	flags.IntVar(&ef.notifyDays, "notify", 0, "days before the start to notify")
+	flags.StringVar(&ef.calendarID, "calendar", "", "calendar ID")
+	flags.StringVar(&ef.category, "category", "", "event category")
+	flags.StringVar(&ef.color, "color", "", "event color as #rrggbb")
+	flags.StringVar(&ef.tags, "tags", "", "comma separated tags")
+	flags.StringVar(&ef.address, "address", "", "where the event takes place")
+	flags.StringVar(&ef.point, "at", "", "coordinates of the place as lat,lon")
+	flags.StringVar(&ef.meetingURL, "meeting", "", "video conference link")
+
+	return flags
+}
+
+// location returns the location given by the address and at flags, nil if there is none.
+func (ef eventFlags) location() (*pb.Location, error) {
+	if ef.address == "" && ef.point == "" {
+		return nil, nil
+	}
+
+	location := &pb.Location{Address: ef.address}
+
+	if ef.point != "" {
+		point, err := parsePoint(ef.point)
+		if err != nil {
+			return nil, err
+		}
+
+		location.Point = point
+	}
+
+	return location, nil
+}
+
+// parsePoint parses coordinates given as "lat,lon".
+func parsePoint(s string) (*pb.Point, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("%w: coordinates must be lat,lon", ErrUsage)
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: latitude: %w", ErrUsage, err)
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: longitude: %w", ErrUsage, err)
+	}
+
+	return &pb.Point{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// splitTags splits a comma separated list of tags, an empty list gives no tags.
+func splitTags(list string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// notify returns the notification offset in the API type.
+func (ef eventFlags) notify() (int32, error) {
+	if ef.notifyDays < 0 || ef.notifyDays > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: notify days out of range", ErrUsage)
+	}
+
+	return int32(ef.notifyDays), nil
+}
+
+func parseFlags(flags *flag.FlagSet, args []string, positional int) error {
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%s: %w: %w", flags.Name(), ErrUsage, err)
+	}
+
+	if flags.NArg() > positional {
+		return fmt.Errorf("%s: %w: unexpected arguments %q", flags.Name(), ErrUsage, flags.Args()[positional:])
+	}
+
+	return nil
+}
+
+func requireID(flags *flag.FlagSet) (string, error) {
+	if flags.NArg() != 1 || flags.Arg(0) == "" {
+		return "", fmt.Errorf("%s: %w: event ID is required", flags.Name(), ErrUsage)
+	}
+
+	return flags.Arg(0), nil
+}
+
+// parseDate parses a human date in local time, see dateparse.Parse.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	date, err := dateparse.Parse(s, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	return date, nil
+}
+
+func createCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	var ef eventFlags
+
+	flags := newEventFlagSet("create", &ef)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	if ef.title == "" {
+		return fmt.Errorf("create: %w: %w", ErrUsage, ErrEmptyTitle)
+	}
+
+	id := flags.Arg(0)
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	now := time.Now()
+
+	start := now.Truncate(time.Minute)
+	if ef.start != "" {
+		parsed, err := parseDate(ef.start, now)
+		if err != nil {
+			return err
+		}
+
+		start = parsed
+	}
+
+	end := start.Add(defaultDuration)
+	if ef.end != "" {
+		parsed, err := parseDate(ef.end, now)
+		if err != nil {
+			return err
+		}
+
+		end = parsed
+	}
+
+	notifyDays, err := ef.notify()
+	if err != nil {
+		return err
+	}
+
+	location, err := ef.location()
+	if err != nil {
+		return err
+	}
+
+	event := &pb.Event{
+		Id:          id,
+		Title:       ef.title,
+		StartDate:   start.UTC().Format(serverLayout),
+		EndDate:     end.UTC().Format(serverLayout),
+		Description: ef.description,
+		NotifyDays:  notifyDays,
+		CalendarId:  ef.calendarID,
+		Category:    ef.category,
+		Color:       ef.color,
+		Tags:        splitTags(ef.tags),
+		Location:    location,
+		MeetingUrl:  ef.meetingURL,
+	}
+
+	// CreateEvent takes only a title, a single-operation batch creates the whole event atomically.
+	resp, err := client.BatchEvents(ctx, &pb.BatchRequest{
+		Atomic:     true,
+		Operations: []*pb.Operation{{Type: "create", Event: event}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, result := range resp.GetResults() {
+		if result.GetError() {
+			return errors.New(result.GetMessage())
+		}
+	}
+
+	return printEvent(ctx, client, out, id)
+}
+
+func updateCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	var ef eventFlags
+
+	flags := newEventFlagSet("update", &ef)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	id, err := requireID(flags)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	event := &pb.Event{Id: id}
+
+	// Only the given flags are sent, the server keeps the other fields as they are.
+	var visitErr error
+
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			event.Title = ef.title
+		case "description":
+			event.Description = ef.description
+		case "notify":
+			notifyDays, err := ef.notify()
+			if err != nil {
+				visitErr = err
+				return
+			}
+
+			event.NotifyDays = notifyDays
+		case "calendar":
+			event.CalendarId = ef.calendarID
+		case "category":
+			event.Category = ef.category
+		case "color":
+			event.Color = ef.color
+		case "tags":
+			event.Tags = splitTags(ef.tags)
+		case "meeting":
+			event.MeetingUrl = ef.meetingURL
+		case "address", "at":
+			location, err := ef.location()
+			if err != nil {
+				visitErr = err
+				return
+			}
+
+			event.Location = location
+		case "start", "end":
+			date, err := parseDate(f.Value.String(), now)
+			if err != nil {
+				visitErr = err
+				return
+			}
+
+			if f.Name == "start" {
+				event.StartDate = date.UTC().Format(serverLayout)
+			} else {
+				event.EndDate = date.UTC().Format(serverLayout)
+			}
+		}
+	})
+
+	if visitErr != nil {
+		return visitErr
+	}
+
+	if _, err = client.UpdateEvent(ctx, &pb.UpdateRequest{Id: id, Event: event}); err != nil {
+		return err
+	}
+
+	return printEvent(ctx, client, out, id)
+}
+
+func deleteCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	id, err := requireID(flags)
+	if err != nil {
+		return err
+	}
+
+	if _, err = client.DeleteEvent(ctx, &pb.DeleteRequest{Id: id}); err != nil {
+		return err
+	}
+
+	return out.deleted(id)
+}
+
+func getCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	id, err := requireID(flags)
+	if err != nil {
+		return err
+	}
+
+	return printEvent(ctx, client, out, id)
+}
+
+func listCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	date := flags.String("date", "today", "first day of the period")
+	period := flags.String("period", "day", "day, week or month")
+	calendarID := flags.String("calendar", "", "calendar ID")
+	category := flags.String("category", "", "event category")
+	tags := flags.String("tags", "", "comma separated tags the events must all have")
+	near := flags.String("near", "", "only events within the radius of lat,lon")
+	radius := flags.Float64("radius", defaultRadius, "radius of the near search in meters")
+
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	day, err := parseDate(*date, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var area *pb.Area
+
+	if *near != "" {
+		center, err := parsePoint(*near)
+		if err != nil {
+			return err
+		}
+
+		area = &pb.Area{Center: center, Radius: *radius}
+	}
+
+	resp, err := client.ListEvents(ctx, &pb.ListRequest{
+		Date:       day.Format(dayLayout),
+		Period:     *period,
+		CalendarId: *calendarID,
+		Category:   *category,
+		Tags:       splitTags(*tags),
+		Near:       area,
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.events(resp.GetEvents())
+}
+
+func watchCommand(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	from := flags.String("from", "", "watch events starting from this day")
+	to := flags.String("to", "", "watch events starting up to this day")
+	epoch := flags.String("epoch", "", "resume after a change of this server epoch")
+	seq := flags.Uint64("seq", 0, "resume after this change sequence number")
+
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	req := &pb.WatchRequest{Epoch: *epoch, FromSeq: *seq}
+	now := time.Now()
+
+	for _, bound := range []struct {
+		value string
+		field *string
+	}{{*from, &req.FromDate}, {*to, &req.ToDate}} {
+		if bound.value == "" {
+			continue
+		}
+
+		day, err := parseDate(bound.value, now)
+		if err != nil {
+			return err
+		}
+
+		*bound.field = day.Format(dayLayout)
+	}
+
+	stream, err := client.WatchEvents(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		change, err := stream.Recv()
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = out.change(change); err != nil {
+			return err
+		}
+	}
+}
+
+func printEvent(ctx context.Context, client pb.EventServiceClient, out printer, id string) error {
+	resp, err := client.GetEvent(ctx, &pb.GetRequest{Id: id})
+	if err != nil {
+		return err
+	}
+
+	return out.event(resp.GetEvent())
+}
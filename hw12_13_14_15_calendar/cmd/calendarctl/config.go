@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultAddress = "localhost:8080"
+	defaultTimeout = 5 * time.Second
+	defaultProfile = "default"
+)
+
+var ErrProfileNotExists = errors.New("profile does not exist")
+
+// Profile holds connection settings of one server, the config file may keep several of them:
+//
+//	profile = "work"
+//
+//	[profiles.work]
+//	address = "calendar.example.com:8080"
+//	user_id = "alice"
+//	timeout = "10s"
+type Profile struct {
+	Address string        `mapstructure:"address"`
+	UserID  string        `mapstructure:"user_id"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type fileConfig struct {
+	Profile  string             `mapstructure:"profile"`
+	Profiles map[string]Profile `mapstructure:"profiles"`
+}
+
+// defaultConfigFile returns ~/.config/calendarctl/config.toml or an empty string when the home
+// directory is unknown.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "calendarctl", "config.toml")
+}
+
+// loadProfile reads the named profile, or the one selected in the file when name is empty.
+// A missing default config file is not an error: the built-in defaults are used then.
+func loadProfile(file, name string) (Profile, error) {
+	profile := Profile{Address: defaultAddress, Timeout: defaultTimeout}
+
+	explicit := file != ""
+	if !explicit {
+		file = defaultConfigFile()
+	}
+
+	if _, err := os.Stat(file); file == "" || (!explicit && errors.Is(err, os.ErrNotExist)) {
+		if name != "" && name != defaultProfile {
+			return Profile{}, fmt.Errorf("%s: %w", name, ErrProfileNotExists)
+		}
+
+		return profile, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("toml")
+
+	if err := v.ReadInConfig(); err != nil {
+		return Profile{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg fileConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return Profile{}, fmt.Errorf("read config: %w", err)
+	}
+
+	if name == "" {
+		name = cfg.Profile
+	}
+
+	if name == "" {
+		name = defaultProfile
+	}
+
+	stored, ok := cfg.Profiles[name]
+	if !ok {
+		if name == defaultProfile {
+			return profile, nil
+		}
+
+		return Profile{}, fmt.Errorf("%s: %w", name, ErrProfileNotExists)
+	}
+
+	if stored.Address != "" {
+		profile.Address = stored.Address
+	}
+
+	if stored.Timeout > 0 {
+		profile.Timeout = stored.Timeout
+	}
+
+	profile.UserID = stored.UserID
+
+	return profile, nil
+}
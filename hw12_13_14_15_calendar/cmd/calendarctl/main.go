@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const usage = `Usage: calendarctl [flags] <command> [command flags] [args]
+
+Commands:
+  create  -title <title> [-start <date>] [-end <date>] [-description <text>] [-notify <days>] [-calendar <id>]
+          [-category <name>] [-color <#rrggbb>] [-tags <tag,...>] [-address <text>] [-at <lat,lon>]
+          [-meeting <url>] [id]
+  update  [-title <title>] [-start <date>] [-end <date>] [-description <text>] [-notify <days>] [-calendar <id>]
+          [-category <name>] [-color <#rrggbb>] [-tags <tag,...>] [-address <text>] [-at <lat,lon>]
+          [-meeting <url>] <id>
+  delete  <id>
+  get     <id>
+  list    [-date <date>] [-period day|week|month] [-calendar <id>] [-category <name>] [-tags <tag,...>]
+          [-near <lat,lon> [-radius <meters>]]
+  watch   [-from <date>] [-to <date>] [-epoch <epoch> -seq <n>]
+  version
+
+Dates are either absolute like "2025-02-01 10:00" or phrases like "tomorrow 10:00",
+"next monday at 9am" and "in 2 hours" in local time.
+
+Flags:
+`
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrUsage          = errors.New("invalid usage")
+)
+
+type command func(ctx context.Context, client pb.EventServiceClient, out printer, args []string) error
+
+var commands = map[string]command{
+	"create": createCommand,
+	"update": updateCommand,
+	"delete": deleteCommand,
+	"get":    getCommand,
+	"list":   listCommand,
+	"watch":  watchCommand,
+}
+
+func main() {
+	flags := flag.NewFlagSet("calendarctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	configFile := flags.String("config", "", "path to profiles file (default ~/.config/calendarctl/config.toml)")
+	profileName := flags.String("profile", "", "profile name from the config file")
+	address := flags.String("addr", "", "gRPC server address, overrides the profile")
+	userID := flags.String("user", "", "user ID sent in x-user-id metadata, overrides the profile")
+	output := flags.String("o", "table", "output format: table or json")
+	timeout := flags.Duration("timeout", 0, "request timeout, overrides the profile")
+
+	_ = flags.Parse(os.Args[1:])
+
+	if err := run(flags, *configFile, *profileName, Profile{
+		Address: *address, UserID: *userID, Timeout: *timeout,
+	}, *output); err != nil {
+		// Server errors arrive as gRPC statuses, only their message is of interest to the user.
+		fmt.Fprintln(os.Stderr, "calendarctl:", status.Convert(err).Message())
+
+		if errors.Is(err, ErrUsage) || errors.Is(err, ErrUnknownCommand) {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		os.Exit(1)
+	}
+}
+
+func run(flags *flag.FlagSet, configFile, profileName string, overrides Profile, output string) error {
+	name := flags.Arg(0)
+	if name == "version" {
+		printVersion()
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("%q: %w", name, ErrUnknownCommand)
+	}
+
+	out, err := newPrinter(output, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	profile, err := loadProfile(configFile, profileName)
+	if err != nil {
+		return err
+	}
+
+	if overrides.Address != "" {
+		profile.Address = overrides.Address
+	}
+
+	if overrides.UserID != "" {
+		profile.UserID = overrides.UserID
+	}
+
+	if overrides.Timeout > 0 {
+		profile.Timeout = overrides.Timeout
+	}
+
+	conn, err := grpc.NewClient(profile.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", profile.Address, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Watch streams until interrupted, other commands are single requests bounded by the timeout.
+	if name != "watch" {
+		ctx, cancel = context.WithTimeout(ctx, profile.Timeout)
+		defer cancel()
+	}
+
+	if profile.UserID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", profile.UserID)
+	}
+
+	return cmd(ctx, pb.NewEventServiceClient(conn), out, flags.Args()[1:])
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+
+	localLayout = "2006-01-02 15:04 MST"
+)
+
+var ErrUnknownOutput = errors.New("unknown output format")
+
+type printer interface {
+	event(event *pb.Event) error
+	events(events []*pb.Event) error
+	change(change *pb.EventChange) error
+	deleted(id string) error
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case outputTable:
+		return tablePrinter{w: w}, nil
+	case outputJSON:
+		return jsonPrinter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("%q: %w", format, ErrUnknownOutput)
+	}
+}
+
+type eventView struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	StartDate   string   `json:"start_date"`
+	EndDate     string   `json:"end_date"`
+	Description string   `json:"description,omitempty"`
+	UserID      string   `json:"user_id,omitempty"`
+	NotifyDays  int32    `json:"notify_days,omitempty"`
+	CalendarID  string   `json:"calendar_id,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Address     string   `json:"address,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	MeetingURL  string   `json:"meeting_url,omitempty"`
+}
+
+type changeView struct {
+	Epoch string    `json:"epoch"`
+	Seq   uint64    `json:"seq"`
+	Type  string    `json:"type"`
+	Event eventView `json:"event"`
+}
+
+// newEventView converts server dates from UTC to local time.
+func newEventView(event *pb.Event) eventView {
+	view := eventView{
+		ID:          event.GetId(),
+		Title:       event.GetTitle(),
+		StartDate:   localDate(event.GetStartDate()),
+		EndDate:     localDate(event.GetEndDate()),
+		Description: event.GetDescription(),
+		UserID:      event.GetUserId(),
+		NotifyDays:  event.GetNotifyDays(),
+		CalendarID:  event.GetCalendarId(),
+		Category:    event.GetCategory(),
+		Color:       event.GetColor(),
+		Tags:        event.GetTags(),
+		Address:     event.GetLocation().GetAddress(),
+		MeetingURL:  event.GetMeetingUrl(),
+	}
+
+	if point := event.GetLocation().GetPoint(); point != nil {
+		latitude, longitude := point.GetLatitude(), point.GetLongitude()
+		view.Latitude, view.Longitude = &latitude, &longitude
+	}
+
+	return view
+}
+
+// location formats the address and the coordinates of the event.
+func (v eventView) location() string {
+	var point string
+	if v.Latitude != nil && v.Longitude != nil {
+		point = fmt.Sprintf("%.6f, %.6f", *v.Latitude, *v.Longitude)
+	}
+
+	switch {
+	case v.Address == "":
+		return point
+	case point == "":
+		return v.Address
+	default:
+		return v.Address + " (" + point + ")"
+	}
+}
+
+func localDate(date string) string {
+	parsed, err := time.Parse(serverLayout, date)
+	if err != nil {
+		return date
+	}
+
+	return parsed.Local().Format(localLayout)
+}
+
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p jsonPrinter) event(event *pb.Event) error {
+	return p.enc.Encode(newEventView(event))
+}
+
+func (p jsonPrinter) events(events []*pb.Event) error {
+	views := make([]eventView, 0, len(events))
+	for _, event := range events {
+		views = append(views, newEventView(event))
+	}
+
+	return p.enc.Encode(views)
+}
+
+func (p jsonPrinter) change(change *pb.EventChange) error {
+	return p.enc.Encode(changeView{
+		Epoch: change.GetEpoch(),
+		Seq:   change.GetSeq(),
+		Type:  change.GetType(),
+		Event: newEventView(change.GetEvent()),
+	})
+}
+
+func (p jsonPrinter) deleted(id string) error {
+	return p.enc.Encode(struct {
+		ID      string `json:"id"`
+		Deleted bool   `json:"deleted"`
+	}{ID: id, Deleted: true})
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p tablePrinter) event(event *pb.Event) error {
+	view := newEventView(event)
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+
+	for _, row := range [][2]string{
+		{"ID", view.ID},
+		{"Title", view.Title},
+		{"Start", view.StartDate},
+		{"End", view.EndDate},
+		{"Description", view.Description},
+		{"Calendar", view.CalendarID},
+		{"Category", view.Category},
+		{"Color", view.Color},
+		{"Tags", strings.Join(view.Tags, ", ")},
+		{"Location", view.location()},
+		{"Meeting", view.MeetingURL},
+		{"User", view.UserID},
+		{"Notify days", fmt.Sprint(view.NotifyDays)},
+	} {
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+
+	return tw.Flush()
+}
+
+func (p tablePrinter) events(events []*pb.Event) error {
+	if len(events) == 0 {
+		_, err := fmt.Fprintln(p.w, "No events.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tSTART\tEND\tCALENDAR")
+
+	for _, event := range events {
+		view := newEventView(event)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", view.ID, view.Title, view.StartDate, view.EndDate, view.CalendarID)
+	}
+
+	return tw.Flush()
+}
+
+// change prints one line per change, a stream cannot be aligned as a whole.
+func (p tablePrinter) change(change *pb.EventChange) error {
+	view := newEventView(change.GetEvent())
+
+	_, err := fmt.Fprintf(p.w, "#%s-%d %-7s %s  %s  %s\n",
+		change.GetEpoch(), change.GetSeq(), change.GetType(), view.ID, view.StartDate, view.Title)
+
+	return err
+}
+
+func (p tablePrinter) deleted(id string) error {
+	_, err := fmt.Fprintf(p.w, "Event %s deleted.\n", id)
+	return err
+}
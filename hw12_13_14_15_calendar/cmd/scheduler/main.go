@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -10,14 +11,27 @@ import (
 	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	blobstore "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/store"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/metrics"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/queue/rabbit"
 	internalstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	memorystorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+	sqlitestorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sqlite"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
 )
 
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	defaultDigestInterval = time.Minute
+)
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/cmd/scheduler")
+
 func main() {
 	flag.Parse()
 
@@ -29,20 +43,67 @@ func main() {
 	}()
 
 	cfg := config.NewConfig()
-	logg := logger.New(cfg.Logger)
+	logg := logger.New(cfg.Logger).With("service", "scheduler")
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "calendar-scheduler")
+	if err != nil {
+		panic(fmt.Sprintf("init tracing error: %v", err))
+	}
 
 	var storage app.Storage
 
 	switch cfg.App.Storage {
 	case "memory":
-		storage = memorystorage.New()
+		memStorage, err := memorystorage.Open(cfg.Memory)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		storage = memStorage
 	case "sql":
-		storage = sqlstorage.New(cfg.Database)
+		sqlStorage := sqlstorage.New(cfg.Database)
+		if err := sqlStorage.Connect(context.Background()); err != nil {
+			panic(err.Error())
+		}
+
+		storage = sqlStorage
+	case "sqlite":
+		sqliteStorage := sqlitestorage.New(cfg.SQLite)
+		if err := sqliteStorage.Connect(context.Background()); err != nil {
+			panic(err.Error())
+		}
+
+		storage = sqliteStorage
 	default:
 		panic(fmt.Sprintf("%s: %v: %s", cfg.App.Storage, internalstorage.ErrStorageNotExist, cfg.App.Storage))
 	}
 
+	storage = metrics.NewStorage(storage)
+
+	// The scheduler purges attachments and prepares notifications, the attachment limits do not matter.
+	calendar := app.New(logg, storage)
+
+	blobs, err := blobstore.Open(cfg.Attachments)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if blobs != nil {
+		calendar.EnableAttachments(blobs, app.AttachmentLimits{})
+	}
+
 	queue := rabbit.New(cfg.Rabbit)
+	metricsServer := metrics.NewServer(cfg.Metrics, logg)
+
+	healthChecker := health.New()
+	healthChecker.AddCheck("storage", storage.Ping)
+	healthChecker.AddCheck("queue", queue.Ping)
+	healthServer := health.NewServer(cfg.Health, logg, healthChecker)
+
+	trashRetention := cfg.App.TrashRetention
+	if trashRetention <= 0 {
+		trashRetention = defaultTrashRetention
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
@@ -62,48 +123,177 @@ func main() {
 			logg.Error("failed to close connection to queue: " + err.Error())
 		}
 
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server: " + err.Error())
+		}
+
+		if err := healthServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop health server: " + err.Error())
+		}
+
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to shutdown tracing: " + err.Error())
+		}
+
 		os.Exit(0)
 	}()
 
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
+	}()
+
+	go func() {
+		if err := healthServer.Start(ctx); err != nil {
+			logg.Error("failed to start health server: " + err.Error())
+		}
+	}()
+
+	digestInterval := cfg.App.DigestInterval
+	if digestInterval <= 0 {
+		digestInterval = defaultDigestInterval
+	}
+
+	go runDigests(ctx, calendar, queue, logg, digestInterval)
+
 	logg.Info("scheduler is running...")
 
 	for {
 		logg.Debug("handling events...")
-		now := time.Now()
+		start := time.Now()
+
+		err := handleEvents(ctx, calendar, storage, queue, logg, trashRetention)
+		metrics.ObserveSchedulerJob(err, time.Since(start))
 
-		err := storage.DeleteOldEvents(ctx, now)
 		if err != nil {
-			logg.Error("failed to delete old events: " + err.Error())
+			logg.Error(err.Error())
 			return
 		}
 
-		events, err := storage.ListEventsForNotify(ctx, now)
-		if err != nil {
-			logg.Error("failed to list events for notify: " + err.Error())
-			return
+		healthChecker.MarkRun(time.Now())
+
+		logg.Debug("sleeping 1 day...")
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+func handleEvents(
+	ctx context.Context,
+	calendar *app.App,
+	storage app.Storage,
+	queue *rabbit.Client,
+	logg logger.Logger,
+	trashRetention time.Duration,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "scheduler.handleEvents")
+	defer func() { tracing.Finish(span, err) }()
+
+	now := time.Now()
+
+	err = storage.DeleteOldEvents(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to delete old events: %w", err)
+	}
+
+	err = storage.PurgeDeletedEvents(ctx, now.Add(-trashRetention))
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted events: %w", err)
+	}
+
+	// Attachments of the events removed above are purged right away, a failed content removal
+	// is retried next time and does not stop the reminders.
+	purged, err := calendar.PurgeAttachments(ctx)
+	if err != nil && !errors.Is(err, app.ErrAttachmentsDisabled) {
+		logg.ErrorContext(ctx, "failed to purge attachments: "+err.Error())
+	}
+
+	if purged > 0 {
+		logg.InfoContext(ctx, fmt.Sprintf("purged %d attachments", purged))
+	}
+
+	events, err := storage.ListEventsForNotify(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list events for notify: %w", err)
+	}
+
+	// Reminders follow the preferences of their users: opted out events are skipped, the rest go
+	// to the preferred channel once the quiet hours are over.
+	notifications, err := calendar.PrepareNotifications(ctx, events, now)
+	if err != nil {
+		return fmt.Errorf("failed to prepare notifications: %w", err)
+	}
+
+	for _, notification := range notifications {
+		eventCtx := logger.ContextWith(ctx, "event_id", notification.EventID)
+
+		if err := queue.Add(eventCtx, notification); err != nil {
+			logg.ErrorContext(eventCtx, "failed to add event to queue: "+err.Error())
+			continue
 		}
 
-		for _, event := range events {
-			var userID string
+		metrics.IncNotificationsEnqueued()
+	}
 
-			if event.UserID.Valid {
-				userID = event.UserID.String
-			}
+	return nil
+}
 
-			notification := app.Notification{
-				EventID: event.ID,
-				Title:   event.Title,
-				Date:    event.StartDate.Format("2006-01-02 15:04"),
-				UserID:  userID,
-			}
+// runDigests enqueues the digests due since the previous check every interval. A window which
+// fails to be prepared is retried with the next one, the digests due while the scheduler was
+// down are not sent.
+func runDigests(
+	ctx context.Context,
+	calendar *app.App,
+	queue *rabbit.Client,
+	logg logger.Logger,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
 
-			err = queue.Add(notification)
-			if err != nil {
-				logg.Error("failed to add event to queue: " + err.Error())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := handleDigests(ctx, calendar, queue, logg, last, now); err != nil {
+				logg.Error(err.Error())
+				continue
 			}
+
+			last = now
+		}
+	}
+}
+
+func handleDigests(
+	ctx context.Context,
+	calendar *app.App,
+	queue *rabbit.Client,
+	logg logger.Logger,
+	from, to time.Time,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "scheduler.handleDigests")
+	defer func() { tracing.Finish(span, err) }()
+
+	digests, err := calendar.PrepareDigests(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to prepare digests: %w", err)
+	}
+
+	for _, digest := range digests {
+		userCtx := logger.ContextWith(ctx, "user_id", digest.UserID)
+
+		if err := queue.Add(userCtx, digest); err != nil {
+			logg.ErrorContext(userCtx, "failed to add digest to queue: "+err.Error())
+			continue
 		}
 
-		logg.Debug("sleeping 1 day...")
-		time.Sleep(24 * time.Hour)
+		metrics.IncNotificationsEnqueued()
 	}
+
+	return nil
 }
+
@@ -12,8 +12,13 @@ import (
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/metrics"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/render"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/streadway/amqp"
 )
 
 func main() {
@@ -27,9 +32,24 @@ func main() {
 	}()
 
 	cfg := config.NewConfig()
-	logg := logger.New(cfg.Logger)
+	logg := logger.New(cfg.Logger).With("service", "sender")
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "calendar-sender")
+	if err != nil {
+		panic(fmt.Sprintf("init tracing error: %v", err))
+	}
+
+	renderer, err := render.New(cfg.Templates.Dir)
+	if err != nil {
+		panic(fmt.Sprintf("init templates error: %v", err))
+	}
 
 	queue := rabbit.New(cfg.Rabbit)
+	metricsServer := metrics.NewServer(cfg.Metrics, logg)
+
+	healthChecker := health.New()
+	healthChecker.AddCheck("queue", queue.Ping)
+	healthServer := health.NewServer(cfg.Health, logg, healthChecker)
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
@@ -38,27 +58,94 @@ func main() {
 	go func() {
 		<-ctx.Done()
 
-		_, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 		defer cancel()
 
 		if err := queue.Close(); err != nil {
 			logg.Error("failed to close connection to queue: " + err.Error())
 		}
 
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server: " + err.Error())
+		}
+
+		if err := healthServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop health server: " + err.Error())
+		}
+
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to shutdown tracing: " + err.Error())
+		}
+
 		os.Exit(0)
 	}()
 
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
+	}()
+
+	go func() {
+		if err := healthServer.Start(ctx); err != nil {
+			logg.Error("failed to start health server: " + err.Error())
+		}
+	}()
+
 	logg.Info("sender is running...")
 
 	for rawMessage := range queue.Get() {
-		var notification app.Notification
-
-		if err := json.Unmarshal(rawMessage.Body, &notification); err != nil {
-			logg.Error("failed to unmarshal raw message: " + err.Error())
-			continue
+		if handleMessage(ctx, rawMessage, renderer, logg) {
+			healthChecker.MarkRun(time.Now())
 		}
+	}
+}
+
+func handleMessage(
+	ctx context.Context,
+	rawMessage amqp.Delivery,
+	renderer *render.Renderer,
+	logg logger.Logger,
+) bool {
+	var err error
+
+	ctx, span := rabbit.StartConsumerSpan(ctx, rawMessage)
+	defer func() { tracing.Finish(span, err) }()
+
+	var notification app.Notification
+
+	if err = json.Unmarshal(rawMessage.Body, &notification); err != nil {
+		logg.ErrorContext(ctx, "failed to unmarshal raw message: "+err.Error())
+		metrics.IncNotificationsFailed()
+		return false
+	}
 
-		logg.Info(fmt.Sprintf("sent message for user %s: event '%s' on %s",
-			notification.UserID, notification.Title, notification.Date))
+	if notification.EventID != "" {
+		ctx = logger.ContextWith(ctx, "event_id", notification.EventID)
 	}
+
+	ctx = logger.ContextWith(ctx, "user_id", notification.UserID)
+
+	text, err := renderer.Render(notification)
+	if err != nil {
+		logg.ErrorContext(ctx, "failed to render notification: "+err.Error())
+		metrics.IncNotificationsFailed()
+		return false
+	}
+
+	// Notifications queued before preferences existed carry no channel, they were push ones.
+	channel := notification.Channel
+	if channel == "" {
+		channel = app.ChannelPush
+	}
+
+	recipient := notification.UserID
+	if notification.Address != "" {
+		recipient += " <" + notification.Address + ">"
+	}
+
+	logg.InfoContext(ctx, fmt.Sprintf("sent %s message for user %s: %s", channel, recipient, text))
+	metrics.IncNotificationsDelivered()
+
+	return true
 }
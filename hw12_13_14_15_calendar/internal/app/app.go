@@ -7,7 +7,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -16,11 +21,21 @@ const (
 	PeriodMonth = "month"
 )
 
-var ErrInvalidPeriod = errors.New("invalid period")
+var (
+	ErrInvalidPeriod = errors.New("invalid period")
+	ErrEventNotFound = storage.ErrEventNotExists
+)
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app")
 
 type App struct {
 	logger  Logger
 	storage Storage
+	bus     *Bus
+
+	// blobs is nil unless attachments are enabled.
+	blobs            BlobStore
+	attachmentLimits AttachmentLimits
 }
 
 //go:generate mockery --name=Logger
@@ -29,18 +44,56 @@ type Logger interface {
 	Error(msg string)
 	Warn(msg string)
 	Debug(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+	WarnContext(ctx context.Context, msg string)
+	DebugContext(ctx context.Context, msg string)
 }
 
 //go:generate mockery --name=Storage
 type Storage interface {
-	CreateEvent(ctx context.Context, event storage.Event) error
-	UpdateEvent(ctx context.Context, id string, event storage.Event) error
-	DeleteEvent(ctx context.Context, event storage.Event) error
+	GetEvent(ctx context.Context, id string) (*storage.Event, error)
+	// CreateEvent, UpdateEvent, DeleteEvent and RestoreEvent record the audit entry, when it is
+	// given, atomically with the change.
+	CreateEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error
+	UpdateEvent(ctx context.Context, id string, event storage.Event, audit *storage.AuditEntry) error
+	DeleteEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error
 	ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error)
 	ListEventsForWeek(ctx context.Context, date time.Time) ([]*storage.Event, error)
 	ListEventsForMonth(ctx context.Context, date time.Time) ([]*storage.Event, error)
 	ListEventsForNotify(ctx context.Context, date time.Time) ([]*storage.Event, error)
+	ListEventsNear(
+		ctx context.Context, center storage.Point, radius float64, from, to time.Time,
+	) ([]*storage.Event, error)
 	DeleteOldEvents(ctx context.Context, date time.Time) error
+	ListDeletedEvents(ctx context.Context) ([]*storage.Event, error)
+	RestoreEvent(ctx context.Context, id string, audit *storage.AuditEntry) error
+	PurgeDeletedEvents(ctx context.Context, before time.Time) error
+	ListAuditEntries(ctx context.Context, eventID string) ([]*storage.AuditEntry, error)
+	ListTags(ctx context.Context, prefix string) ([]*storage.TagUsage, error)
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) error
+	GetAttachment(ctx context.Context, id string) (*storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]*storage.Attachment, error)
+	ListOrphanedAttachments(ctx context.Context) ([]*storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	GetPreferences(ctx context.Context, userID string) (*storage.Preferences, error)
+	SetPreferences(ctx context.Context, preferences storage.Preferences) error
+	ListPreferences(ctx context.Context, delivery string) ([]*storage.Preferences, error)
+	DeletePreferences(ctx context.Context, userID string) error
+	SetOptOut(ctx context.Context, optOut storage.OptOut) error
+	DeleteOptOut(ctx context.Context, eventID, userID string) error
+	ListOptOuts(ctx context.Context, userID string) ([]*storage.OptOut, error)
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) error
+	UpdateCalendar(ctx context.Context, calendar storage.Calendar) error
+	DeleteCalendar(ctx context.Context, id string) error
+	GetCalendar(ctx context.Context, id string) (*storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]*storage.Calendar, error)
+	ListCalendarEvents(ctx context.Context, calendarID string) ([]*storage.Event, error)
+	SetShare(ctx context.Context, share storage.Share) error
+	DeleteShare(ctx context.Context, calendarID, userID string) error
+	ListShares(ctx context.Context, calendarID string) ([]*storage.Share, error)
+	ApplyBatch(ctx context.Context, ops []storage.Operation, atomic bool) ([]error, error)
+	Ping(ctx context.Context) error
 	Close(ctx context.Context) error
 }
 
@@ -48,10 +101,21 @@ func New(logger Logger, storage Storage) *App {
 	return &App{
 		logger:  logger,
 		storage: storage,
+		bus:     NewBus(defaultHistorySize),
 	}
 }
 
-func (a *App) CreateEvent(ctx context.Context, id string, title string) error {
+// CreateEvent creates an event in the calendar, an empty calendarID leaves the event unassigned.
+func (a *App) CreateEvent(ctx context.Context, id, title, calendarID string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.CreateEvent", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, calendarID, PermissionWrite); err != nil {
+		return err
+	}
+
 	event := storage.Event{
 		ID:        id,
 		Title:     title,
@@ -59,109 +123,644 @@ func (a *App) CreateEvent(ctx context.Context, id string, title string) error {
 		EndDate:   time.Now().Add(time.Hour * 24),
 	}
 
-	return a.storage.CreateEvent(ctx, event)
+	if calendarID != "" {
+		event.CalendarID = sql.NullString{String: calendarID, Valid: true}
+	}
+
+	audit, err := auditEntry(ctx, id, ActionCreate, nil, &event)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.CreateEvent(ctx, event, audit); err != nil {
+		return err
+	}
+
+	a.bus.Publish(ChangeCreated, toEvent(&event), event.StartDate)
+	a.logger.DebugContext(ctx, "event created")
+
+	return nil
 }
 
-func (a *App) UpdateEvent(ctx context.Context, id string, domain Event) error {
-	event := storage.Event{
-		ID:    id,
-		Title: domain.Title,
+func (a *App) GetEvent(ctx context.Context, id string) (_ Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEvent", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	event, err := a.liveEvent(ctx, id)
+	if err != nil {
+		return Event{}, fmt.Errorf("%s: %w", id, err)
 	}
 
-	if domain.NotifyDays > 0 {
-		event.NotifyDays = sql.NullInt32{Int32: domain.NotifyDays, Valid: true}
+	permission, err := a.newAccessChecker(ctx).require(ctx, event.CalendarID.String, PermissionFreeBusy)
+	if err != nil {
+		return Event{}, err
 	}
 
-	if domain.StartDate != "" {
-		startDate, err := time.Parse("2006-01-02 15:04", domain.StartDate)
-		if err != nil {
+	if permission == PermissionFreeBusy {
+		return redact(toEvent(event)), nil
+	}
+
+	return toEvent(event), nil
+}
+
+// UpdateEvent merges non-empty fields of domain into the stored event.
+func (a *App) UpdateEvent(ctx context.Context, id string, domain Event) (err error) {
+	ctx, span := tracer.Start(ctx, "App.UpdateEvent", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	patch, err := toStorageEvent(id, domain)
+	if err != nil {
+		return err
+	}
+
+	if domain.UserID != "" {
+		ctx = logger.ContextWith(ctx, "user_id", domain.UserID)
+	}
+
+	before, err := a.liveEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	checker := a.newAccessChecker(ctx)
+
+	if _, err = checker.require(ctx, before.CalendarID.String, PermissionWrite); err != nil {
+		return err
+	}
+
+	event := mergeEvent(*before, patch)
+
+	if event.CalendarID != before.CalendarID {
+		if _, err = checker.require(ctx, event.CalendarID.String, PermissionWrite); err != nil {
 			return err
 		}
+	}
 
-		event.StartDate = startDate
+	audit, err := auditEntry(ctx, id, ActionUpdate, before, &event)
+	if err != nil {
+		return err
 	}
 
-	if domain.EndDate != "" {
-		endDate, err := time.Parse("2006-01-02 15:04", domain.EndDate)
+	if err = a.storage.UpdateEvent(ctx, id, event, audit); err != nil {
+		return err
+	}
+
+	a.bus.Publish(ChangeUpdated, toEvent(&event), event.StartDate)
+	a.logger.DebugContext(ctx, "event updated")
+
+	return nil
+}
+
+// PutEvent stores domain as a whole, creating the event if it does not exist or replacing all its
+// fields otherwise. It reports whether the event was created.
+func (a *App) PutEvent(ctx context.Context, domain Event) (created bool, err error) {
+	ctx, span := tracer.Start(ctx, "App.PutEvent", trace.WithAttributes(attribute.String("event.id", domain.ID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", domain.ID)
+
+	if domain.ID == "" {
+		return false, ErrEmptyEventID
+	}
+
+	event, err := toStorageEvent(domain.ID, domain)
+	if err != nil {
+		return false, err
+	}
+
+	checker := a.newAccessChecker(ctx)
+
+	if _, err = checker.require(ctx, event.CalendarID.String, PermissionWrite); err != nil {
+		return false, err
+	}
+
+	before, err := a.liveEvent(ctx, domain.ID)
+
+	switch {
+	case errors.Is(err, ErrEventNotFound):
+		audit, err := auditEntry(ctx, event.ID, ActionCreate, nil, &event)
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		event.EndDate = endDate
+		if err = a.storage.CreateEvent(ctx, event, audit); err != nil {
+			return false, err
+		}
+
+		a.bus.Publish(ChangeCreated, toEvent(&event), event.StartDate)
+		a.logger.DebugContext(ctx, "event created")
+
+		return true, nil
+	case err != nil:
+		return false, err
 	}
 
-	if domain.UserID != "" {
-		event.UserID = sql.NullString{String: domain.UserID, Valid: true}
+	if before.CalendarID != event.CalendarID {
+		if _, err = checker.require(ctx, before.CalendarID.String, PermissionWrite); err != nil {
+			return false, err
+		}
 	}
 
-	if domain.Description != "" {
-		event.Description = sql.NullString{String: domain.Description, Valid: true}
+	audit, err := auditEntry(ctx, event.ID, ActionUpdate, before, &event)
+	if err != nil {
+		return false, err
 	}
 
-	return a.storage.UpdateEvent(ctx, id, event)
+	if err = a.storage.UpdateEvent(ctx, event.ID, event, audit); err != nil {
+		return false, err
+	}
+
+	a.bus.Publish(ChangeUpdated, toEvent(&event), event.StartDate)
+	a.logger.DebugContext(ctx, "event replaced")
+
+	return false, nil
 }
 
-func (a *App) DeleteEvent(ctx context.Context, id string) error {
-	return a.storage.DeleteEvent(ctx, storage.Event{ID: id})
+func (a *App) DeleteEvent(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteEvent", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	before, err := a.liveEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, before.CalendarID.String, PermissionWrite); err != nil {
+		return err
+	}
+
+	audit, err := auditEntry(ctx, id, ActionDelete, before, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.DeleteEvent(ctx, storage.Event{ID: id}, audit); err != nil {
+		return err
+	}
+
+	a.bus.Publish(ChangeDeleted, changeRef(before), time.Time{})
+	a.logger.DebugContext(ctx, "event deleted")
+
+	return nil
 }
 
-func (a *App) ListEvents(ctx context.Context, date, period string) ([]Event, error) {
-	parsedDate, err := time.Parse("2006-01-02", date)
+// ListTrash returns deleted events which are not purged yet, the most recently deleted first.
+func (a *App) ListTrash(ctx context.Context) (_ []Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListTrash")
+	defer func() { tracing.Finish(span, err) }()
+
+	events, err := a.storage.ListDeletedEvents(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var events []*storage.Event
+	checker := a.newAccessChecker(ctx)
+	result := make([]Event, 0, len(events))
 
-	switch period {
-	case PeriodDay:
-		events, err = a.storage.ListEventsForDay(ctx, parsedDate)
+	for _, event := range events {
+		permission, err := checker.permission(ctx, event.CalendarID.String)
 		if err != nil {
 			return nil, err
 		}
-	case PeriodWeek:
-		events, err = a.storage.ListEventsForWeek(ctx, parsedDate)
+
+		if allows(permission, PermissionRead) {
+			result = append(result, toEvent(event))
+		}
+	}
+
+	a.logger.DebugContext(ctx, fmt.Sprintf("listed %d events in trash", len(result)))
+
+	return result, nil
+}
+
+func (a *App) RestoreEvent(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.RestoreEvent", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	event, err := a.storage.GetEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, event.CalendarID.String, PermissionWrite); err != nil {
+		return err
+	}
+
+	restored := *event
+	restored.DeletedAt = sql.NullTime{}
+
+	audit, err := auditEntry(ctx, id, ActionRestore, nil, &restored)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.RestoreEvent(ctx, id, audit); err != nil {
+		return err
+	}
+
+	a.bus.Publish(ChangeRestored, changeRef(event), time.Time{})
+	a.logger.DebugContext(ctx, "event restored")
+
+	return nil
+}
+
+// ListEvents returns events of the period visible to the actor and matching the filter.
+// Events of calendars shared as free-busy have their details hidden, so they never match
+// a category or tag filter.
+func (a *App) ListEvents(ctx context.Context, date, period string, filter ListFilter) (_ []Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListEvents", trace.WithAttributes(
+		attribute.String("date", date),
+		attribute.String("period", period),
+		attribute.String("calendar.id", filter.CalendarID),
+		attribute.String("category", filter.Category),
+		attribute.StringSlice("tags", filter.Tags),
+	))
+	defer func() { tracing.Finish(span, err) }()
+
+	parsedDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := a.newAccessChecker(ctx)
+	calendarID := filter.CalendarID
+
+	if calendarID != "" {
+		if _, err = checker.require(ctx, calendarID, PermissionFreeBusy); err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := a.listPeriod(ctx, parsedDate, period, filter.Near)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, 0, len(events))
+
+	for _, event := range events {
+		if calendarID != "" && event.CalendarID.String != calendarID {
+			continue
+		}
+
+		permission, err := checker.permission(ctx, event.CalendarID.String)
 		if err != nil {
 			return nil, err
 		}
-	case PeriodMonth:
-		events, err = a.storage.ListEventsForMonth(ctx, parsedDate)
+
+		var listed Event
+
+		switch {
+		case allows(permission, PermissionRead):
+			listed = toEvent(event)
+		case permission == PermissionFreeBusy:
+			listed = redact(toEvent(event))
+		default:
+			continue
+		}
+
+		if filter.matches(listed) {
+			result = append(result, listed)
+		}
+	}
+
+	a.logger.DebugContext(ctx, fmt.Sprintf("listed %d events for %s %s", len(result), period, date))
+
+	return result, nil
+}
+
+// listPeriod returns the live events of the period, only the ones in the area if it is set.
+func (a *App) listPeriod(ctx context.Context, date time.Time, period string, near *Area) ([]*storage.Event, error) {
+	if near != nil {
+		if err := validateArea(*near); err != nil {
+			return nil, err
+		}
+
+		from, to, err := periodRange(date, period)
 		if err != nil {
 			return nil, err
 		}
+
+		return a.storage.ListEventsNear(ctx, toStoragePoint(near.Center), near.Radius, from, to)
+	}
+
+	switch period {
+	case PeriodDay:
+		return a.storage.ListEventsForDay(ctx, date)
+	case PeriodWeek:
+		return a.storage.ListEventsForWeek(ctx, date)
+	case PeriodMonth:
+		return a.storage.ListEventsForMonth(ctx, date)
 	default:
 		return nil, fmt.Errorf("%s: %w", period, ErrInvalidPeriod)
 	}
+}
 
-	result := make([]Event, 0, len(events))
-	for _, event := range events {
-		var (
-			description, userID string
-			notifyDays          int32
-		)
+func periodRange(date time.Time, period string) (from, to time.Time, err error) {
+	switch period {
+	case PeriodDay:
+		from, to = storage.DayRange(date)
+	case PeriodWeek:
+		from, to = storage.WeekRange(date)
+	case PeriodMonth:
+		from, to = storage.MonthRange(date)
+	default:
+		err = fmt.Errorf("%s: %w", period, ErrInvalidPeriod)
+	}
 
-		if event.Description.Valid {
-			description = event.Description.String
+	return from, to, err
+}
+
+// WatchEvents streams changes of events matching filter and visible to the actor until ctx is done.
+// Changes carrying no user or date (e.g. deletions) are not filtered out. Permissions are resolved
+// once per calendar, so a share revoked during the stream takes effect on reconnect.
+func (a *App) WatchEvents(ctx context.Context, filter WatchFilter) (<-chan Change, error) {
+	var from, to time.Time
+
+	if filter.FromDate != "" {
+		parsed, err := time.Parse("2006-01-02", filter.FromDate)
+		if err != nil {
+			return nil, err
 		}
 
-		if event.UserID.Valid {
-			userID = event.UserID.String
+		from = parsed
+	}
+
+	if filter.ToDate != "" {
+		parsed, err := time.Parse("2006-01-02", filter.ToDate)
+		if err != nil {
+			return nil, err
 		}
 
-		if event.NotifyDays.Valid {
-			notifyDays = event.NotifyDays.Int32
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	match := func(change Change) bool {
+		if filter.UserID != "" && change.Event.UserID != "" && change.Event.UserID != filter.UserID {
+			return false
+		}
+
+		if change.start.IsZero() {
+			return true
+		}
+
+		if !from.IsZero() && change.start.Before(from) {
+			return false
 		}
 
-		result = append(result, Event{
-			ID:          event.ID,
-			Title:       event.Title,
-			StartDate:   event.StartDate.Format("2006-01-02 15:04"),
-			EndDate:     event.EndDate.Format("2006-01-02 15:04"),
-			Description: description,
-			UserID:      userID,
-			NotifyDays:  notifyDays,
-		})
+		return to.IsZero() || change.start.Before(to)
 	}
 
-	return result, nil
+	checker := a.newAccessChecker(ctx)
+
+	changes, cancel, err := a.bus.Subscribe(filter.Epoch, filter.FromSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Change)
+
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change, ok := <-changes:
+				if !ok {
+					return
+				}
+
+				if !match(change) {
+					continue
+				}
+
+				permission, err := checker.permission(ctx, change.Event.CalendarID)
+				if err != nil {
+					a.logger.ErrorContext(ctx, "failed to check calendar permission: "+err.Error())
+					continue
+				}
+
+				switch {
+				case allows(permission, PermissionRead):
+				case permission == PermissionFreeBusy:
+					change.Event = redact(change.Event)
+				default:
+					continue
+				}
+
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// liveEvent returns the event unless it does not exist or is in the trash.
+func (a *App) liveEvent(ctx context.Context, id string) (*storage.Event, error) {
+	event, err := a.storage.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.DeletedAt.Valid {
+		return nil, ErrEventNotFound
+	}
+
+	return event, nil
+}
+
+func toStorageEvent(id string, domain Event) (storage.Event, error) {
+	event := storage.Event{
+		ID:    id,
+		Title: domain.Title,
+	}
+
+	if domain.NotifyDays > 0 {
+		event.NotifyDays = sql.NullInt32{Int32: domain.NotifyDays, Valid: true}
+	}
+
+	if domain.StartDate != "" {
+		startDate, err := time.Parse("2006-01-02 15:04", domain.StartDate)
+		if err != nil {
+			return storage.Event{}, err
+		}
+
+		event.StartDate = startDate
+	}
+
+	if domain.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02 15:04", domain.EndDate)
+		if err != nil {
+			return storage.Event{}, err
+		}
+
+		event.EndDate = endDate
+	}
+
+	if domain.UserID != "" {
+		event.UserID = sql.NullString{String: domain.UserID, Valid: true}
+	}
+
+	if domain.Description != "" {
+		event.Description = sql.NullString{String: domain.Description, Valid: true}
+	}
+
+	if domain.CalendarID != "" {
+		event.CalendarID = sql.NullString{String: domain.CalendarID, Valid: true}
+	}
+
+	category, err := normalizeCategory(domain.Category)
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	if category != "" {
+		event.Category = sql.NullString{String: category, Valid: true}
+	}
+
+	if domain.Color != "" {
+		if !colorRe.MatchString(domain.Color) {
+			return storage.Event{}, fmt.Errorf("%s: %w", domain.Color, ErrInvalidColor)
+		}
+
+		event.Color = sql.NullString{String: domain.Color, Valid: true}
+	}
+
+	if event.Tags, err = toStorageTags(domain.Tags); err != nil {
+		return storage.Event{}, err
+	}
+
+	if err = setLocation(&event, domain.Location); err != nil {
+		return storage.Event{}, err
+	}
+
+	if domain.MeetingURL != "" {
+		if err = validateMeetingURL(domain.MeetingURL); err != nil {
+			return storage.Event{}, err
+		}
+
+		event.MeetingURL = sql.NullString{String: domain.MeetingURL, Valid: true}
+	}
+
+	return event, nil
+}
+
+// mergeEvent returns existing with the fields set in patch overwritten.
+func mergeEvent(existing, patch storage.Event) storage.Event {
+	merged := existing
+
+	if patch.Title != "" {
+		merged.Title = patch.Title
+	}
+
+	if !patch.StartDate.IsZero() {
+		merged.StartDate = patch.StartDate
+	}
+
+	if !patch.EndDate.IsZero() {
+		merged.EndDate = patch.EndDate
+	}
+
+	if patch.Description.Valid {
+		merged.Description = patch.Description
+	}
+
+	if patch.UserID.Valid {
+		merged.UserID = patch.UserID
+	}
+
+	if patch.NotifyDays.Valid {
+		merged.NotifyDays = patch.NotifyDays
+	}
+
+	if patch.CalendarID.Valid {
+		merged.CalendarID = patch.CalendarID
+	}
+
+	if patch.Category.Valid {
+		merged.Category = patch.Category
+	}
+
+	if patch.Color.Valid {
+		merged.Color = patch.Color
+	}
+
+	if patch.Tags != nil {
+		merged.Tags = patch.Tags
+	}
+
+	if patch.Address.Valid {
+		merged.Address = patch.Address
+	}
+
+	if patch.Latitude.Valid {
+		merged.Latitude = patch.Latitude
+		merged.Longitude = patch.Longitude
+	}
+
+	if patch.MeetingURL.Valid {
+		merged.MeetingURL = patch.MeetingURL
+	}
+
+	return merged
+}
+
+// changeRef is the event of a delete or restore change. Only the ID is sent, the calendar and
+// the owner stay so that WatchEvents shows the change only to those who may read the event.
+func changeRef(event *storage.Event) Event {
+	return Event{ID: event.ID, UserID: event.UserID.String, CalendarID: event.CalendarID.String}
+}
+
+func toEvent(event *storage.Event) Event {
+	var (
+		description, userID, deletedAt string
+		notifyDays                     int32
+	)
+
+	if event.Description.Valid {
+		description = event.Description.String
+	}
+
+	if event.UserID.Valid {
+		userID = event.UserID.String
+	}
+
+	if event.NotifyDays.Valid {
+		notifyDays = event.NotifyDays.Int32
+	}
+
+	if event.DeletedAt.Valid {
+		deletedAt = event.DeletedAt.Time.Format("2006-01-02 15:04")
+	}
+
+	return Event{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartDate:   event.StartDate.Format("2006-01-02 15:04"),
+		EndDate:     event.EndDate.Format("2006-01-02 15:04"),
+		Description: description,
+		UserID:      userID,
+		NotifyDays:  notifyDays,
+		CalendarID:  event.CalendarID.String,
+		Category:    event.Category.String,
+		Color:       event.Color.String,
+		Tags:        event.Tags,
+		Location:    toLocation(event),
+		MeetingURL:  event.MeetingURL.String,
+		DeletedAt:   deletedAt,
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,18 +14,61 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestCreateEvent(t *testing.T) {
+// anyCtx matches the context App derives from the caller's one for tracing.
+const anyCtx = mock.Anything
+
+func newLoggerMock() *mocks.Logger {
 	mockLogger := new(mocks.Logger)
+	mockLogger.On("DebugContext", anyCtx, mock.Anything).Maybe()
+
+	return mockLogger
+}
+
+func auditEntryWith(action string) interface{} {
+	return mock.MatchedBy(func(entry *storage.AuditEntry) bool {
+		return entry != nil && entry.Action == action && entry.Actor == anonymousActor
+	})
+}
+
+// operationsWith matches batch operations by type, event and the action of the audit entry.
+func operationsWith(want ...storage.Operation) interface{} {
+	return mock.MatchedBy(func(ops []storage.Operation) bool {
+		if len(ops) != len(want) {
+			return false
+		}
+
+		for i, op := range ops {
+			if op.Type != want[i].Type || !reflect.DeepEqual(op.Event, want[i].Event) ||
+				(op.Audit == nil) != (want[i].Audit == nil) {
+				return false
+			}
+
+			if op.Audit != nil && op.Audit.Action != want[i].Audit.Action {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+func TestCreateEvent(t *testing.T) {
+	mockLogger := newLoggerMock()
 	mockStorage := new(mocks.Storage)
 
 	ctx := context.Background()
 
-	mockStorage.On("CreateEvent", ctx, mock.Anything).Return(nil)
+	mockStorage.On("CreateEvent", anyCtx, mock.Anything, mock.MatchedBy(func(entry *storage.AuditEntry) bool {
+		return entry.EventID == "test uuid" && entry.Actor == "test user" && entry.Action == ActionCreate &&
+			entry.Before == nil && len(entry.After) > 0
+	})).Return(nil)
 
 	app := New(mockLogger, mockStorage)
 
-	err := app.CreateEvent(ctx, "test uuid", "test title")
+	err := app.CreateEvent(WithActor(ctx, "test user"), "test uuid", "test title", "")
 	require.Nil(t, err)
+
+	mockStorage.AssertExpectations(t)
 }
 
 func TestUpdateEvent(t *testing.T) {
@@ -44,7 +88,8 @@ func TestUpdateEvent(t *testing.T) {
 		{
 			name: "update full data in event",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("UpdateEvent", ctx, "test uuid", storage.Event{
+				mock.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+				mock.On("UpdateEvent", anyCtx, "test uuid", storage.Event{
 					ID:        "test uuid",
 					Title:     "test title",
 					StartDate: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
@@ -58,7 +103,7 @@ func TestUpdateEvent(t *testing.T) {
 						Valid:  true,
 					},
 					NotifyDays: sql.NullInt32{Int32: 1, Valid: true},
-				}).Return(nil)
+				}, auditEntryWith(ActionUpdate)).Return(nil)
 			},
 			args: args{
 				event: Event{
@@ -74,14 +119,45 @@ func TestUpdateEvent(t *testing.T) {
 		{
 			name: "event is empty",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("UpdateEvent", ctx, "test uuid", storage.Event{
+				mock.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+				mock.On("UpdateEvent", anyCtx, "test uuid", storage.Event{
 					ID: "test uuid",
-				}).Return(nil)
+				}, auditEntryWith(ActionUpdate)).Return(nil)
 			},
 			args: args{
 				event: Event{},
 			},
 		},
+		{
+			name: "partial update keeps stored fields",
+			mockFunc: func(mock *mocks.Storage) {
+				stored := storage.Event{
+					ID:          "test uuid",
+					Title:       "old title",
+					StartDate:   time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+					EndDate:     time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC),
+					Description: sql.NullString{String: "old description", Valid: true},
+				}
+				mock.On("GetEvent", anyCtx, "test uuid").Return(&stored, nil)
+
+				stored.Title = "new title"
+				mock.On("UpdateEvent", anyCtx, "test uuid", stored, auditEntryWith(ActionUpdate)).Return(nil)
+			},
+			args: args{
+				event: Event{Title: "new title"},
+			},
+		},
+		{
+			name: "event not found",
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "test uuid").Return(nil, storage.ErrEventNotExists)
+			},
+			args: args{
+				event: Event{},
+			},
+			wantErr:       true,
+			expectedError: ErrEventNotFound,
+		},
 		{
 			name:     "wrong start date",
 			mockFunc: func(_ *mocks.Storage) {},
@@ -108,7 +184,7 @@ func TestUpdateEvent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockLogger := new(mocks.Logger)
+			mockLogger := newLoggerMock()
 			mockStorage := new(mocks.Storage)
 
 			tt.mockFunc(mockStorage)
@@ -126,13 +202,38 @@ func TestUpdateEvent(t *testing.T) {
 	}
 }
 
+func TestGetEvent(t *testing.T) {
+	mockLogger := newLoggerMock()
+	mockStorage := new(mocks.Storage)
+
+	ctx := context.Background()
+
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{
+		ID:        "test uuid",
+		Title:     "test title",
+		StartDate: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+	}, nil)
+	mockStorage.On("GetEvent", anyCtx, "missing uuid").Return(nil, storage.ErrEventNotExists)
+
+	app := New(mockLogger, mockStorage)
+
+	event, err := app.GetEvent(ctx, "test uuid")
+	require.NoError(t, err)
+	require.Equal(t, "test title", event.Title)
+	require.Equal(t, "2025-02-01 09:00", event.StartDate)
+
+	_, err = app.GetEvent(ctx, "missing uuid")
+	require.ErrorIs(t, err, ErrEventNotFound)
+}
+
 func TestDeleteEvent(t *testing.T) {
-	mockLogger := new(mocks.Logger)
+	mockLogger := newLoggerMock()
 	mockStorage := new(mocks.Storage)
 
 	ctx := context.Background()
 
-	mockStorage.On("DeleteEvent", ctx, storage.Event{ID: "test uuid"}).Return(nil)
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+	mockStorage.On("DeleteEvent", anyCtx, storage.Event{ID: "test uuid"}, auditEntryWith(ActionDelete)).Return(nil)
 
 	app := New(mockLogger, mockStorage)
 
@@ -140,6 +241,77 @@ func TestDeleteEvent(t *testing.T) {
 	require.Nil(t, err)
 }
 
+func TestListTrash(t *testing.T) {
+	mockLogger := newLoggerMock()
+	mockStorage := new(mocks.Storage)
+
+	ctx := context.Background()
+	deletedAt := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	mockStorage.On("ListDeletedEvents", anyCtx).Return([]*storage.Event{
+		{ID: "test uuid", DeletedAt: sql.NullTime{Time: deletedAt, Valid: true}},
+	}, nil)
+
+	app := New(mockLogger, mockStorage)
+
+	events, err := app.ListTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "2025-02-01 09:00", events[0].DeletedAt)
+}
+
+func TestRestoreEvent(t *testing.T) {
+	mockLogger := newLoggerMock()
+	mockStorage := new(mocks.Storage)
+
+	ctx := context.Background()
+
+	mockStorage.On("RestoreEvent", anyCtx, "test uuid", auditEntryWith(ActionRestore)).Return(nil)
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+	mockStorage.On("GetEvent", anyCtx, "missing uuid").Return(nil, storage.ErrEventNotExists)
+
+	app := New(mockLogger, mockStorage)
+
+	err := app.RestoreEvent(ctx, "test uuid")
+	require.NoError(t, err)
+
+	err = app.RestoreEvent(ctx, "missing uuid")
+	require.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestGetEventHistory(t *testing.T) {
+	mockLogger := newLoggerMock()
+	mockStorage := new(mocks.Storage)
+
+	ctx := context.Background()
+	createdAt := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	mockStorage.On("ListAuditEntries", anyCtx, "test uuid").Return([]*storage.AuditEntry{
+		{
+			ID: 1, EventID: "test uuid", Actor: "test user", Action: ActionCreate,
+			After: []byte(`{"ID":"test uuid","Title":"old"}`), CreatedAt: createdAt,
+		},
+		{
+			ID: 2, EventID: "test uuid", Actor: "test user", Action: ActionUpdate,
+			Before: []byte(`{"ID":"test uuid","Title":"old"}`), After: []byte(`{"ID":"test uuid","Title":"new"}`),
+			CreatedAt: createdAt,
+		},
+	}, nil)
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+
+	app := New(mockLogger, mockStorage)
+
+	entries, err := app.GetEventHistory(ctx, "test uuid")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Nil(t, entries[0].Before)
+	require.Equal(t, "old", entries[0].After.Title)
+	require.Equal(t, "old", entries[1].Before.Title)
+	require.Equal(t, "new", entries[1].After.Title)
+	require.Equal(t, "2025-02-01 09:00:00", entries[1].CreatedAt)
+}
+
 func TestListEvents(t *testing.T) {
 	ctx := context.Background()
 
@@ -160,7 +332,7 @@ func TestListEvents(t *testing.T) {
 		{
 			name: "List events for day",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("ListEventsForDay", ctx,
+				mock.On("ListEventsForDay", anyCtx,
 					time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
 				).Return([]*storage.Event{
 					{
@@ -178,7 +350,7 @@ func TestListEvents(t *testing.T) {
 		{
 			name: "List events for week",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("ListEventsForWeek", ctx,
+				mock.On("ListEventsForWeek", anyCtx,
 					time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
 				).Return([]*storage.Event{
 					{ID: "test uuid", Title: "test title"},
@@ -192,7 +364,7 @@ func TestListEvents(t *testing.T) {
 		{
 			name: "List events for month",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("ListEventsForMonth", ctx,
+				mock.On("ListEventsForMonth", anyCtx,
 					time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
 				).Return([]*storage.Event{
 					{ID: "test uuid", Title: "test title"},
@@ -206,7 +378,7 @@ func TestListEvents(t *testing.T) {
 		{
 			name: "storage error",
 			mockFunc: func(mock *mocks.Storage) {
-				mock.On("ListEventsForDay", ctx,
+				mock.On("ListEventsForDay", anyCtx,
 					time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
 				).Return([]*storage.Event{}, testErr)
 			},
@@ -241,13 +413,13 @@ func TestListEvents(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockLogger := new(mocks.Logger)
+			mockLogger := newLoggerMock()
 			mockStorage := new(mocks.Storage)
 
 			tt.mockFunc(mockStorage)
 
 			app := New(mockLogger, mockStorage)
-			events, err := app.ListEvents(ctx, tt.args.date, tt.args.period)
+			events, err := app.ListEvents(ctx, tt.args.date, tt.args.period, ListFilter{})
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -260,3 +432,188 @@ func TestListEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestWatchEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := new(mocks.Storage)
+	mockStorage.On("CreateEvent", anyCtx, mock.Anything, mock.Anything).Return(nil)
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{ID: "test uuid"}, nil)
+	mockStorage.On("DeleteEvent", anyCtx, storage.Event{ID: "test uuid"}, mock.Anything).Return(nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	today := time.Now().Format("2006-01-02")
+
+	changes, err := app.WatchEvents(ctx, WatchFilter{FromDate: today, ToDate: today})
+	require.NoError(t, err)
+
+	err = app.CreateEvent(ctx, "test uuid", "test title", "")
+	require.NoError(t, err)
+
+	err = app.DeleteEvent(ctx, "test uuid")
+	require.NoError(t, err)
+
+	change := <-changes
+	require.Equal(t, ChangeCreated, change.Type)
+	require.Equal(t, "test title", change.Event.Title)
+
+	change = <-changes
+	require.Equal(t, ChangeDeleted, change.Type)
+
+	_, err = app.WatchEvents(ctx, WatchFilter{FromDate: "wrong date"})
+	require.Error(t, err)
+
+	cancel()
+
+	_, ok := <-changes
+	require.False(t, ok)
+}
+
+func TestBatchEvents(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		ops           []BatchOperation
+		atomic        bool
+		mockFunc      func(mock *mocks.Storage)
+		wantResults   []error
+		expectedError error
+	}{
+		{
+			name:          "empty batch",
+			expectedError: ErrEmptyBatch,
+		},
+		{
+			name:          "too large batch",
+			ops:           make([]BatchOperation, MaxBatchSize+1),
+			expectedError: ErrBatchTooLarge,
+		},
+		{
+			name: "atomic batch aborted on invalid operation",
+			ops: []BatchOperation{
+				{Type: OpDelete, Event: Event{ID: "1"}},
+				{Type: OpUpdate, Event: Event{ID: "2", StartDate: "tomorrow"}},
+			},
+			atomic: true,
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "1").Return(&storage.Event{ID: "1"}, nil)
+			},
+			wantResults:   []error{ErrBatchAborted, errors.New("parse")},
+			expectedError: ErrBatchAborted,
+		},
+		{
+			name: "best-effort batch skips invalid operation",
+			ops: []BatchOperation{
+				{Type: OpDelete, Event: Event{ID: "1"}},
+				{Type: "move", Event: Event{ID: "2"}},
+				{Type: OpCreate, Event: Event{ID: "3", StartDate: "2025-02-01 9:00"}},
+			},
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "1").Return(&storage.Event{ID: "1"}, nil)
+				mock.On("ApplyBatch", anyCtx, operationsWith(
+					storage.Operation{
+						Type: OpDelete, Event: storage.Event{ID: "1"}, Audit: &storage.AuditEntry{Action: ActionDelete},
+					},
+					storage.Operation{Type: OpCreate, Event: storage.Event{
+						ID:        "3",
+						StartDate: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+						EndDate:   time.Date(2025, 2, 2, 9, 0, 0, 0, time.UTC),
+					}, Audit: &storage.AuditEntry{Action: ActionCreate}},
+				), false).Return([]error{nil, storage.ErrEventAlreadyExists}, nil)
+			},
+			wantResults: []error{nil, storage.ErrUnknownOperation, storage.ErrEventAlreadyExists},
+		},
+		{
+			name: "best-effort batch rejects second operation on event",
+			ops: []BatchOperation{
+				{Type: OpCreate, Event: Event{ID: "3", StartDate: "2025-02-01 9:00"}},
+				{Type: OpUpdate, Event: Event{ID: "3", Title: "renamed"}},
+			},
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "3").Return(nil, storage.ErrEventNotExists)
+				mock.On("ApplyBatch", anyCtx, operationsWith(
+					storage.Operation{Type: OpCreate, Event: storage.Event{
+						ID:        "3",
+						StartDate: time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+						EndDate:   time.Date(2025, 2, 2, 9, 0, 0, 0, time.UTC),
+					}, Audit: &storage.AuditEntry{Action: ActionCreate}},
+				), false).Return([]error{nil}, nil)
+			},
+			wantResults: []error{nil, ErrDuplicateEventID},
+		},
+		{
+			name: "atomic batch aborted on second operation on event",
+			ops: []BatchOperation{
+				{Type: OpCreate, Event: Event{ID: "3", StartDate: "2025-02-01 9:00"}},
+				{Type: OpUpdate, Event: Event{ID: "3", Title: "renamed"}},
+			},
+			atomic: true,
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "3").Return(nil, storage.ErrEventNotExists)
+			},
+			wantResults:   []error{ErrBatchAborted, ErrDuplicateEventID},
+			expectedError: ErrBatchAborted,
+		},
+		{
+			name: "atomic batch aborted by storage",
+			ops: []BatchOperation{
+				{Type: OpDelete, Event: Event{ID: "1"}},
+				{Type: OpUpdate, Event: Event{ID: "2"}},
+			},
+			atomic: true,
+			mockFunc: func(mock *mocks.Storage) {
+				mock.On("GetEvent", anyCtx, "1").Return(&storage.Event{ID: "1"}, nil)
+				mock.On("GetEvent", anyCtx, "2").Return(nil, storage.ErrEventNotExists)
+				mock.On("ApplyBatch", anyCtx, operationsWith(
+					storage.Operation{
+						Type: OpDelete, Event: storage.Event{ID: "1"}, Audit: &storage.AuditEntry{Action: ActionDelete},
+					},
+					// The missing event has nothing to audit, ApplyBatch reports it.
+					storage.Operation{Type: OpUpdate, Event: storage.Event{ID: "2"}},
+				), true).
+					Return([]error{storage.ErrBatchAborted, storage.ErrEventNotExists}, storage.ErrBatchAborted)
+			},
+			wantResults:   []error{ErrBatchAborted, storage.ErrEventNotExists},
+			expectedError: ErrBatchAborted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(mocks.Storage)
+			if tt.mockFunc != nil {
+				tt.mockFunc(mockStorage)
+			}
+
+			app := New(newLoggerMock(), mockStorage)
+
+			results, err := app.BatchEvents(ctx, tt.ops, tt.atomic)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Len(t, results, len(tt.wantResults))
+
+			for i, want := range tt.wantResults {
+				require.Equal(t, tt.ops[i].Event.ID, results[i].ID)
+
+				switch {
+				case want == nil:
+					require.NoError(t, results[i].Err)
+				case errors.Is(want, storage.ErrBatchAborted), errors.Is(want, storage.ErrEventNotExists),
+					errors.Is(want, storage.ErrEventAlreadyExists), errors.Is(want, ErrDuplicateEventID):
+					require.ErrorIs(t, results[i].Err, want)
+				default:
+					require.Error(t, results[i].Err)
+				}
+			}
+
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
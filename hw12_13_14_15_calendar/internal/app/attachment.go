@@ -0,0 +1,328 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultMaxAttachmentSize = 10 << 20
+	defaultMaxAttachments    = 20
+	maxAttachmentNameLength  = 255
+	defaultContentType       = "application/octet-stream"
+)
+
+var (
+	ErrAttachmentNotFound  = storage.ErrAttachmentNotExists
+	ErrAttachmentsDisabled = errors.New("attachments are disabled")
+	ErrInvalidAttachment   = errors.New("invalid attachment")
+	ErrAttachmentTooLarge  = errors.New("attachment is too large")
+	ErrTooManyAttachments  = errors.New("too many attachments")
+)
+
+// BlobStore keeps the contents of attachments by key. Get of a missing key fails with
+// blob.ErrBlobNotExists, Delete of a missing key succeeds.
+//
+//go:generate mockery --name=BlobStore
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// AttachmentLimits bound the attachments of an event, zero values keep the defaults.
+type AttachmentLimits struct {
+	MaxSize     int64
+	MaxPerEvent int
+}
+
+// EnableAttachments keeps attachment contents in blobs. Until it is called the attachment
+// methods fail with ErrAttachmentsDisabled.
+func (a *App) EnableAttachments(blobs BlobStore, limits AttachmentLimits) {
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultMaxAttachmentSize
+	}
+
+	if limits.MaxPerEvent <= 0 {
+		limits.MaxPerEvent = defaultMaxAttachments
+	}
+
+	a.blobs = blobs
+	a.attachmentLimits = limits
+}
+
+// AddAttachment stores the content and attaches it to a live event the actor may change.
+func (a *App) AddAttachment(ctx context.Context, eventID string, upload AttachmentUpload) (_ Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "App.AddAttachment", trace.WithAttributes(attribute.String("event.id", eventID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	if a.blobs == nil {
+		return Attachment{}, ErrAttachmentsDisabled
+	}
+
+	attachment, err := a.toStorageAttachment(eventID, upload)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if _, err = a.attachmentEvent(ctx, eventID, PermissionWrite); err != nil {
+		return Attachment{}, err
+	}
+
+	existing, err := a.storage.ListAttachments(ctx, eventID)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if len(existing) >= a.attachmentLimits.MaxPerEvent {
+		return Attachment{}, fmt.Errorf("at most %d per event: %w",
+			a.attachmentLimits.MaxPerEvent, ErrTooManyAttachments)
+	}
+
+	key := attachmentKey(attachment)
+
+	if err = a.blobs.Put(ctx, key, upload.Content, attachment.Size, attachment.ContentType); err != nil {
+		return Attachment{}, err
+	}
+
+	if err = a.storage.CreateAttachment(ctx, attachment); err != nil {
+		if deleteErr := a.blobs.Delete(ctx, key); deleteErr != nil {
+			a.logger.ErrorContext(ctx, "failed to delete blob of rejected attachment: "+deleteErr.Error())
+		}
+
+		return Attachment{}, err
+	}
+
+	a.logger.DebugContext(ctx, "attachment "+attachment.ID+" added")
+
+	return toAttachment(&attachment), nil
+}
+
+// ListAttachments returns the attachments of a live event visible to the actor.
+func (a *App) ListAttachments(ctx context.Context, eventID string) (_ []Attachment, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListAttachments", trace.WithAttributes(attribute.String("event.id", eventID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	if a.blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+
+	if _, err = a.attachmentEvent(ctx, eventID, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	attachments, err := a.storage.ListAttachments(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		result = append(result, toAttachment(attachment))
+	}
+
+	return result, nil
+}
+
+// OpenAttachment returns the attachment and its content, the caller closes the content.
+func (a *App) OpenAttachment(ctx context.Context, eventID, id string) (_ Attachment, _ io.ReadCloser, err error) {
+	ctx, span := tracer.Start(ctx, "App.OpenAttachment", trace.WithAttributes(
+		attribute.String("event.id", eventID), attribute.String("attachment.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	attachment, err := a.eventAttachment(ctx, eventID, id, PermissionRead)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	content, err := a.blobs.Get(ctx, attachmentKey(*attachment))
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	return toAttachment(attachment), content, nil
+}
+
+// DeleteAttachment detaches the attachment and removes its content. A content which fails to be
+// removed is only logged, the attachment is gone for users either way.
+func (a *App) DeleteAttachment(ctx context.Context, eventID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteAttachment", trace.WithAttributes(
+		attribute.String("event.id", eventID), attribute.String("attachment.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	attachment, err := a.eventAttachment(ctx, eventID, id, PermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+
+	if err = a.blobs.Delete(ctx, attachmentKey(*attachment)); err != nil {
+		a.logger.ErrorContext(ctx, "failed to delete blob of attachment "+id+": "+err.Error())
+	}
+
+	a.logger.DebugContext(ctx, "attachment "+id+" deleted")
+
+	return nil
+}
+
+// PurgeAttachments removes the attachments of events which no longer exist, contents first, so
+// a failure leaves the attachment to be purged next time. Attachments of events in the trash are
+// kept, restoring an event brings them back.
+func (a *App) PurgeAttachments(ctx context.Context) (purged int, err error) {
+	ctx, span := tracer.Start(ctx, "App.PurgeAttachments")
+	defer func() { tracing.Finish(span, err) }()
+
+	if a.blobs == nil {
+		return 0, ErrAttachmentsDisabled
+	}
+
+	attachments, err := a.storage.ListOrphanedAttachments(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+
+	for _, attachment := range attachments {
+		if err := a.blobs.Delete(ctx, attachmentKey(*attachment)); err != nil {
+			errs = append(errs, fmt.Errorf("attachment %s: %w", attachment.ID, err))
+			continue
+		}
+
+		err := a.storage.DeleteAttachment(ctx, attachment.ID)
+		if err != nil && !errors.Is(err, storage.ErrAttachmentNotExists) {
+			errs = append(errs, fmt.Errorf("attachment %s: %w", attachment.ID, err))
+			continue
+		}
+
+		purged++
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// attachmentEvent returns the live event if the actor has the permission on its calendar.
+func (a *App) attachmentEvent(ctx context.Context, eventID, required string) (*storage.Event, error) {
+	event, err := a.liveEvent(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", eventID, err)
+	}
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, event.CalendarID.String, required); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// eventAttachment returns the attachment if it belongs to the event and the actor has the
+// permission on the event.
+func (a *App) eventAttachment(ctx context.Context, eventID, id, required string) (*storage.Attachment, error) {
+	if a.blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+
+	if _, err := a.attachmentEvent(ctx, eventID, required); err != nil {
+		return nil, err
+	}
+
+	attachment, err := a.storage.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", id, err)
+	}
+
+	if attachment.EventID != eventID {
+		return nil, fmt.Errorf("%s: %w", id, ErrAttachmentNotFound)
+	}
+
+	return attachment, nil
+}
+
+func (a *App) toStorageAttachment(eventID string, upload AttachmentUpload) (storage.Attachment, error) {
+	name, err := attachmentName(upload.Name)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+
+	contentType := defaultContentType
+
+	if upload.ContentType != "" {
+		mediaType, params, err := mime.ParseMediaType(upload.ContentType)
+		if err != nil {
+			return storage.Attachment{}, fmt.Errorf("content type %q: %w", upload.ContentType, ErrInvalidAttachment)
+		}
+
+		contentType = mime.FormatMediaType(mediaType, params)
+	}
+
+	if upload.Size < 0 || upload.Content == nil {
+		return storage.Attachment{}, fmt.Errorf("no content: %w", ErrInvalidAttachment)
+	}
+
+	if upload.Size > a.attachmentLimits.MaxSize {
+		return storage.Attachment{}, fmt.Errorf("%d bytes, at most %d allowed: %w",
+			upload.Size, a.attachmentLimits.MaxSize, ErrAttachmentTooLarge)
+	}
+
+	return storage.Attachment{
+		ID:          uuid.NewString(),
+		EventID:     eventID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        upload.Size,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// attachmentName keeps the last element of a path some clients send as the file name.
+func attachmentName(name string) (string, error) {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.TrimSpace(name)
+
+	if name == "" || name == "." || name == ".." || !utf8.ValidString(name) ||
+		utf8.RuneCountInString(name) > maxAttachmentNameLength || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("name %q: %w", name, ErrInvalidAttachment)
+	}
+
+	return name, nil
+}
+
+// attachmentKey groups the blobs of an event, the key does not depend on the user given name.
+func attachmentKey(attachment storage.Attachment) string {
+	return "events/" + attachment.EventID + "/" + attachment.ID
+}
+
+func toAttachment(attachment *storage.Attachment) Attachment {
+	return Attachment{
+		ID:          attachment.ID,
+		EventID:     attachment.EventID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
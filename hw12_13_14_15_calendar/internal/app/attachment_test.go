@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app/mocks"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAttachmentApp(t *testing.T) (*App, *mocks.Storage, *mocks.BlobStore) {
+	t.Helper()
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetEvent", anyCtx, "meeting").Return(&storage.Event{
+		ID: "meeting", CalendarID: sql.NullString{String: "work", Valid: true},
+	}, nil)
+
+	mockBlobs := mocks.NewBlobStore(t)
+
+	app := New(newLoggerMock(), mockStorage)
+	app.EnableAttachments(mockBlobs, AttachmentLimits{MaxSize: 10, MaxPerEvent: 2})
+
+	return app, mockStorage, mockBlobs
+}
+
+func upload(name, content string) AttachmentUpload {
+	return AttachmentUpload{Name: name, Size: int64(len(content)), Content: strings.NewReader(content)}
+}
+
+func TestAddAttachment(t *testing.T) {
+	app, mockStorage, mockBlobs := newAttachmentApp(t)
+	ctx := WithActor(context.Background(), "alice")
+
+	mockStorage.On("ListAttachments", anyCtx, "meeting").Return([]*storage.Attachment{{ID: "a1"}}, nil).Once()
+	mockBlobs.On("Put", anyCtx, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "events/meeting/")
+	}), mock.Anything, int64(6), "text/plain; charset=utf-8").Return(nil).Once()
+	mockStorage.On("CreateAttachment", anyCtx, mock.MatchedBy(func(a storage.Attachment) bool {
+		return a.EventID == "meeting" && a.Name == "agenda.txt" && a.Size == 6
+	})).Return(nil).Once()
+
+	added := upload(`C:\Users\alice\agenda.txt`, "agenda")
+	added.ContentType = "Text/Plain; Charset=utf-8"
+
+	attachment, err := app.AddAttachment(ctx, "meeting", added)
+	require.NoError(t, err)
+	require.Equal(t, "agenda.txt", attachment.Name)
+	require.Equal(t, "text/plain; charset=utf-8", attachment.ContentType)
+	require.NotEmpty(t, attachment.ID)
+
+	t.Run("rejected by the storage", func(t *testing.T) {
+		mockStorage.On("ListAttachments", anyCtx, "meeting").Return(nil, nil).Once()
+		mockBlobs.On("Put", anyCtx, mock.Anything, mock.Anything, int64(6), defaultContentType).Return(nil).Once()
+		mockStorage.On("CreateAttachment", anyCtx, mock.Anything).Return(storage.ErrEventNotExists).Once()
+		mockBlobs.On("Delete", anyCtx, mock.Anything).Return(nil).Once()
+
+		_, err := app.AddAttachment(ctx, "meeting", upload("slides", "slides"))
+		require.ErrorIs(t, err, ErrEventNotFound)
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		mockStorage.On("ListAttachments", anyCtx, "meeting").Return([]*storage.Attachment{{}, {}}, nil).Once()
+
+		_, err := app.AddAttachment(ctx, "meeting", upload("slides", "slides"))
+		require.ErrorIs(t, err, ErrTooManyAttachments)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := app.AddAttachment(ctx, "meeting", upload("slides", "more than ten bytes"))
+		require.ErrorIs(t, err, ErrAttachmentTooLarge)
+
+		for _, name := range []string{"", " ", "dir/", "..", "bad\x00name", strings.Repeat("x", 256)} {
+			_, err = app.AddAttachment(ctx, "meeting", upload(name, "x"))
+			require.ErrorIs(t, err, ErrInvalidAttachment, name)
+		}
+
+		bad := upload("slides", "x")
+		bad.ContentType = "not a type"
+		_, err = app.AddAttachment(ctx, "meeting", bad)
+		require.ErrorIs(t, err, ErrInvalidAttachment)
+	})
+
+	t.Run("read only share", func(t *testing.T) {
+		_, err := app.AddAttachment(WithActor(context.Background(), "bob"), "meeting", upload("slides", "x"))
+		require.ErrorIs(t, err, ErrAccessDenied)
+	})
+}
+
+func TestOpenAttachment(t *testing.T) {
+	app, mockStorage, mockBlobs := newAttachmentApp(t)
+
+	mockStorage.On("GetAttachment", anyCtx, "a1").Return(&storage.Attachment{
+		ID: "a1", EventID: "meeting", Name: "agenda.txt", Size: 6,
+	}, nil)
+	mockStorage.On("GetAttachment", anyCtx, "other").Return(&storage.Attachment{ID: "other", EventID: "lunch"}, nil)
+	mockBlobs.On("Get", anyCtx, "events/meeting/a1").Return(io.NopCloser(strings.NewReader("agenda")), nil).Once()
+
+	attachment, content, err := app.OpenAttachment(WithActor(context.Background(), "bob"), "meeting", "a1")
+	require.NoError(t, err)
+	require.Equal(t, "agenda.txt", attachment.Name)
+
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, "agenda", string(data))
+
+	// Attachments are found only through their own event.
+	_, _, err = app.OpenAttachment(WithActor(context.Background(), "bob"), "meeting", "other")
+	require.ErrorIs(t, err, ErrAttachmentNotFound)
+
+	// Free-busy viewers do not see attachments.
+	_, _, err = app.OpenAttachment(WithActor(context.Background(), "carol"), "meeting", "a1")
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	_, err = New(newLoggerMock(), mockStorage).ListAttachments(context.Background(), "meeting")
+	require.ErrorIs(t, err, ErrAttachmentsDisabled)
+}
+
+func TestPurgeAttachments(t *testing.T) {
+	app, mockStorage, mockBlobs := newAttachmentApp(t)
+
+	mockStorage.On("ListOrphanedAttachments", anyCtx).Return([]*storage.Attachment{
+		{ID: "a1", EventID: "gone"},
+		{ID: "a2", EventID: "gone"},
+	}, nil)
+	mockBlobs.On("Delete", anyCtx, "events/gone/a1").Return(nil).Once()
+	mockBlobs.On("Delete", anyCtx, "events/gone/a2").Return(errors.New("unavailable")).Once()
+	mockStorage.On("DeleteAttachment", anyCtx, "a1").Return(nil).Once()
+
+	purged, err := app.PurgeAttachments(context.Background())
+	require.ErrorContains(t, err, "unavailable")
+	require.Equal(t, 1, purged)
+
+	// The attachment whose content was not removed is kept for the next run.
+	mockStorage.AssertNotCalled(t, "DeleteAttachment", anyCtx, "a2")
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+
+	anonymousActor = "anonymous"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the ID of the user performing the request.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	if actor == "" {
+		return anonymousActor
+	}
+
+	return actor
+}
+
+// IsAnonymous reports whether ctx carries no actor.
+func IsAnonymous(ctx context.Context) bool {
+	return ActorFromContext(ctx) == anonymousActor
+}
+
+// GetEventHistory returns audit entries of the event in the order they were recorded.
+func (a *App) GetEventHistory(ctx context.Context, id string) (_ []AuditEntry, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetEventHistory", trace.WithAttributes(attribute.String("event.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	entries, err := a.storage.ListAuditEntries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AuditEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		before, err := decodeSnapshot(entry.Before)
+		if err != nil {
+			return nil, fmt.Errorf("audit entry %d: %w", entry.ID, err)
+		}
+
+		after, err := decodeSnapshot(entry.After)
+		if err != nil {
+			return nil, fmt.Errorf("audit entry %d: %w", entry.ID, err)
+		}
+
+		result = append(result, AuditEntry{
+			ID:        entry.ID,
+			EventID:   entry.EventID,
+			Actor:     entry.Actor,
+			Action:    entry.Action,
+			Before:    before,
+			After:     after,
+			CreatedAt: entry.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, a.historyCalendar(ctx, id, result), PermissionRead); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// historyCalendar returns the calendar of the event, falling back to the latest audit
+// snapshot for events purged from the storage.
+func (a *App) historyCalendar(ctx context.Context, id string, entries []AuditEntry) string {
+	if event, err := a.storage.GetEvent(ctx, id); err == nil {
+		return event.CalendarID.String
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].After != nil {
+			return entries[i].After.CalendarID
+		}
+
+		if entries[i].Before != nil {
+			return entries[i].Before.CalendarID
+		}
+	}
+
+	return ""
+}
+
+// auditEntry describes the change of the event for the audit log. The storage records it
+// together with the change, so there is no change without its entry.
+func auditEntry(ctx context.Context, id, action string, before, after *storage.Event) (*storage.AuditEntry, error) {
+	entry := &storage.AuditEntry{
+		EventID: id,
+		Actor:   ActorFromContext(ctx),
+		Action:  action,
+	}
+
+	var err error
+
+	if before != nil {
+		if entry.Before, err = json.Marshal(toEvent(before)); err != nil {
+			return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+		}
+	}
+
+	if after != nil {
+		if entry.After, err = json.Marshal(toEvent(after)); err != nil {
+			return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+		}
+	}
+
+	return entry, nil
+}
+
+func decodeSnapshot(data []byte) (*Event, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	OpCreate = storage.OpCreate
+	OpUpdate = storage.OpUpdate
+	OpDelete = storage.OpDelete
+
+	MaxBatchSize = 100
+)
+
+var (
+	ErrEmptyBatch       = errors.New("batch is empty")
+	ErrBatchTooLarge    = fmt.Errorf("batch exceeds %d operations", MaxBatchSize)
+	ErrEmptyEventID     = errors.New("event id is empty")
+	ErrDuplicateEventID = errors.New("event id repeats in the batch")
+	ErrBatchAborted     = storage.ErrBatchAborted
+)
+
+// BatchEvents applies ops in a single storage call. In atomic mode either every operation
+// is applied or none is and ErrBatchAborted is returned; otherwise failed operations are
+// skipped. Results are returned in the order of ops either way. Operations are prepared against
+// the stored events, so only the first operation on an event is accepted, later ones fail.
+func (a *App) BatchEvents(ctx context.Context, ops []BatchOperation, atomic bool) (_ []BatchResult, err error) {
+	ctx, span := tracer.Start(ctx, "App.BatchEvents", trace.WithAttributes(
+		attribute.Int("batch.size", len(ops)),
+		attribute.Bool("batch.atomic", atomic),
+	))
+	defer func() { tracing.Finish(span, err) }()
+
+	if len(ops) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	if len(ops) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(ops))
+	storageOps := make([]storage.Operation, 0, len(ops))
+	positions := make([]int, 0, len(ops))
+	checker := a.newAccessChecker(ctx)
+	seen := make(map[string]bool, len(ops))
+
+	for i, op := range ops {
+		results[i].ID = op.Event.ID
+
+		storageOp, opErr := a.prepareOperation(ctx, checker, op)
+		if opErr == nil && seen[op.Event.ID] {
+			opErr = fmt.Errorf("%s: %w", op.Event.ID, ErrDuplicateEventID)
+		}
+
+		seen[op.Event.ID] = true
+
+		if opErr == nil {
+			storageOps = append(storageOps, storageOp)
+			positions = append(positions, i)
+			continue
+		}
+
+		if atomic {
+			for j := range results {
+				results[j] = BatchResult{ID: ops[j].Event.ID, Err: ErrBatchAborted}
+			}
+			results[i].Err = opErr
+
+			return results, ErrBatchAborted
+		}
+
+		results[i].Err = opErr
+	}
+
+	if len(storageOps) == 0 {
+		return results, nil
+	}
+
+	errs, err := a.storage.ApplyBatch(ctx, storageOps, atomic)
+	if err != nil && !errors.Is(err, ErrBatchAborted) {
+		return nil, err
+	}
+
+	for j, opErr := range errs {
+		results[positions[j]].Err = opErr
+	}
+
+	if err != nil {
+		return results, err
+	}
+
+	var applied int
+
+	for j, op := range storageOps {
+		if errs[j] != nil {
+			continue
+		}
+
+		applied++
+
+		switch op.Type {
+		case OpCreate:
+			a.bus.Publish(ChangeCreated, toEvent(&op.Event), op.Event.StartDate)
+		case OpUpdate:
+			a.bus.Publish(ChangeUpdated, toEvent(&op.Event), op.Event.StartDate)
+		case OpDelete:
+			a.bus.Publish(ChangeDeleted, changeRef(&op.Event), time.Time{})
+		}
+	}
+
+	a.logger.DebugContext(ctx, fmt.Sprintf("batch applied %d of %d operations", applied, len(ops)))
+
+	return results, nil
+}
+
+// prepareOperation validates op and checks the actor may apply it. Updates are merged into
+// the stored event the same way UpdateEvent does, the stored event goes to the audit entry.
+// A missing event is not an error here, ApplyBatch reports it itself.
+func (a *App) prepareOperation(
+	ctx context.Context, checker *accessChecker, op BatchOperation,
+) (storage.Operation, error) {
+	storageOp, err := toStorageOperation(op)
+	if err != nil {
+		return storage.Operation{}, err
+	}
+
+	if storageOp.Type == OpCreate {
+		if _, err = checker.require(ctx, storageOp.Event.CalendarID.String, PermissionWrite); err != nil {
+			return storage.Operation{}, err
+		}
+
+		storageOp.Audit, err = auditEntry(ctx, storageOp.Event.ID, ActionCreate, nil, &storageOp.Event)
+
+		return storageOp, err
+	}
+
+	before, err := a.liveEvent(ctx, storageOp.Event.ID)
+	if errors.Is(err, ErrEventNotFound) {
+		return storageOp, nil
+	}
+
+	if err != nil {
+		return storage.Operation{}, err
+	}
+
+	if _, err = checker.require(ctx, before.CalendarID.String, PermissionWrite); err != nil {
+		return storage.Operation{}, err
+	}
+
+	if storageOp.Type == OpDelete {
+		// Storage deletes by ID, the rest of the event is kept for the published change.
+		storageOp.Event = *before
+		storageOp.Audit, err = auditEntry(ctx, storageOp.Event.ID, ActionDelete, before, nil)
+
+		return storageOp, err
+	}
+
+	storageOp.Event = mergeEvent(*before, storageOp.Event)
+
+	if storageOp.Event.CalendarID != before.CalendarID {
+		if _, err = checker.require(ctx, storageOp.Event.CalendarID.String, PermissionWrite); err != nil {
+			return storage.Operation{}, err
+		}
+	}
+
+	storageOp.Audit, err = auditEntry(ctx, storageOp.Event.ID, ActionUpdate, before, &storageOp.Event)
+
+	return storageOp, err
+}
+
+func toStorageOperation(op BatchOperation) (storage.Operation, error) {
+	if op.Event.ID == "" {
+		return storage.Operation{}, ErrEmptyEventID
+	}
+
+	switch op.Type {
+	case OpCreate:
+		event, err := toStorageEvent(op.Event.ID, op.Event)
+		if err != nil {
+			return storage.Operation{}, err
+		}
+
+		if event.StartDate.IsZero() {
+			event.StartDate = time.Now()
+		}
+
+		if event.EndDate.IsZero() {
+			event.EndDate = event.StartDate.Add(time.Hour * 24)
+		}
+
+		return storage.Operation{Type: op.Type, Event: event}, nil
+	case OpUpdate:
+		event, err := toStorageEvent(op.Event.ID, op.Event)
+		if err != nil {
+			return storage.Operation{}, err
+		}
+
+		return storage.Operation{Type: op.Type, Event: event}, nil
+	case OpDelete:
+		return storage.Operation{Type: op.Type, Event: storage.Event{ID: op.Event.ID}}, nil
+	default:
+		return storage.Operation{}, fmt.Errorf("%s: %w", op.Type, storage.ErrUnknownOperation)
+	}
+}
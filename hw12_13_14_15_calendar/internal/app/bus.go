@@ -0,0 +1,132 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ChangeCreated  = "created"
+	ChangeUpdated  = "updated"
+	ChangeDeleted  = "deleted"
+	ChangeRestored = "restored"
+)
+
+const (
+	defaultHistorySize = 1024
+	subscriberBuffer   = 64
+)
+
+// ErrWatchResync is returned for a cursor the bus cannot resume from: it was issued by another
+// process or its changes are no longer kept. The watcher has to reload events and watch from now.
+var ErrWatchResync = errors.New("watch cursor is stale, resync and watch without it")
+
+// Change describes a single modification of an event. Seq grows monotonically within the
+// process identified by Epoch, together they let watchers resume after reconnect.
+type Change struct {
+	Epoch string
+	Seq   uint64
+	Type  string
+	Event Event
+
+	start time.Time
+}
+
+type subscription struct {
+	ch chan Change
+}
+
+// Bus fans out event changes to watchers and keeps a bounded history for resuming.
+type Bus struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []Change
+	size    int
+	subs    map[*subscription]struct{}
+}
+
+func NewBus(historySize int) *Bus {
+	return &Bus{
+		// Sequence numbers restart with the process, the epoch tells them apart.
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		history: make([]Change, 0, historySize),
+		size:    historySize,
+		subs:    make(map[*subscription]struct{}),
+	}
+}
+
+func (b *Bus) Publish(changeType string, event Event, start time.Time) Change {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+
+	change := Change{
+		Epoch: b.epoch,
+		Seq:   b.seq,
+		Type:  changeType,
+		Event: event,
+		start: start,
+	}
+
+	if len(b.history) == b.size {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:b.size-1]
+	}
+	b.history = append(b.history, change)
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- change:
+		default:
+			// Subscriber is too slow, drop it: it will resume from its last seq.
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+
+	return change
+}
+
+// Subscribe returns a channel of changes of the epoch with sequence greater than fromSeq,
+// followed by live changes. Zero fromSeq subscribes to live changes only. ErrWatchResync is
+// returned when the changes after fromSeq cannot be replayed.
+func (b *Bus) Subscribe(epoch string, fromSeq uint64) (<-chan Change, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Change
+	if fromSeq > 0 {
+		if epoch != b.epoch || fromSeq > b.seq || fromSeq+1 < b.history[0].Seq {
+			return nil, nil, ErrWatchResync
+		}
+
+		for _, change := range b.history {
+			if change.Seq > fromSeq {
+				replay = append(replay, change)
+			}
+		}
+	}
+
+	sub := &subscription{ch: make(chan Change, len(replay)+subscriberBuffer)}
+	for _, change := range replay {
+		sub.ch <- change
+	}
+
+	b.subs[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+
+	return sub.ch, cancel, nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("live changes", func(t *testing.T) {
+		bus := NewBus(10)
+
+		changes, cancel, err := bus.Subscribe("", 0)
+		require.NoError(t, err)
+		defer cancel()
+
+		bus.Publish(ChangeCreated, Event{ID: "1"}, time.Time{})
+
+		change := <-changes
+		require.Equal(t, bus.epoch, change.Epoch)
+		require.Equal(t, uint64(1), change.Seq)
+		require.Equal(t, ChangeCreated, change.Type)
+		require.Equal(t, "1", change.Event.ID)
+	})
+
+	t.Run("resume from sequence", func(t *testing.T) {
+		bus := NewBus(2)
+
+		bus.Publish(ChangeCreated, Event{ID: "1"}, time.Time{})
+		bus.Publish(ChangeUpdated, Event{ID: "1"}, time.Time{})
+		bus.Publish(ChangeDeleted, Event{ID: "1"}, time.Time{})
+
+		changes, cancel, err := bus.Subscribe(bus.epoch, 1)
+		require.NoError(t, err)
+		defer cancel()
+
+		require.Equal(t, uint64(2), (<-changes).Seq)
+		require.Equal(t, uint64(3), (<-changes).Seq)
+	})
+
+	t.Run("stale cursor", func(t *testing.T) {
+		bus := NewBus(2)
+
+		for _, changeType := range []string{ChangeCreated, ChangeUpdated, ChangeDeleted, ChangeRestored} {
+			bus.Publish(changeType, Event{ID: "1"}, time.Time{})
+		}
+
+		// A cursor of another process, one ahead of the bus and one whose changes are gone.
+		for _, cursor := range []struct {
+			epoch string
+			seq   uint64
+		}{{"previous", 3}, {bus.epoch, 5}, {bus.epoch, 1}} {
+			_, _, err := bus.Subscribe(cursor.epoch, cursor.seq)
+			require.ErrorIs(t, err, ErrWatchResync, "cursor %s/%d", cursor.epoch, cursor.seq)
+		}
+
+		changes, cancel, err := bus.Subscribe(bus.epoch, 2)
+		require.NoError(t, err)
+		defer cancel()
+
+		require.Equal(t, uint64(3), (<-changes).Seq)
+	})
+
+	t.Run("cancel closes channel", func(t *testing.T) {
+		bus := NewBus(10)
+
+		changes, cancel, err := bus.Subscribe("", 0)
+		require.NoError(t, err)
+		cancel()
+		cancel()
+
+		_, ok := <-changes
+		require.False(t, ok)
+	})
+}
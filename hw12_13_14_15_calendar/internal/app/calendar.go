@@ -0,0 +1,422 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Permissions are ordered: each one includes everything the previous one allows.
+// Free-busy shows only when events take place, their details are hidden.
+const (
+	PermissionFreeBusy = "free_busy"
+	PermissionRead     = "read"
+	PermissionWrite    = "write"
+	PermissionOwner    = "owner"
+
+	defaultTimeZone = "UTC"
+	freeBusyTitle   = "busy"
+)
+
+var (
+	ErrAccessDenied      = errors.New("access denied")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrInvalidColor      = errors.New("invalid color")
+	ErrInvalidTimeZone   = errors.New("invalid time zone")
+	ErrEmptyCalendarName = errors.New("calendar name is empty")
+	ErrEmptyCalendarID   = errors.New("calendar id is empty")
+	ErrCalendarNotFound  = storage.ErrCalendarNotExists
+	ErrShareNotFound     = storage.ErrShareNotExists
+	ErrCalendarNotEmpty  = storage.ErrCalendarNotEmpty
+
+	permissionRanks = map[string]int{
+		PermissionFreeBusy: 1,
+		PermissionRead:     2,
+		PermissionWrite:    3,
+		PermissionOwner:    4,
+	}
+
+	colorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+func (a *App) CreateCalendar(ctx context.Context, calendar Calendar) (err error) {
+	ctx, span := tracer.Start(ctx, "App.CreateCalendar",
+		trace.WithAttributes(attribute.String("calendar.id", calendar.ID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "calendar_id", calendar.ID)
+
+	actor := ActorFromContext(ctx)
+	if actor == anonymousActor {
+		return ErrAccessDenied
+	}
+
+	if calendar.ID == "" {
+		return ErrEmptyCalendarID
+	}
+
+	if calendar.Name == "" {
+		return ErrEmptyCalendarName
+	}
+
+	if calendar.TimeZone == "" {
+		calendar.TimeZone = defaultTimeZone
+	}
+
+	calendar.OwnerID = actor
+
+	stored, err := toStorageCalendar(calendar)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.CreateCalendar(ctx, stored); err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, "calendar created")
+
+	return nil
+}
+
+func (a *App) GetCalendar(ctx context.Context, id string) (_ Calendar, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetCalendar", trace.WithAttributes(attribute.String("calendar.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	permission, err := a.newAccessChecker(ctx).require(ctx, id, PermissionFreeBusy)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	calendar, err := a.storage.GetCalendar(ctx, id)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	return toCalendar(calendar, permission), nil
+}
+
+// UpdateCalendar merges non-empty fields of domain into the stored calendar, only the owner may do it.
+func (a *App) UpdateCalendar(ctx context.Context, id string, domain Calendar) (err error) {
+	ctx, span := tracer.Start(ctx, "App.UpdateCalendar", trace.WithAttributes(attribute.String("calendar.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "calendar_id", id)
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, id, PermissionOwner); err != nil {
+		return err
+	}
+
+	existing, err := a.storage.GetCalendar(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	calendar := toCalendar(existing, PermissionOwner)
+
+	if domain.Name != "" {
+		calendar.Name = domain.Name
+	}
+
+	if domain.Color != "" {
+		calendar.Color = domain.Color
+	}
+
+	if domain.TimeZone != "" {
+		calendar.TimeZone = domain.TimeZone
+	}
+
+	stored, err := toStorageCalendar(calendar)
+	if err != nil {
+		return err
+	}
+
+	if err = a.storage.UpdateCalendar(ctx, stored); err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, "calendar updated")
+
+	return nil
+}
+
+// DeleteCalendar removes an empty calendar, only the owner may do it.
+func (a *App) DeleteCalendar(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeleteCalendar", trace.WithAttributes(attribute.String("calendar.id", id)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "calendar_id", id)
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, id, PermissionOwner); err != nil {
+		return err
+	}
+
+	if err = a.storage.DeleteCalendar(ctx, id); err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, "calendar deleted")
+
+	return nil
+}
+
+// ListCalendars returns calendars the actor owns or which are shared with them.
+func (a *App) ListCalendars(ctx context.Context) (_ []Calendar, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListCalendars")
+	defer func() { tracing.Finish(span, err) }()
+
+	checker := a.newAccessChecker(ctx)
+
+	calendars, err := a.storage.ListCalendars(ctx, checker.actor)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Calendar, 0, len(calendars))
+
+	for _, calendar := range calendars {
+		permission, err := checker.permission(ctx, calendar.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, toCalendar(calendar, permission))
+	}
+
+	return result, nil
+}
+
+// ListCalendarEvents returns all live events of the calendar, with details hidden for free-busy viewers.
+func (a *App) ListCalendarEvents(ctx context.Context, calendarID string) (_ []Event, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListCalendarEvents",
+		trace.WithAttributes(attribute.String("calendar.id", calendarID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	if calendarID == "" {
+		return nil, ErrEmptyCalendarID
+	}
+
+	permission, err := a.newAccessChecker(ctx).require(ctx, calendarID, PermissionFreeBusy)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.ListCalendarEvents(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Event, 0, len(events))
+
+	for _, event := range events {
+		if permission == PermissionFreeBusy {
+			result = append(result, redact(toEvent(event)))
+		} else {
+			result = append(result, toEvent(event))
+		}
+	}
+
+	return result, nil
+}
+
+// ShareCalendar grants userID the permission on the calendar or changes the granted one.
+func (a *App) ShareCalendar(ctx context.Context, calendarID, userID, permission string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.ShareCalendar", trace.WithAttributes(
+		attribute.String("calendar.id", calendarID),
+		attribute.String("permission", permission),
+	))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	if permission == PermissionOwner || permissionRanks[permission] == 0 {
+		return fmt.Errorf("%s: %w", permission, ErrInvalidPermission)
+	}
+
+	checker := a.newAccessChecker(ctx)
+
+	if _, err = checker.require(ctx, calendarID, PermissionOwner); err != nil {
+		return err
+	}
+
+	if userID == "" || userID == checker.actor {
+		return fmt.Errorf("%q: %w", userID, ErrInvalidPermission)
+	}
+
+	err = a.storage.SetShare(ctx, storage.Share{CalendarID: calendarID, UserID: userID, Permission: permission})
+	if err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, fmt.Sprintf("calendar shared with %s: %s", userID, permission))
+
+	return nil
+}
+
+func (a *App) UnshareCalendar(ctx context.Context, calendarID, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.UnshareCalendar",
+		trace.WithAttributes(attribute.String("calendar.id", calendarID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, calendarID, PermissionOwner); err != nil {
+		return err
+	}
+
+	if err = a.storage.DeleteShare(ctx, calendarID, userID); err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, "calendar unshared with "+userID)
+
+	return nil
+}
+
+func (a *App) ListShares(ctx context.Context, calendarID string) (_ []Share, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListShares", trace.WithAttributes(attribute.String("calendar.id", calendarID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, calendarID, PermissionOwner); err != nil {
+		return nil, err
+	}
+
+	shares, err := a.storage.ListShares(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Share, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, Share{CalendarID: share.CalendarID, UserID: share.UserID, Permission: share.Permission})
+	}
+
+	return result, nil
+}
+
+// accessChecker resolves permissions of the actor and caches them for the lifetime of a single call.
+type accessChecker struct {
+	app   *App
+	actor string
+	cache map[string]string
+}
+
+func (a *App) newAccessChecker(ctx context.Context) *accessChecker {
+	return &accessChecker{
+		app:   a,
+		actor: ActorFromContext(ctx),
+		cache: make(map[string]string),
+	}
+}
+
+// permission returns the actor's permission on the calendar or an empty string if there is none.
+// Events without a calendar are not restricted.
+func (c *accessChecker) permission(ctx context.Context, calendarID string) (string, error) {
+	if calendarID == "" {
+		return PermissionOwner, nil
+	}
+
+	if permission, ok := c.cache[calendarID]; ok {
+		return permission, nil
+	}
+
+	var permission string
+
+	calendar, err := c.app.storage.GetCalendar(ctx, calendarID)
+
+	switch {
+	case errors.Is(err, storage.ErrCalendarNotExists):
+	case err != nil:
+		return "", err
+	case calendar.OwnerID == c.actor:
+		permission = PermissionOwner
+	default:
+		shares, err := c.app.storage.ListShares(ctx, calendarID)
+		if err != nil {
+			return "", err
+		}
+
+		for _, share := range shares {
+			if share.UserID == c.actor {
+				permission = share.Permission
+				break
+			}
+		}
+	}
+
+	c.cache[calendarID] = permission
+
+	return permission, nil
+}
+
+// require returns the actor's permission on the calendar if it is at least the required one.
+func (c *accessChecker) require(ctx context.Context, calendarID, required string) (string, error) {
+	permission, err := c.permission(ctx, calendarID)
+	if err != nil {
+		return "", err
+	}
+
+	if !allows(permission, required) {
+		return "", fmt.Errorf("calendar %s: %w", calendarID, ErrAccessDenied)
+	}
+
+	return permission, nil
+}
+
+func allows(granted, required string) bool {
+	return granted != "" && permissionRanks[granted] >= permissionRanks[required]
+}
+
+// redact hides the details of an event shown to a free-busy viewer.
+func redact(event Event) Event {
+	return Event{
+		ID:         event.ID,
+		Title:      freeBusyTitle,
+		StartDate:  event.StartDate,
+		EndDate:    event.EndDate,
+		CalendarID: event.CalendarID,
+	}
+}
+
+func toStorageCalendar(calendar Calendar) (storage.Calendar, error) {
+	if _, err := time.LoadLocation(calendar.TimeZone); err != nil {
+		return storage.Calendar{}, fmt.Errorf("%s: %w", calendar.TimeZone, ErrInvalidTimeZone)
+	}
+
+	stored := storage.Calendar{
+		ID:       calendar.ID,
+		OwnerID:  calendar.OwnerID,
+		Name:     calendar.Name,
+		TimeZone: calendar.TimeZone,
+	}
+
+	if calendar.Color != "" {
+		if !colorRe.MatchString(calendar.Color) {
+			return storage.Calendar{}, fmt.Errorf("%s: %w", calendar.Color, ErrInvalidColor)
+		}
+
+		stored.Color = sql.NullString{String: calendar.Color, Valid: true}
+	}
+
+	return stored, nil
+}
+
+func toCalendar(calendar *storage.Calendar, permission string) Calendar {
+	return Calendar{
+		ID:         calendar.ID,
+		OwnerID:    calendar.OwnerID,
+		Name:       calendar.Name,
+		Color:      calendar.Color.String,
+		TimeZone:   calendar.TimeZone,
+		Permission: permission,
+	}
+}
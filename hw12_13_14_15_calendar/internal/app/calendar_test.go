@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app/mocks"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newCalendarStorageMock() *mocks.Storage {
+	mockStorage := new(mocks.Storage)
+	mockStorage.On("GetCalendar", anyCtx, "work").Return(&storage.Calendar{
+		ID: "work", OwnerID: "alice", Name: "work", TimeZone: "UTC",
+	}, nil)
+	mockStorage.On("GetCalendar", anyCtx, "missing").Return(nil, storage.ErrCalendarNotExists)
+	mockStorage.On("ListShares", anyCtx, "work").Return([]*storage.Share{
+		{CalendarID: "work", UserID: "bob", Permission: PermissionRead},
+		{CalendarID: "work", UserID: "carol", Permission: PermissionFreeBusy},
+	}, nil)
+
+	return mockStorage
+}
+
+func TestCreateCalendar(t *testing.T) {
+	tests := []struct {
+		name          string
+		actor         string
+		calendar      Calendar
+		expectedError error
+	}{
+		{
+			name:     "created with defaults",
+			actor:    "alice",
+			calendar: Calendar{ID: "work", Name: "work", Color: "#FF0000"},
+		},
+		{
+			name:          "anonymous actor",
+			actor:         anonymousActor,
+			calendar:      Calendar{ID: "work", Name: "work"},
+			expectedError: ErrAccessDenied,
+		},
+		{
+			name:          "empty name",
+			actor:         "alice",
+			calendar:      Calendar{ID: "work"},
+			expectedError: ErrEmptyCalendarName,
+		},
+		{
+			name:          "invalid color",
+			actor:         "alice",
+			calendar:      Calendar{ID: "work", Name: "work", Color: "red"},
+			expectedError: ErrInvalidColor,
+		},
+		{
+			name:          "invalid time zone",
+			actor:         "alice",
+			calendar:      Calendar{ID: "work", Name: "work", TimeZone: "Mars/Olympus"},
+			expectedError: ErrInvalidTimeZone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(mocks.Storage)
+			mockStorage.On("CreateCalendar", anyCtx, storage.Calendar{
+				ID: "work", OwnerID: "alice", Name: "work", TimeZone: defaultTimeZone,
+				Color: sql.NullString{String: "#FF0000", Valid: true},
+			}).Return(nil)
+
+			app := New(newLoggerMock(), mockStorage)
+
+			err := app.CreateCalendar(WithActor(context.Background(), tt.actor), tt.calendar)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				mockStorage.AssertNotCalled(t, "CreateCalendar", anyCtx, mock.Anything)
+
+				return
+			}
+
+			require.NoError(t, err)
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCalendarPermissions(t *testing.T) {
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("SetShare", anyCtx, storage.Share{
+		CalendarID: "work", UserID: "dave", Permission: PermissionWrite,
+	}).Return(nil)
+	mockStorage.On("DeleteCalendar", anyCtx, "work").Return(storage.ErrCalendarNotEmpty)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	owner := WithActor(context.Background(), "alice")
+	reader := WithActor(context.Background(), "bob")
+	stranger := WithActor(context.Background(), "eve")
+
+	calendar, err := app.GetCalendar(reader, "work")
+	require.NoError(t, err)
+	require.Equal(t, PermissionRead, calendar.Permission)
+
+	calendar, err = app.GetCalendar(owner, "work")
+	require.NoError(t, err)
+	require.Equal(t, PermissionOwner, calendar.Permission)
+
+	_, err = app.GetCalendar(stranger, "work")
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	_, err = app.GetCalendar(owner, "missing")
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	require.NoError(t, app.ShareCalendar(owner, "work", "dave", PermissionWrite))
+	require.ErrorIs(t, app.ShareCalendar(owner, "work", "dave", PermissionOwner), ErrInvalidPermission)
+	require.ErrorIs(t, app.ShareCalendar(owner, "work", "alice", PermissionRead), ErrInvalidPermission)
+	require.ErrorIs(t, app.ShareCalendar(reader, "work", "dave", PermissionRead), ErrAccessDenied)
+
+	_, err = app.ListShares(reader, "work")
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	require.ErrorIs(t, app.DeleteCalendar(reader, "work"), ErrAccessDenied)
+	require.ErrorIs(t, app.DeleteCalendar(owner, "work"), ErrCalendarNotEmpty)
+}
+
+func TestCalendarEventAccess(t *testing.T) {
+	start := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	event := &storage.Event{
+		ID: "test uuid", Title: "meeting", StartDate: start, EndDate: start.Add(time.Hour),
+		Description: sql.NullString{String: "secret", Valid: true},
+		CalendarID:  sql.NullString{String: "work", Valid: true},
+	}
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(event, nil)
+	mockStorage.On("ListEventsForDay", anyCtx, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)).
+		Return([]*storage.Event{event, {ID: "free uuid", Title: "lunch", StartDate: start}}, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	reader := WithActor(context.Background(), "bob")
+	freeBusy := WithActor(context.Background(), "carol")
+	stranger := WithActor(context.Background(), "eve")
+
+	got, err := app.GetEvent(reader, "test uuid")
+	require.NoError(t, err)
+	require.Equal(t, "meeting", got.Title)
+	require.Equal(t, "secret", got.Description)
+
+	got, err = app.GetEvent(freeBusy, "test uuid")
+	require.NoError(t, err)
+	require.Equal(t, freeBusyTitle, got.Title)
+	require.Empty(t, got.Description)
+	require.Equal(t, "2025-02-01 09:00", got.StartDate)
+
+	_, err = app.GetEvent(stranger, "test uuid")
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	require.ErrorIs(t, app.DeleteEvent(reader, "test uuid"), ErrAccessDenied)
+	require.ErrorIs(t, app.CreateEvent(reader, "new uuid", "title", "work"), ErrAccessDenied)
+
+	events, err := app.ListEvents(stranger, "2025-02-01", "day", ListFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "lunch", events[0].Title)
+
+	events, err = app.ListEvents(freeBusy, "2025-02-01", "day", ListFilter{CalendarID: "work"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, freeBusyTitle, events[0].Title)
+
+	_, err = app.ListEvents(stranger, "2025-02-01", "day", ListFilter{CalendarID: "work"})
+	require.ErrorIs(t, err, ErrAccessDenied)
+}
+
+func TestWatchEventsAccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{
+		ID: "test uuid", CalendarID: sql.NullString{String: "work", Valid: true},
+	}, nil)
+	mockStorage.On("DeleteEvent", anyCtx, storage.Event{ID: "test uuid"}, mock.Anything).Return(nil)
+	mockStorage.On("RestoreEvent", anyCtx, "test uuid", mock.Anything).Return(nil)
+	mockStorage.On("ApplyBatch", anyCtx, mock.Anything, false).Return([]error{nil}, nil)
+	mockStorage.On("CreateEvent", anyCtx, mock.Anything, mock.Anything).Return(nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	reader, err := app.WatchEvents(WithActor(ctx, "bob"), WatchFilter{})
+	require.NoError(t, err)
+
+	stranger, err := app.WatchEvents(WithActor(ctx, "eve"), WatchFilter{})
+	require.NoError(t, err)
+
+	owner := WithActor(ctx, "alice")
+	require.NoError(t, app.DeleteEvent(owner, "test uuid"))
+	require.NoError(t, app.RestoreEvent(owner, "test uuid"))
+	_, err = app.BatchEvents(owner, []BatchOperation{{Type: OpDelete, Event: Event{ID: "test uuid"}}}, false)
+	require.NoError(t, err)
+	require.NoError(t, app.CreateEvent(owner, "public uuid", "title", ""))
+
+	for _, want := range []string{ChangeDeleted, ChangeRestored, ChangeDeleted, ChangeCreated} {
+		change := <-reader
+		require.Equal(t, want, change.Type)
+	}
+
+	// Changes are delivered in order, so eve has skipped everything before the public event.
+	change := <-stranger
+	require.Equal(t, ChangeCreated, change.Type)
+	require.Equal(t, "public uuid", change.Event.ID)
+}
+
+func TestPutEvent(t *testing.T) {
+	start := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	stored := storage.Event{
+		ID: "test uuid", Title: "new", StartDate: start, EndDate: start.Add(time.Hour),
+		CalendarID: sql.NullString{String: "work", Valid: true},
+	}
+	domain := Event{
+		ID: "test uuid", Title: "new", StartDate: "2025-02-01 09:00", EndDate: "2025-02-01 10:00", CalendarID: "work",
+	}
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(nil, storage.ErrEventNotExists).Once()
+	mockStorage.On("CreateEvent", anyCtx, stored, mock.Anything).Return(nil).Once()
+	mockStorage.On("GetEvent", anyCtx, "test uuid").Return(&storage.Event{
+		ID: "test uuid", Title: "old", Description: sql.NullString{String: "dropped", Valid: true},
+		CalendarID: sql.NullString{String: "work", Valid: true},
+	}, nil).Once()
+	mockStorage.On("UpdateEvent", anyCtx, "test uuid", stored, mock.Anything).Return(nil).Once()
+
+	app := New(newLoggerMock(), mockStorage)
+	owner := WithActor(context.Background(), "alice")
+
+	created, err := app.PutEvent(owner, domain)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	created, err = app.PutEvent(owner, domain)
+	require.NoError(t, err)
+	require.False(t, created)
+
+	_, err = app.PutEvent(WithActor(context.Background(), "bob"), domain)
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	_, err = app.PutEvent(owner, Event{})
+	require.ErrorIs(t, err, ErrEmptyEventID)
+
+	mockStorage.AssertNumberOfCalls(t, "CreateEvent", 1)
+	mockStorage.AssertNumberOfCalls(t, "UpdateEvent", 1)
+}
+
+func TestListCalendarEvents(t *testing.T) {
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("ListCalendarEvents", anyCtx, "work").Return([]*storage.Event{
+		{ID: "1", Title: "meeting", CalendarID: sql.NullString{String: "work", Valid: true}},
+	}, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	events, err := app.ListCalendarEvents(WithActor(context.Background(), "bob"), "work")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "meeting", events[0].Title)
+
+	events, err = app.ListCalendarEvents(WithActor(context.Background(), "carol"), "work")
+	require.NoError(t, err)
+	require.Equal(t, freeBusyTitle, events[0].Title)
+
+	_, err = app.ListCalendarEvents(WithActor(context.Background(), "eve"), "work")
+	require.ErrorIs(t, err, ErrAccessDenied)
+}
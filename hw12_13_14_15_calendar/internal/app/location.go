@@ -0,0 +1,125 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	maxAddressLength    = 255
+	maxMeetingURLLength = 2048
+
+	// maxRadius is half of the Earth's circumference, every point is closer than that.
+	maxRadius = math.Pi * storage.EarthRadius
+)
+
+var (
+	ErrInvalidLocation   = errors.New("invalid location")
+	ErrInvalidMeetingURL = errors.New("invalid meeting url")
+	ErrInvalidArea       = errors.New("invalid area")
+)
+
+// String formats the location for people: the address, the coordinates or both.
+func (l Location) String() string {
+	var point string
+	if l.Point != nil {
+		point = fmt.Sprintf("%.6f, %.6f", l.Point.Latitude, l.Point.Longitude)
+	}
+
+	switch {
+	case l.Address == "":
+		return point
+	case point == "":
+		return l.Address
+	default:
+		return l.Address + " (" + point + ")"
+	}
+}
+
+// setLocation validates the location and sets its non-empty parts on the event.
+func setLocation(event *storage.Event, location *Location) error {
+	if location == nil {
+		return nil
+	}
+
+	address := strings.TrimSpace(location.Address)
+	if utf8.RuneCountInString(address) > maxAddressLength {
+		return fmt.Errorf("address is longer than %d characters: %w", maxAddressLength, ErrInvalidLocation)
+	}
+
+	if address != "" {
+		event.Address = sql.NullString{String: address, Valid: true}
+	}
+
+	if location.Point != nil {
+		if err := validatePoint(*location.Point); err != nil {
+			return err
+		}
+
+		event.Latitude = sql.NullFloat64{Float64: location.Point.Latitude, Valid: true}
+		event.Longitude = sql.NullFloat64{Float64: location.Point.Longitude, Valid: true}
+	}
+
+	return nil
+}
+
+func validatePoint(point Point) error {
+	if math.IsNaN(point.Latitude) || point.Latitude < -90 || point.Latitude > 90 ||
+		math.IsNaN(point.Longitude) || point.Longitude < -180 || point.Longitude > 180 {
+		return fmt.Errorf("%g, %g: %w", point.Latitude, point.Longitude, ErrInvalidLocation)
+	}
+
+	return nil
+}
+
+func validateArea(area Area) error {
+	if err := validatePoint(area.Center); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArea, err)
+	}
+
+	if math.IsNaN(area.Radius) || area.Radius <= 0 || area.Radius > maxRadius {
+		return fmt.Errorf("radius %g: %w", area.Radius, ErrInvalidArea)
+	}
+
+	return nil
+}
+
+// validateMeetingURL accepts absolute http and https links.
+func validateMeetingURL(meetingURL string) error {
+	if len(meetingURL) > maxMeetingURLLength {
+		return fmt.Errorf("longer than %d characters: %w", maxMeetingURLLength, ErrInvalidMeetingURL)
+	}
+
+	parsed, err := url.Parse(meetingURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s: %w", meetingURL, ErrInvalidMeetingURL)
+	}
+
+	return nil
+}
+
+func toStoragePoint(point Point) storage.Point {
+	return storage.Point{Latitude: point.Latitude, Longitude: point.Longitude}
+}
+
+// toLocation returns the location of the event or nil if it has none.
+func toLocation(event *storage.Event) *Location {
+	point, hasPoint := event.Point()
+	if !event.Address.Valid && !hasPoint {
+		return nil
+	}
+
+	location := &Location{Address: event.Address.String}
+	if hasPoint {
+		location.Point = &Point{Latitude: point.Latitude, Longitude: point.Longitude}
+	}
+
+	return location
+}
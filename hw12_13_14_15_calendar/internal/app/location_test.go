@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location *Location
+		want     storage.Event
+		wantErr  error
+	}{
+		{name: "nil keeps stored location", location: nil},
+		{
+			name:     "address and point",
+			location: &Location{Address: " Red Square ", Point: &Point{Latitude: 55.75, Longitude: 37.62}},
+			want: storage.Event{
+				Address:   sql.NullString{String: "Red Square", Valid: true},
+				Latitude:  sql.NullFloat64{Float64: 55.75, Valid: true},
+				Longitude: sql.NullFloat64{Float64: 37.62, Valid: true},
+			},
+		},
+		{name: "latitude out of range", location: &Location{Point: &Point{Latitude: 91}}, wantErr: ErrInvalidLocation},
+		{
+			name:     "longitude out of range",
+			location: &Location{Point: &Point{Longitude: -181}},
+			wantErr:  ErrInvalidLocation,
+		},
+		{name: "not a number", location: &Location{Point: &Point{Latitude: math.NaN()}}, wantErr: ErrInvalidLocation},
+		{
+			name:     "address too long",
+			location: &Location{Address: strings.Repeat("x", maxAddressLength+1)},
+			wantErr:  ErrInvalidLocation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event storage.Event
+
+			err := setLocation(&event, tt.location)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, event)
+		})
+	}
+}
+
+func TestValidateMeetingURL(t *testing.T) {
+	require.NoError(t, validateMeetingURL("https://meet.example.com/abc-def"))
+	require.NoError(t, validateMeetingURL("http://localhost:8080/room"))
+
+	invalid := []string{"meet.example.com/abc", "ftp://example.com", "https://", "javascript:alert(1)"}
+	for _, meetingURL := range invalid {
+		require.ErrorIs(t, validateMeetingURL(meetingURL), ErrInvalidMeetingURL, meetingURL)
+	}
+}
+
+func TestListEventsNear(t *testing.T) {
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	center := Point{Latitude: 55.75, Longitude: 37.62}
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("ListEventsNear", anyCtx, toStoragePoint(center), 1000.0, day, day.AddDate(0, 0, 1)).
+		Return([]*storage.Event{{
+			ID: "meeting", StartDate: day.Add(10 * time.Hour), UserID: sql.NullString{String: "bob", Valid: true},
+			Latitude:  sql.NullFloat64{Float64: 55.751, Valid: true},
+			Longitude: sql.NullFloat64{Float64: 37.62, Valid: true},
+		}}, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+	ctx := WithActor(context.Background(), "bob")
+
+	events, err := app.ListEvents(ctx, "2025-03-03", "day", ListFilter{Near: &Area{Center: center, Radius: 1000}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, &Location{Point: &Point{Latitude: 55.751, Longitude: 37.62}}, events[0].Location)
+
+	_, err = app.ListEvents(ctx, "2025-03-03", "day", ListFilter{Near: &Area{Center: center}})
+	require.ErrorIs(t, err, ErrInvalidArea)
+
+	_, err = app.ListEvents(ctx, "2025-03-03", "day", ListFilter{Near: &Area{Center: Point{Latitude: 100}, Radius: 1}})
+	require.ErrorIs(t, err, ErrInvalidArea)
+
+	mockStorage.AssertNumberOfCalls(t, "ListEventsNear", 1)
+}
+
+func TestNewNotification(t *testing.T) {
+	event := &storage.Event{
+		ID:         "meeting",
+		Title:      "Standup",
+		StartDate:  time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC),
+		UserID:     sql.NullString{String: "bob", Valid: true},
+		Address:    sql.NullString{String: "Red Square", Valid: true},
+		Latitude:   sql.NullFloat64{Float64: 55.7558, Valid: true},
+		Longitude:  sql.NullFloat64{Float64: 37.6173, Valid: true},
+		MeetingURL: sql.NullString{String: "https://meet.example.com/abc", Valid: true},
+	}
+
+	notification := NewNotification(event)
+	require.Equal(t, "2025-03-03 10:00", notification.Date)
+	require.Equal(t, "https://meet.example.com/abc", notification.MeetingURL)
+	require.NotNil(t, notification.Location)
+	require.Equal(t, "Red Square (55.755800, 37.617300)", notification.Location.String())
+
+	require.Nil(t, NewNotification(&storage.Event{ID: "online"}).Location)
+	require.Equal(t, "55.000000, 37.000000", Location{Point: &Point{Latitude: 55, Longitude: 37}}.String())
+}
@@ -2,7 +2,11 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // Logger is an autogenerated mock type for the Logger type
 type Logger struct {
@@ -14,21 +18,41 @@ func (_m *Logger) Debug(msg string) {
 	_m.Called(msg)
 }
 
+// DebugContext provides a mock function with given fields: ctx, msg
+func (_m *Logger) DebugContext(ctx context.Context, msg string) {
+	_m.Called(ctx, msg)
+}
+
 // Error provides a mock function with given fields: msg
 func (_m *Logger) Error(msg string) {
 	_m.Called(msg)
 }
 
+// ErrorContext provides a mock function with given fields: ctx, msg
+func (_m *Logger) ErrorContext(ctx context.Context, msg string) {
+	_m.Called(ctx, msg)
+}
+
 // Info provides a mock function with given fields: msg
 func (_m *Logger) Info(msg string) {
 	_m.Called(msg)
 }
 
+// InfoContext provides a mock function with given fields: ctx, msg
+func (_m *Logger) InfoContext(ctx context.Context, msg string) {
+	_m.Called(ctx, msg)
+}
+
 // Warn provides a mock function with given fields: msg
 func (_m *Logger) Warn(msg string) {
 	_m.Called(msg)
 }
 
+// WarnContext provides a mock function with given fields: ctx, msg
+func (_m *Logger) WarnContext(ctx context.Context, msg string) {
+	_m.Called(ctx, msg)
+}
+
 // NewLogger creates a new instance of Logger. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewLogger(t interface {
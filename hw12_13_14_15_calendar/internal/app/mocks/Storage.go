@@ -16,6 +16,36 @@ type Storage struct {
 	mock.Mock
 }
 
+// ApplyBatch provides a mock function with given fields: ctx, ops, atomic
+func (_m *Storage) ApplyBatch(ctx context.Context, ops []storage.Operation, atomic bool) ([]error, error) {
+	ret := _m.Called(ctx, ops, atomic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyBatch")
+	}
+
+	var r0 []error
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.Operation, bool) ([]error, error)); ok {
+		return rf(ctx, ops, atomic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.Operation, bool) []error); ok {
+		r0 = rf(ctx, ops, atomic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]error)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []storage.Operation, bool) error); ok {
+		r1 = rf(ctx, ops, atomic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Close provides a mock function with given fields: ctx
 func (_m *Storage) Close(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -34,17 +64,89 @@ func (_m *Storage) Close(ctx context.Context) error {
 	return r0
 }
 
-// CreateEvent provides a mock function with given fields: ctx, event
-func (_m *Storage) CreateEvent(ctx context.Context, event storage.Event) error {
-	ret := _m.Called(ctx, event)
+// CreateAttachment provides a mock function with given fields: ctx, attachment
+func (_m *Storage) CreateAttachment(ctx context.Context, attachment storage.Attachment) error {
+	ret := _m.Called(ctx, attachment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAttachment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Attachment) error); ok {
+		r0 = rf(ctx, attachment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateCalendar provides a mock function with given fields: ctx, calendar
+func (_m *Storage) CreateCalendar(ctx context.Context, calendar storage.Calendar) error {
+	ret := _m.Called(ctx, calendar)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateCalendar")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Calendar) error); ok {
+		r0 = rf(ctx, calendar)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateEvent provides a mock function with given fields: ctx, event, audit
+func (_m *Storage) CreateEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error {
+	ret := _m.Called(ctx, event, audit)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateEvent")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, storage.Event) error); ok {
-		r0 = rf(ctx, event)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Event, *storage.AuditEntry) error); ok {
+		r0 = rf(ctx, event, audit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteAttachment provides a mock function with given fields: ctx, id
+func (_m *Storage) DeleteAttachment(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAttachment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteCalendar provides a mock function with given fields: ctx, id
+func (_m *Storage) DeleteCalendar(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteCalendar")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -52,17 +154,17 @@ func (_m *Storage) CreateEvent(ctx context.Context, event storage.Event) error {
 	return r0
 }
 
-// DeleteEvent provides a mock function with given fields: ctx, event
-func (_m *Storage) DeleteEvent(ctx context.Context, event storage.Event) error {
-	ret := _m.Called(ctx, event)
+// DeleteEvent provides a mock function with given fields: ctx, event, audit
+func (_m *Storage) DeleteEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error {
+	ret := _m.Called(ctx, event, audit)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteEvent")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, storage.Event) error); ok {
-		r0 = rf(ctx, event)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Event, *storage.AuditEntry) error); ok {
+		r0 = rf(ctx, event, audit)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -88,6 +190,330 @@ func (_m *Storage) DeleteOldEvents(ctx context.Context, date time.Time) error {
 	return r0
 }
 
+// DeleteOptOut provides a mock function with given fields: ctx, eventID, userID
+func (_m *Storage) DeleteOptOut(ctx context.Context, eventID string, userID string) error {
+	ret := _m.Called(ctx, eventID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteOptOut")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, eventID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeletePreferences provides a mock function with given fields: ctx, userID
+func (_m *Storage) DeletePreferences(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePreferences")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteShare provides a mock function with given fields: ctx, calendarID, userID
+func (_m *Storage) DeleteShare(ctx context.Context, calendarID string, userID string) error {
+	ret := _m.Called(ctx, calendarID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteShare")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, calendarID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAttachment provides a mock function with given fields: ctx, id
+func (_m *Storage) GetAttachment(ctx context.Context, id string) (*storage.Attachment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAttachment")
+	}
+
+	var r0 *storage.Attachment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*storage.Attachment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *storage.Attachment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Attachment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCalendar provides a mock function with given fields: ctx, id
+func (_m *Storage) GetCalendar(ctx context.Context, id string) (*storage.Calendar, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCalendar")
+	}
+
+	var r0 *storage.Calendar
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*storage.Calendar, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *storage.Calendar); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Calendar)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEvent provides a mock function with given fields: ctx, id
+func (_m *Storage) GetEvent(ctx context.Context, id string) (*storage.Event, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetEvent")
+	}
+
+	var r0 *storage.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*storage.Event, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *storage.Event); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPreferences provides a mock function with given fields: ctx, userID
+func (_m *Storage) GetPreferences(ctx context.Context, userID string) (*storage.Preferences, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPreferences")
+	}
+
+	var r0 *storage.Preferences
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*storage.Preferences, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *storage.Preferences); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Preferences)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAttachments provides a mock function with given fields: ctx, eventID
+func (_m *Storage) ListAttachments(ctx context.Context, eventID string) ([]*storage.Attachment, error) {
+	ret := _m.Called(ctx, eventID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAttachments")
+	}
+
+	var r0 []*storage.Attachment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.Attachment, error)); ok {
+		return rf(ctx, eventID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.Attachment); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Attachment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAuditEntries provides a mock function with given fields: ctx, eventID
+func (_m *Storage) ListAuditEntries(ctx context.Context, eventID string) ([]*storage.AuditEntry, error) {
+	ret := _m.Called(ctx, eventID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAuditEntries")
+	}
+
+	var r0 []*storage.AuditEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.AuditEntry, error)); ok {
+		return rf(ctx, eventID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.AuditEntry); ok {
+		r0 = rf(ctx, eventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.AuditEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, eventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListCalendarEvents provides a mock function with given fields: ctx, calendarID
+func (_m *Storage) ListCalendarEvents(ctx context.Context, calendarID string) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, calendarID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCalendarEvents")
+	}
+
+	var r0 []*storage.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.Event, error)); ok {
+		return rf(ctx, calendarID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.Event); ok {
+		r0 = rf(ctx, calendarID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, calendarID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListCalendars provides a mock function with given fields: ctx, userID
+func (_m *Storage) ListCalendars(ctx context.Context, userID string) ([]*storage.Calendar, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCalendars")
+	}
+
+	var r0 []*storage.Calendar
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.Calendar, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.Calendar); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Calendar)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeletedEvents provides a mock function with given fields: ctx
+func (_m *Storage) ListDeletedEvents(ctx context.Context) ([]*storage.Event, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDeletedEvents")
+	}
+
+	var r0 []*storage.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*storage.Event, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*storage.Event); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListEventsForDay provides a mock function with given fields: ctx, date
 func (_m *Storage) ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error) {
 	ret := _m.Called(ctx, date)
@@ -208,17 +634,323 @@ func (_m *Storage) ListEventsForWeek(ctx context.Context, date time.Time) ([]*st
 	return r0, r1
 }
 
-// UpdateEvent provides a mock function with given fields: ctx, id, event
-func (_m *Storage) UpdateEvent(ctx context.Context, id string, event storage.Event) error {
-	ret := _m.Called(ctx, id, event)
+// ListEventsNear provides a mock function with given fields: ctx, center, radius, from, to
+func (_m *Storage) ListEventsNear(ctx context.Context, center storage.Point, radius float64, from time.Time, to time.Time) ([]*storage.Event, error) {
+	ret := _m.Called(ctx, center, radius, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListEventsNear")
+	}
+
+	var r0 []*storage.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Point, float64, time.Time, time.Time) ([]*storage.Event, error)); ok {
+		return rf(ctx, center, radius, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Point, float64, time.Time, time.Time) []*storage.Event); ok {
+		r0 = rf(ctx, center, radius, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, storage.Point, float64, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, center, radius, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOptOuts provides a mock function with given fields: ctx, userID
+func (_m *Storage) ListOptOuts(ctx context.Context, userID string) ([]*storage.OptOut, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListOptOuts")
+	}
+
+	var r0 []*storage.OptOut
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.OptOut, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.OptOut); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.OptOut)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrphanedAttachments provides a mock function with given fields: ctx
+func (_m *Storage) ListOrphanedAttachments(ctx context.Context) ([]*storage.Attachment, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListOrphanedAttachments")
+	}
+
+	var r0 []*storage.Attachment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*storage.Attachment, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*storage.Attachment); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Attachment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPreferences provides a mock function with given fields: ctx, delivery
+func (_m *Storage) ListPreferences(ctx context.Context, delivery string) ([]*storage.Preferences, error) {
+	ret := _m.Called(ctx, delivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPreferences")
+	}
+
+	var r0 []*storage.Preferences
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.Preferences, error)); ok {
+		return rf(ctx, delivery)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.Preferences); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Preferences)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, delivery)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListShares provides a mock function with given fields: ctx, calendarID
+func (_m *Storage) ListShares(ctx context.Context, calendarID string) ([]*storage.Share, error) {
+	ret := _m.Called(ctx, calendarID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListShares")
+	}
+
+	var r0 []*storage.Share
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.Share, error)); ok {
+		return rf(ctx, calendarID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.Share); ok {
+		r0 = rf(ctx, calendarID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.Share)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, calendarID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTags provides a mock function with given fields: ctx, prefix
+func (_m *Storage) ListTags(ctx context.Context, prefix string) ([]*storage.TagUsage, error) {
+	ret := _m.Called(ctx, prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTags")
+	}
+
+	var r0 []*storage.TagUsage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*storage.TagUsage, error)); ok {
+		return rf(ctx, prefix)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*storage.TagUsage); ok {
+		r0 = rf(ctx, prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storage.TagUsage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *Storage) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeDeletedEvents provides a mock function with given fields: ctx, before
+func (_m *Storage) PurgeDeletedEvents(ctx context.Context, before time.Time) error {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeletedEvents")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreEvent provides a mock function with given fields: ctx, id, audit
+func (_m *Storage) RestoreEvent(ctx context.Context, id string, audit *storage.AuditEntry) error {
+	ret := _m.Called(ctx, id, audit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreEvent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *storage.AuditEntry) error); ok {
+		r0 = rf(ctx, id, audit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetOptOut provides a mock function with given fields: ctx, optOut
+func (_m *Storage) SetOptOut(ctx context.Context, optOut storage.OptOut) error {
+	ret := _m.Called(ctx, optOut)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetOptOut")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.OptOut) error); ok {
+		r0 = rf(ctx, optOut)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPreferences provides a mock function with given fields: ctx, preferences
+func (_m *Storage) SetPreferences(ctx context.Context, preferences storage.Preferences) error {
+	ret := _m.Called(ctx, preferences)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPreferences")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Preferences) error); ok {
+		r0 = rf(ctx, preferences)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetShare provides a mock function with given fields: ctx, share
+func (_m *Storage) SetShare(ctx context.Context, share storage.Share) error {
+	ret := _m.Called(ctx, share)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetShare")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Share) error); ok {
+		r0 = rf(ctx, share)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateCalendar provides a mock function with given fields: ctx, calendar
+func (_m *Storage) UpdateCalendar(ctx context.Context, calendar storage.Calendar) error {
+	ret := _m.Called(ctx, calendar)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateCalendar")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Calendar) error); ok {
+		r0 = rf(ctx, calendar)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateEvent provides a mock function with given fields: ctx, id, event, audit
+func (_m *Storage) UpdateEvent(ctx context.Context, id string, event storage.Event, audit *storage.AuditEntry) error {
+	ret := _m.Called(ctx, id, event, audit)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateEvent")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, storage.Event) error); ok {
-		r0 = rf(ctx, id, event)
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.Event, *storage.AuditEntry) error); ok {
+		r0 = rf(ctx, id, event, audit)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -1,5 +1,10 @@
 package app
 
+import (
+	"io"
+	"time"
+)
+
 type Event struct {
 	ID          string
 	Title       string
@@ -8,11 +13,146 @@ type Event struct {
 	Description string
 	UserID      string
 	NotifyDays  int32
+	CalendarID  string
+	Category    string
+	Color       string
+	Tags        []string
+	Location    *Location
+	MeetingURL  string
+	DeletedAt   string
+}
+
+// Location is where an event takes place, Point is nil when only the address is known.
+type Location struct {
+	Address string
+	Point   *Point
+}
+
+// Point is a place on the Earth in degrees.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Area is a circle on the Earth, Radius is in meters.
+type Area struct {
+	Center Point
+	Radius float64
+}
+
+// ListFilter narrows ListEvents down to a calendar, a category, events having all the tags and
+// events taking place in the area.
+type ListFilter struct {
+	CalendarID string
+	Category   string
+	Tags       []string
+	Near       *Area
+}
+
+// Attachment is a file attached to an event, the content is read with OpenAttachment.
+type Attachment struct {
+	ID          string
+	EventID     string
+	Name        string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
+}
+
+// AttachmentUpload is a new attachment, Content must provide exactly Size bytes.
+type AttachmentUpload struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Content     io.Reader
+}
+
+// Tag is a tag suggested for autocomplete with the number of visible events having it.
+type Tag struct {
+	Name  string
+	Count int
 }
 
+// Notification is a reminder about an event or, of NotificationDigest type, the agenda of the
+// day in Date. It goes to Address over Channel, a zero DeliverAt means right away.
 type Notification struct {
-	EventID string
-	Title   string
-	Date    string
-	UserID  string
+	Type       string
+	EventID    string
+	Title      string
+	Date       string
+	UserID     string
+	Location   *Location
+	MeetingURL string
+	Channel    string
+	Address    string
+	DeliverAt  time.Time
+	Agenda     []AgendaItem
+}
+
+// AgendaItem is an event of a digest, Start and End are "15:04" times in the time zone of the user.
+type AgendaItem struct {
+	EventID    string
+	Title      string
+	Start      string
+	End        string
+	Location   *Location
+	MeetingURL string
+}
+
+// Preferences tell how the user wants to be notified. Reminders falling between QuietStart and
+// QuietEnd, "15:04" times in TimeZone, are held until the quiet hours end. Users who chose the
+// digest delivery get the agenda of each day at DigestTime instead of reminders.
+type Preferences struct {
+	Channel    string
+	Address    string
+	QuietStart string
+	QuietEnd   string
+	TimeZone   string
+	Delivery   string
+	DigestTime string
+}
+
+type WatchFilter struct {
+	UserID   string
+	FromDate string
+	ToDate   string
+	// Epoch and FromSeq are the cursor of the last change the watcher received.
+	Epoch   string
+	FromSeq uint64
+}
+
+type BatchOperation struct {
+	Type  string
+	Event Event
+}
+
+type BatchResult struct {
+	ID  string
+	Err error
+}
+
+type AuditEntry struct {
+	ID        int64
+	EventID   string
+	Actor     string
+	Action    string
+	Before    *Event
+	After     *Event
+	CreatedAt string
+}
+
+// Calendar groups events of its owner. Permission is the one of the user who requested it.
+type Calendar struct {
+	ID         string
+	OwnerID    string
+	Name       string
+	Color      string
+	TimeZone   string
+	Permission string
+}
+
+type Share struct {
+	CalendarID string
+	UserID     string
+	Permission string
 }
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Notifications queued before digests existed carry no type, they are reminders.
+const (
+	NotificationReminder = "reminder"
+	NotificationDigest   = "digest"
+)
+
+// NewNotification builds the reminder about the event, it tells where the event takes place
+// and how to join it online.
+func NewNotification(event *storage.Event) Notification {
+	return Notification{
+		Type:       NotificationReminder,
+		EventID:    event.ID,
+		Title:      event.Title,
+		Date:       event.StartDate.Format("2006-01-02 15:04"),
+		UserID:     event.UserID.String,
+		Location:   toLocation(event),
+		MeetingURL: event.MeetingURL.String,
+	}
+}
+
+// PrepareDigests builds the agendas due in (from, to]: a user who chose the digest delivery gets
+// the events of the day at the digest time of the day in the time zone of the user. Days without
+// events the user is notified about produce no digest.
+func (a *App) PrepareDigests(ctx context.Context, from, to time.Time) (_ []Notification, err error) {
+	ctx, span := tracer.Start(ctx, "App.PrepareDigests", trace.WithAttributes(
+		attribute.String("from", from.Format(time.RFC3339)), attribute.String("to", to.Format(time.RFC3339))))
+	defer func() { tracing.Finish(span, err) }()
+
+	stored, err := a.storage.ListPreferences(ctx, DeliveryDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users in the same time zone share the day, its events are listed once.
+	days := make(map[int64][]*storage.Event)
+
+	var digests []Notification
+
+	for _, s := range stored {
+		preferences := toPreferences(s)
+
+		at, due := preferences.digestDue(from, to)
+		if !due {
+			continue
+		}
+
+		dayStart, _ := storage.DayRange(at)
+
+		events, ok := days[dayStart.Unix()]
+		if !ok {
+			if events, err = a.storage.ListEventsForDay(ctx, at); err != nil {
+				return nil, fmt.Errorf("user %s: %w", s.UserID, err)
+			}
+
+			days[dayStart.Unix()] = events
+		}
+
+		optOuts, err := a.storage.ListOptOuts(ctx, s.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", s.UserID, err)
+		}
+
+		optedOut := make(map[string]bool, len(optOuts))
+		for _, optOut := range optOuts {
+			optedOut[optOut.EventID] = true
+		}
+
+		var agenda []AgendaItem
+
+		for _, event := range events {
+			if event.UserID.String == s.UserID && !optedOut[event.ID] {
+				agenda = append(agenda, toAgendaItem(event, at.Location()))
+			}
+		}
+
+		if len(agenda) == 0 {
+			continue
+		}
+
+		digest := Notification{
+			Type:    NotificationDigest,
+			Date:    at.Format("2006-01-02"),
+			UserID:  s.UserID,
+			Channel: preferences.Channel,
+			Address: preferences.Address,
+			Agenda:  agenda,
+		}
+
+		if until, quiet := preferences.quietUntil(at); quiet {
+			digest.DeliverAt = until
+		}
+
+		digests = append(digests, digest)
+	}
+
+	return digests, nil
+}
+
+// digestDue returns the last digest time of the user in (from, to].
+func (p Preferences) digestDue(from, to time.Time) (time.Time, bool) {
+	digestTime, err := time.Parse(timeOfDayLayout, p.DigestTime)
+	if err != nil {
+		digestTime, _ = time.Parse(timeOfDayLayout, defaultDigestTime)
+	}
+
+	local := to.In(p.location())
+
+	at := time.Date(local.Year(), local.Month(), local.Day(),
+		digestTime.Hour(), digestTime.Minute(), 0, 0, local.Location())
+	if at.After(to) {
+		at = at.AddDate(0, 0, -1)
+	}
+
+	return at, at.After(from)
+}
+
+func toAgendaItem(event *storage.Event, location *time.Location) AgendaItem {
+	return AgendaItem{
+		EventID:    event.ID,
+		Title:      event.Title,
+		Start:      event.StartDate.In(location).Format(timeOfDayLayout),
+		End:        event.EndDate.In(location).Format(timeOfDayLayout),
+		Location:   toLocation(event),
+		MeetingURL: event.MeetingURL.String,
+	}
+}
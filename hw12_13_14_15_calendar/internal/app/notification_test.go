@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestDue(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	preferences := Preferences{TimeZone: "Europe/Moscow", DigestTime: "08:00"}
+	digestAt := time.Date(2025, 3, 1, 8, 0, 0, 0, moscow)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantDue  bool
+	}{
+		{name: "window ends at digest time", from: digestAt.Add(-time.Minute), to: digestAt, wantDue: true},
+		{
+			name: "window contains digest time",
+			from: digestAt.Add(-time.Minute), to: digestAt.Add(time.Minute), wantDue: true,
+		},
+		{name: "window starts at digest time", from: digestAt, to: digestAt.Add(time.Minute)},
+		{name: "before digest time", from: digestAt.Add(-2 * time.Minute), to: digestAt.Add(-time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, due := preferences.digestDue(tt.from, tt.to)
+			require.Equal(t, tt.wantDue, due)
+
+			if due {
+				require.True(t, digestAt.Equal(at), "at %s, want %s", at, digestAt)
+			}
+		})
+	}
+
+	// A window spanning midnight finds the digest time of the day before.
+	late := Preferences{TimeZone: "UTC", DigestTime: "23:59"}
+	midnight := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	at, due := late.digestDue(midnight.Add(-2*time.Minute), midnight.Add(time.Minute))
+	require.True(t, due)
+	require.Equal(t, time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC), at)
+}
+
+func TestPrepareDigests(t *testing.T) {
+	digestAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	userEvent := func(id, userID string, start time.Time) *storage.Event {
+		return &storage.Event{
+			ID: id, Title: id, StartDate: start, EndDate: start.Add(time.Hour),
+			UserID: sql.NullString{String: userID, Valid: true},
+		}
+	}
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("ListPreferences", anyCtx, DeliveryDigest).Return([]*storage.Preferences{
+		{
+			UserID: "alice", Channel: ChannelEmail, Address: "alice@example.com", TimeZone: "Europe/Moscow",
+			Delivery: DeliveryDigest, DigestTime: "11:00",
+		},
+		{UserID: "bob", Channel: ChannelPush, TimeZone: "UTC", Delivery: DeliveryDigest, DigestTime: "08:00"},
+		{UserID: "carol", Channel: ChannelPush, TimeZone: "UTC", Delivery: DeliveryDigest, DigestTime: "08:00"},
+		{UserID: "dave", Channel: ChannelPush, TimeZone: "UTC", Delivery: DeliveryDigest, DigestTime: "09:00"},
+	}, nil)
+	mockStorage.On("ListEventsForDay", anyCtx, mock.Anything).Return([]*storage.Event{
+		userEvent("standup", "alice", digestAt.Add(time.Hour)),
+		userEvent("muted", "alice", digestAt.Add(2*time.Hour)),
+		userEvent("lunch", "bob", digestAt.Add(4*time.Hour)),
+	}, nil)
+	mockStorage.On("ListOptOuts", anyCtx, "alice").Return([]*storage.OptOut{{EventID: "muted", UserID: "alice"}}, nil)
+	mockStorage.On("ListOptOuts", anyCtx, mock.Anything).Return(nil, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	digests, err := app.PrepareDigests(context.Background(), digestAt.Add(-time.Minute), digestAt)
+	require.NoError(t, err)
+
+	// Carol has no events and Dave's digest is not due yet.
+	require.Equal(t, []Notification{
+		{
+			Type: NotificationDigest, Date: "2025-03-01", UserID: "alice",
+			Channel: ChannelEmail, Address: "alice@example.com",
+			Agenda: []AgendaItem{{EventID: "standup", Title: "standup", Start: "12:00", End: "13:00"}},
+		},
+		{
+			Type: NotificationDigest, Date: "2025-03-01", UserID: "bob", Channel: ChannelPush,
+			Agenda: []AgendaItem{{EventID: "lunch", Title: "lunch", Start: "12:00", End: "13:00"}},
+		},
+	}, digests)
+
+	// Moscow and UTC days differ, each is listed once.
+	mockStorage.AssertNumberOfCalls(t, "ListEventsForDay", 2)
+}
@@ -0,0 +1,401 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Push notifications go to the devices of the user, the other channels need an address.
+const (
+	ChannelPush  = "push"
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+
+	defaultDigestTime = "08:00"
+	timeOfDayLayout   = "15:04"
+)
+
+var (
+	ErrInvalidChannel    = errors.New("invalid channel")
+	ErrInvalidAddress    = errors.New("invalid address")
+	ErrInvalidQuietHours = errors.New("invalid quiet hours")
+	ErrInvalidDelivery   = errors.New("invalid delivery")
+	ErrInvalidDigestTime = errors.New("invalid digest time")
+
+	phoneRe = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// GetPreferences returns the preferences of the actor, the defaults if they were never set.
+func (a *App) GetPreferences(ctx context.Context) (_ Preferences, err error) {
+	ctx, span := tracer.Start(ctx, "App.GetPreferences")
+	defer func() { tracing.Finish(span, err) }()
+
+	if IsAnonymous(ctx) {
+		return Preferences{}, ErrAccessDenied
+	}
+
+	return a.preferences(ctx, ActorFromContext(ctx))
+}
+
+// SetPreferences replaces the preferences of the actor, empty fields take the defaults.
+func (a *App) SetPreferences(ctx context.Context, preferences Preferences) (_ Preferences, err error) {
+	ctx, span := tracer.Start(ctx, "App.SetPreferences")
+	defer func() { tracing.Finish(span, err) }()
+
+	if IsAnonymous(ctx) {
+		return Preferences{}, ErrAccessDenied
+	}
+
+	stored, err := toStoragePreferences(preferences)
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	stored.UserID = ActorFromContext(ctx)
+
+	if err = a.storage.SetPreferences(ctx, stored); err != nil {
+		return Preferences{}, err
+	}
+
+	a.logger.DebugContext(ctx, "preferences set")
+
+	return toPreferences(&stored), nil
+}
+
+// DeletePreferences resets the preferences of the actor to the defaults.
+func (a *App) DeletePreferences(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "App.DeletePreferences")
+	defer func() { tracing.Finish(span, err) }()
+
+	if IsAnonymous(ctx) {
+		return ErrAccessDenied
+	}
+
+	err = a.storage.DeletePreferences(ctx, ActorFromContext(ctx))
+	if err != nil && !errors.Is(err, storage.ErrPreferencesNotExists) {
+		return err
+	}
+
+	return nil
+}
+
+// OptOut stops the reminders about a live event the actor may read.
+func (a *App) OptOut(ctx context.Context, eventID string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.OptOut", trace.WithAttributes(attribute.String("event.id", eventID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	if IsAnonymous(ctx) {
+		return ErrAccessDenied
+	}
+
+	event, err := a.liveEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", eventID, err)
+	}
+
+	if _, err = a.newAccessChecker(ctx).require(ctx, event.CalendarID.String, PermissionRead); err != nil {
+		return err
+	}
+
+	optOut := storage.OptOut{EventID: eventID, UserID: ActorFromContext(ctx)}
+
+	if err = a.storage.SetOptOut(ctx, optOut); err != nil {
+		return err
+	}
+
+	a.logger.DebugContext(ctx, "opted out of reminders")
+
+	return nil
+}
+
+// OptIn brings back the reminders about the event, it does nothing if the actor did not opt out.
+func (a *App) OptIn(ctx context.Context, eventID string) (err error) {
+	ctx, span := tracer.Start(ctx, "App.OptIn", trace.WithAttributes(attribute.String("event.id", eventID)))
+	defer func() { tracing.Finish(span, err) }()
+
+	if IsAnonymous(ctx) {
+		return ErrAccessDenied
+	}
+
+	err = a.storage.DeleteOptOut(ctx, eventID, ActorFromContext(ctx))
+	if err != nil && !errors.Is(err, storage.ErrOptOutNotExists) {
+		return err
+	}
+
+	return nil
+}
+
+// ListOptOuts returns the IDs of the events the actor opted out of.
+func (a *App) ListOptOuts(ctx context.Context) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "App.ListOptOuts")
+	defer func() { tracing.Finish(span, err) }()
+
+	if IsAnonymous(ctx) {
+		return nil, ErrAccessDenied
+	}
+
+	optOuts, err := a.storage.ListOptOuts(ctx, ActorFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	eventIDs := make([]string, 0, len(optOuts))
+	for _, optOut := range optOuts {
+		eventIDs = append(eventIDs, optOut.EventID)
+	}
+
+	return eventIDs, nil
+}
+
+// PrepareNotifications builds the reminders about the events as of now. Events the user opted
+// out of and the events of users who get digests are dropped, the rest are addressed to the
+// preferred channel and held until the end of the quiet hours of the user.
+func (a *App) PrepareNotifications(
+	ctx context.Context, events []*storage.Event, now time.Time,
+) (_ []Notification, err error) {
+	ctx, span := tracer.Start(ctx, "App.PrepareNotifications",
+		trace.WithAttributes(attribute.Int("events", len(events))))
+	defer func() { tracing.Finish(span, err) }()
+
+	type recipient struct {
+		preferences Preferences
+		optedOut    map[string]bool
+	}
+
+	recipients := make(map[string]*recipient)
+	notifications := make([]Notification, 0, len(events))
+
+	for _, event := range events {
+		userID := event.UserID.String
+
+		r, ok := recipients[userID]
+		if !ok {
+			r = &recipient{optedOut: make(map[string]bool)}
+
+			if r.preferences, err = a.preferences(ctx, userID); err != nil {
+				return nil, fmt.Errorf("user %s: %w", userID, err)
+			}
+
+			optOuts, err := a.storage.ListOptOuts(ctx, userID)
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %w", userID, err)
+			}
+
+			for _, optOut := range optOuts {
+				r.optedOut[optOut.EventID] = true
+			}
+
+			recipients[userID] = r
+		}
+
+		if r.optedOut[event.ID] {
+			a.logger.DebugContext(logger.ContextWith(ctx, "event_id", event.ID), "user opted out of reminder")
+			continue
+		}
+
+		if r.preferences.Delivery == DeliveryDigest {
+			continue
+		}
+
+		notification := NewNotification(event)
+		notification.Channel = r.preferences.Channel
+		notification.Address = r.preferences.Address
+
+		if until, quiet := r.preferences.quietUntil(now); quiet {
+			notification.DeliverAt = until
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// preferences returns the stored preferences of the user or the defaults.
+func (a *App) preferences(ctx context.Context, userID string) (Preferences, error) {
+	stored, err := a.storage.GetPreferences(ctx, userID)
+	if errors.Is(err, storage.ErrPreferencesNotExists) {
+		return defaultPreferences(), nil
+	}
+
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	return toPreferences(stored), nil
+}
+
+func defaultPreferences() Preferences {
+	return Preferences{
+		Channel:    ChannelPush,
+		TimeZone:   defaultTimeZone,
+		Delivery:   DeliveryImmediate,
+		DigestTime: defaultDigestTime,
+	}
+}
+
+// quietUntil reports whether t falls in the quiet hours and when they end. Quiet hours ending
+// before they start span midnight.
+func (p Preferences) quietUntil(t time.Time) (time.Time, bool) {
+	if p.QuietStart == "" {
+		return time.Time{}, false
+	}
+
+	location := p.location()
+
+	start, _ := time.Parse(timeOfDayLayout, p.QuietStart)
+	end, _ := time.Parse(timeOfDayLayout, p.QuietEnd)
+
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var quiet bool
+
+	if startMinute < endMinute {
+		quiet = minute >= startMinute && minute < endMinute
+	} else {
+		quiet = minute >= startMinute || minute < endMinute
+	}
+
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	day := local.Day()
+	if minute >= endMinute {
+		day++
+	}
+
+	return time.Date(local.Year(), local.Month(), day, end.Hour(), end.Minute(), 0, 0, location), true
+}
+
+// location returns the time zone of the user, UTC if it is no longer known.
+func (p Preferences) location() *time.Location {
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+func toStoragePreferences(preferences Preferences) (storage.Preferences, error) {
+	defaults := defaultPreferences()
+
+	if preferences.Channel == "" {
+		preferences.Channel = defaults.Channel
+	}
+
+	if preferences.TimeZone == "" {
+		preferences.TimeZone = defaults.TimeZone
+	}
+
+	if preferences.Delivery == "" {
+		preferences.Delivery = defaults.Delivery
+	}
+
+	if preferences.DigestTime == "" {
+		preferences.DigestTime = defaults.DigestTime
+	}
+
+	if err := validateAddress(preferences.Channel, preferences.Address); err != nil {
+		return storage.Preferences{}, err
+	}
+
+	quietStart, quietEnd, err := normalizeQuietHours(preferences.QuietStart, preferences.QuietEnd)
+	if err != nil {
+		return storage.Preferences{}, err
+	}
+
+	if _, err := time.LoadLocation(preferences.TimeZone); err != nil {
+		return storage.Preferences{}, fmt.Errorf("%s: %w", preferences.TimeZone, ErrInvalidTimeZone)
+	}
+
+	if preferences.Delivery != DeliveryImmediate && preferences.Delivery != DeliveryDigest {
+		return storage.Preferences{}, fmt.Errorf("%s: %w", preferences.Delivery, ErrInvalidDelivery)
+	}
+
+	digestTime, err := time.Parse(timeOfDayLayout, preferences.DigestTime)
+	if err != nil {
+		return storage.Preferences{}, fmt.Errorf("%q: %w", preferences.DigestTime, ErrInvalidDigestTime)
+	}
+
+	return storage.Preferences{
+		Channel:    preferences.Channel,
+		Address:    preferences.Address,
+		QuietStart: quietStart,
+		QuietEnd:   quietEnd,
+		TimeZone:   preferences.TimeZone,
+		Delivery:   preferences.Delivery,
+		DigestTime: digestTime.Format(timeOfDayLayout),
+	}, nil
+}
+
+// validateAddress checks the address fits the channel: a bare email address, a phone number in
+// the international format or none for push notifications.
+func validateAddress(channel, address string) error {
+	switch channel {
+	case ChannelPush:
+		if address != "" {
+			return fmt.Errorf("push notifications take no address: %w", ErrInvalidAddress)
+		}
+	case ChannelEmail:
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return fmt.Errorf("%q: %w", address, ErrInvalidAddress)
+		}
+	case ChannelSMS:
+		if !phoneRe.MatchString(address) {
+			return fmt.Errorf("%q: %w", address, ErrInvalidAddress)
+		}
+	default:
+		return fmt.Errorf("%s: %w", channel, ErrInvalidChannel)
+	}
+
+	return nil
+}
+
+// normalizeQuietHours accepts no quiet hours or two different "15:04" times, "7:30" becomes "07:30".
+func normalizeQuietHours(start, end string) (string, string, error) {
+	if start == "" && end == "" {
+		return "", "", nil
+	}
+
+	startTime, startErr := time.Parse(timeOfDayLayout, start)
+	endTime, endErr := time.Parse(timeOfDayLayout, end)
+
+	if startErr != nil || endErr != nil || startTime.Equal(endTime) {
+		return "", "", fmt.Errorf("%q-%q: %w", start, end, ErrInvalidQuietHours)
+	}
+
+	return startTime.Format(timeOfDayLayout), endTime.Format(timeOfDayLayout), nil
+}
+
+func toPreferences(preferences *storage.Preferences) Preferences {
+	return Preferences{
+		Channel:    preferences.Channel,
+		Address:    preferences.Address,
+		QuietStart: preferences.QuietStart,
+		QuietEnd:   preferences.QuietEnd,
+		TimeZone:   preferences.TimeZone,
+		Delivery:   preferences.Delivery,
+		DigestTime: preferences.DigestTime,
+	}
+}
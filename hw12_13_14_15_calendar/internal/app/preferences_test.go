@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToStoragePreferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		preferences Preferences
+		want        storage.Preferences
+		wantErr     error
+	}{
+		{
+			name:        "defaults",
+			preferences: Preferences{},
+			want: storage.Preferences{
+				Channel: ChannelPush, TimeZone: "UTC", Delivery: DeliveryImmediate, DigestTime: defaultDigestTime,
+			},
+		},
+		{
+			name: "email with quiet hours",
+			preferences: Preferences{
+				Channel: ChannelEmail, Address: "alice@example.com", QuietStart: "22:00", QuietEnd: "7:30",
+				TimeZone: "Europe/Moscow", Delivery: DeliveryDigest, DigestTime: "7:15",
+			},
+			want: storage.Preferences{
+				Channel: ChannelEmail, Address: "alice@example.com", QuietStart: "22:00", QuietEnd: "07:30",
+				TimeZone: "Europe/Moscow", Delivery: DeliveryDigest, DigestTime: "07:15",
+			},
+		},
+		{
+			name:        "sms",
+			preferences: Preferences{Channel: ChannelSMS, Address: "+15550100"},
+			want: storage.Preferences{
+				Channel: ChannelSMS, Address: "+15550100", TimeZone: "UTC", Delivery: DeliveryImmediate,
+				DigestTime: defaultDigestTime,
+			},
+		},
+		{name: "unknown channel", preferences: Preferences{Channel: "pigeon"}, wantErr: ErrInvalidChannel},
+		{name: "push with address", preferences: Preferences{Address: "x"}, wantErr: ErrInvalidAddress},
+		{
+			name:        "email with name",
+			preferences: Preferences{Channel: ChannelEmail, Address: "Alice <alice@example.com>"},
+			wantErr:     ErrInvalidAddress,
+		},
+		{
+			name:        "bad phone",
+			preferences: Preferences{Channel: ChannelSMS, Address: "5550100"},
+			wantErr:     ErrInvalidAddress,
+		},
+		{name: "half quiet hours", preferences: Preferences{QuietStart: "22:00"}, wantErr: ErrInvalidQuietHours},
+		{
+			name:        "empty quiet hours",
+			preferences: Preferences{QuietStart: "22:00", QuietEnd: "22:00"},
+			wantErr:     ErrInvalidQuietHours,
+		},
+		{
+			name:        "bad quiet time",
+			preferences: Preferences{QuietStart: "24:00", QuietEnd: "07:00"},
+			wantErr:     ErrInvalidQuietHours,
+		},
+		{name: "bad time zone", preferences: Preferences{TimeZone: "Mars/Olympus"}, wantErr: ErrInvalidTimeZone},
+		{name: "bad delivery", preferences: Preferences{Delivery: "weekly"}, wantErr: ErrInvalidDelivery},
+		{name: "bad digest time", preferences: Preferences{DigestTime: "8am"}, wantErr: ErrInvalidDigestTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toStoragePreferences(tt.preferences)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	overnight := Preferences{QuietStart: "22:00", QuietEnd: "07:30", TimeZone: "Europe/Moscow"}
+	lunch := Preferences{QuietStart: "13:00", QuietEnd: "14:00", TimeZone: "UTC"}
+
+	tests := []struct {
+		name        string
+		preferences Preferences
+		at          time.Time
+		wantQuiet   bool
+		wantUntil   time.Time
+	}{
+		{name: "no quiet hours", preferences: Preferences{TimeZone: "UTC"}, at: time.Now()},
+		{
+			name:        "before midnight",
+			preferences: overnight,
+			at:          time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC), // 22:00 in Moscow
+			wantQuiet:   true,
+			wantUntil:   time.Date(2025, 3, 2, 7, 30, 0, 0, moscow),
+		},
+		{
+			name:        "after midnight",
+			preferences: overnight,
+			at:          time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC),
+			wantQuiet:   true,
+			wantUntil:   time.Date(2025, 3, 1, 7, 30, 0, 0, moscow),
+		},
+		{
+			name:        "quiet hours are over",
+			preferences: overnight,
+			at:          time.Date(2025, 3, 1, 4, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "daytime",
+			preferences: lunch,
+			at:          time.Date(2025, 3, 1, 13, 59, 0, 0, time.UTC),
+			wantQuiet:   true,
+			wantUntil:   time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC),
+		},
+		{name: "daytime end", preferences: lunch, at: time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.preferences.quietUntil(tt.at)
+			require.Equal(t, tt.wantQuiet, quiet)
+			require.True(t, tt.wantUntil.Equal(until), "until %s, want %s", until, tt.wantUntil)
+		})
+	}
+}
+
+func TestPrepareNotifications(t *testing.T) {
+	now := time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC)
+	userEvent := func(id, userID string) *storage.Event {
+		return &storage.Event{
+			ID: id, Title: id, StartDate: now.Add(24 * time.Hour),
+			UserID: sql.NullString{String: userID, Valid: true},
+		}
+	}
+
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetPreferences", anyCtx, "alice").Return(&storage.Preferences{
+		UserID: "alice", Channel: ChannelEmail, Address: "alice@example.com",
+		QuietStart: "22:00", QuietEnd: "07:00", TimeZone: "UTC", Delivery: DeliveryImmediate,
+	}, nil)
+	mockStorage.On("GetPreferences", anyCtx, "bob").Return(nil, storage.ErrPreferencesNotExists)
+	mockStorage.On("GetPreferences", anyCtx, "dave").Return(&storage.Preferences{
+		UserID: "dave", Channel: ChannelPush, TimeZone: "UTC", Delivery: DeliveryDigest, DigestTime: "08:00",
+	}, nil)
+	mockStorage.On("ListOptOuts", anyCtx, "alice").Return([]*storage.OptOut{{EventID: "muted", UserID: "alice"}}, nil)
+	mockStorage.On("ListOptOuts", anyCtx, "bob").Return(nil, nil)
+	mockStorage.On("ListOptOuts", anyCtx, "dave").Return(nil, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	notifications, err := app.PrepareNotifications(context.Background(), []*storage.Event{
+		userEvent("meeting", "alice"), userEvent("muted", "alice"), userEvent("call", "bob"), userEvent("gym", "dave"),
+	}, now)
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+
+	require.Equal(t, "meeting", notifications[0].EventID)
+	require.Equal(t, ChannelEmail, notifications[0].Channel)
+	require.Equal(t, "alice@example.com", notifications[0].Address)
+	require.Equal(t, time.Date(2025, 3, 2, 7, 0, 0, 0, time.UTC), notifications[0].DeliverAt.UTC())
+
+	require.Equal(t, "call", notifications[1].EventID)
+	require.Equal(t, ChannelPush, notifications[1].Channel)
+	require.True(t, notifications[1].DeliverAt.IsZero())
+
+	// Preferences and opt-outs are read once per user, digest users get no reminders.
+	mockStorage.AssertNumberOfCalls(t, "GetPreferences", 3)
+	mockStorage.AssertNumberOfCalls(t, "ListOptOuts", 3)
+}
+
+func TestOptOut(t *testing.T) {
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("GetEvent", anyCtx, "meeting").Return(&storage.Event{
+		ID: "meeting", CalendarID: sql.NullString{String: "work", Valid: true},
+	}, nil)
+	mockStorage.On("SetOptOut", anyCtx, storage.OptOut{EventID: "meeting", UserID: "bob"}).Return(nil)
+	mockStorage.On("DeleteOptOut", anyCtx, "meeting", "bob").Return(storage.ErrOptOutNotExists)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	require.NoError(t, app.OptOut(WithActor(context.Background(), "bob"), "meeting"))
+	require.NoError(t, app.OptIn(WithActor(context.Background(), "bob"), "meeting"))
+
+	// Free-busy viewers cannot tell the event from others, they have nothing to opt out of.
+	require.ErrorIs(t, app.OptOut(WithActor(context.Background(), "carol"), "meeting"), ErrAccessDenied)
+	require.ErrorIs(t, app.OptOut(context.Background(), "meeting"), ErrAccessDenied)
+
+	mockStorage.AssertNumberOfCalls(t, "SetOptOut", 1)
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxTags           = 10
+	maxTagLength      = 32
+	maxCategoryLength = 50
+
+	defaultTagSuggestions = 10
+	maxTagSuggestions     = 50
+)
+
+var (
+	ErrInvalidTag      = errors.New("invalid tag")
+	ErrTooManyTags     = errors.New("too many tags")
+	ErrInvalidCategory = errors.New("invalid category")
+
+	// Tags are lowercase words of letters, digits, dashes and underscores. They never contain
+	// storage.TagSeparator.
+	tagRe = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_-]*$`)
+)
+
+// SuggestTags completes prefix with the tags of the events visible to the actor, the most
+// used first. A non-positive limit means the default one.
+func (a *App) SuggestTags(ctx context.Context, prefix string, limit int) (_ []Tag, err error) {
+	ctx, span := tracer.Start(ctx, "App.SuggestTags", trace.WithAttributes(attribute.String("prefix", prefix)))
+	defer func() { tracing.Finish(span, err) }()
+
+	if limit <= 0 {
+		limit = defaultTagSuggestions
+	}
+
+	limit = min(limit, maxTagSuggestions)
+
+	usages, err := a.storage.ListTags(ctx, normalizeTag(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	checker := a.newAccessChecker(ctx)
+	counts := make(map[string]int)
+
+	var tags []Tag
+
+	for _, usage := range usages {
+		permission, err := checker.permission(ctx, usage.CalendarID.String)
+		if err != nil {
+			return nil, err
+		}
+
+		if !allows(permission, PermissionRead) {
+			continue
+		}
+
+		if _, ok := counts[usage.Tag]; !ok {
+			tags = append(tags, Tag{Name: usage.Tag})
+		}
+
+		counts[usage.Tag] += usage.Count
+	}
+
+	for i := range tags {
+		tags[i].Count = counts[tags[i].Name]
+	}
+
+	// The storage orders tags by name, the stable sort keeps it for equally used ones.
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].Count > tags[j].Count })
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	return tags, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// toStorageTags normalizes and validates tags, dropping duplicates. A nil slice stays nil,
+// so an update without tags keeps the stored ones, an empty one removes them.
+func toStorageTags(tags []string) (storage.Tags, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	normalized := make(storage.Tags, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+
+		if utf8.RuneCountInString(tag) > maxTagLength || !tagRe.MatchString(tag) {
+			return nil, fmt.Errorf("%q: %w", tag, ErrInvalidTag)
+		}
+
+		normalized = append(normalized, tag)
+	}
+
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("%d tags, at most %d allowed: %w", len(normalized), maxTags, ErrTooManyTags)
+	}
+
+	return normalized, nil
+}
+
+func normalizeCategory(category string) (string, error) {
+	category = strings.TrimSpace(category)
+
+	if utf8.RuneCountInString(category) > maxCategoryLength {
+		return "", fmt.Errorf("%q: %w", category, ErrInvalidCategory)
+	}
+
+	return category, nil
+}
+
+// matches reports whether a listed event passes the category and tag parts of the filter.
+func (f ListFilter) matches(event Event) bool {
+	if f.Category != "" && !strings.EqualFold(strings.TrimSpace(f.Category), event.Category) {
+		return false
+	}
+
+	for _, tag := range f.Tags {
+		if !slices.Contains(event.Tags, normalizeTag(tag)) {
+			return false
+		}
+	}
+
+	return true
+}
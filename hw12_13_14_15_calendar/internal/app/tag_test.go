@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToStorageTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    storage.Tags
+		wantErr error
+	}{
+		{name: "nil keeps stored tags", tags: nil, want: nil},
+		{name: "empty removes tags", tags: []string{}, want: storage.Tags{}},
+		{
+			name: "normalized",
+			tags: []string{" Work", "urgent", "work", "ПЛАН-2"},
+			want: storage.Tags{"urgent", "work", "план-2"},
+		},
+		{name: "separator", tags: []string{"a,b"}, wantErr: ErrInvalidTag},
+		{name: "blank", tags: []string{" "}, wantErr: ErrInvalidTag},
+		{name: "too long", tags: []string{strings.Repeat("x", maxTagLength+1)}, wantErr: ErrInvalidTag},
+		{name: "too many", tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), wantErr: ErrTooManyTags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toStorageTags(tt.tags)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestListEventsFilter(t *testing.T) {
+	start := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("ListEventsForDay", anyCtx, start.Truncate(24*time.Hour)).Return([]*storage.Event{
+		{
+			ID: "meeting", StartDate: start, Tags: storage.Tags{"urgent", "work"},
+			Category:   sql.NullString{String: "Meeting", Valid: true},
+			CalendarID: sql.NullString{String: "work", Valid: true},
+		},
+		{ID: "lunch", StartDate: start, Tags: storage.Tags{"food"}},
+		{ID: "call", StartDate: start, Tags: storage.Tags{"work"}},
+	}, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	list := func(actor string, filter ListFilter) []string {
+		events, err := app.ListEvents(WithActor(context.Background(), actor), "2025-02-01", "day", filter)
+		require.NoError(t, err)
+
+		ids := make([]string, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+
+		return ids
+	}
+
+	require.Equal(t, []string{"meeting", "call"}, list("bob", ListFilter{Tags: []string{"Work"}}))
+	require.Equal(t, []string{"meeting"}, list("bob", ListFilter{Tags: []string{"work", "urgent"}}))
+	require.Equal(t, []string{"meeting"}, list("bob", ListFilter{Category: "meeting"}))
+	require.Empty(t, list("bob", ListFilter{Category: "meeting", Tags: []string{"food"}}))
+
+	// Tags of free-busy events are hidden, so filters do not reveal them.
+	require.Equal(t, []string{"call"}, list("carol", ListFilter{Tags: []string{"work"}}))
+}
+
+func TestSuggestTags(t *testing.T) {
+	mockStorage := newCalendarStorageMock()
+	mockStorage.On("ListTags", anyCtx, "wo").Return([]*storage.TagUsage{
+		{Tag: "wood", Count: 1},
+		{Tag: "work", Count: 1},
+		{Tag: "work", CalendarID: sql.NullString{String: "work", Valid: true}, Count: 2},
+		{Tag: "workout", CalendarID: sql.NullString{String: "work", Valid: true}, Count: 1},
+	}, nil)
+
+	app := New(newLoggerMock(), mockStorage)
+
+	tags, err := app.SuggestTags(WithActor(context.Background(), "bob"), " WO", 0)
+	require.NoError(t, err)
+	require.Equal(t, []Tag{{Name: "work", Count: 3}, {Name: "wood", Count: 1}, {Name: "workout", Count: 1}}, tags)
+
+	// Free-busy viewers do not see the tags of the calendar.
+	tags, err = app.SuggestTags(WithActor(context.Background(), "carol"), "wo", 2)
+	require.NoError(t, err)
+	require.Equal(t, []Tag{{Name: "wood", Count: 1}, {Name: "work", Count: 1}}, tags)
+}
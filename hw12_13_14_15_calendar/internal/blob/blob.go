@@ -0,0 +1,17 @@
+// Package blob holds what the blob stores of attachment contents share, the stores themselves
+// live in the subpackages.
+package blob
+
+import "errors"
+
+const (
+	StoreLocal = "local"
+	StoreS3    = "s3"
+)
+
+var (
+	ErrBlobNotExists = errors.New("blob not exist")
+	ErrUnknownStore  = errors.New("unknown blob store")
+	ErrInvalidKey    = errors.New("invalid blob key")
+	ErrSizeMismatch  = errors.New("blob size mismatch")
+)
@@ -0,0 +1,100 @@
+// Package blobtest is a conformance suite every app.BlobStore implementation must pass.
+package blobtest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty store, it is called for every test of the suite.
+type Factory func(t *testing.T) app.BlobStore
+
+const (
+	key1 = "events/00000000-0000-0000-0000-000000000001/a1"
+	key2 = "events/00000000-0000-0000-0000-000000000001/a2"
+)
+
+// Run runs the whole suite against stores made by newStore.
+func Run(t *testing.T, newStore Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, s app.BlobStore)
+	}{
+		{name: "put and get", test: testPutGet},
+		{name: "delete", test: testDelete},
+		{name: "size mismatch", test: testSizeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func mustPut(t *testing.T, s app.BlobStore, key, content string) {
+	t.Helper()
+
+	err := s.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), "text/plain")
+	require.NoError(t, err)
+}
+
+func requireContent(t *testing.T, s app.BlobStore, key, expected string) {
+	t.Helper()
+
+	content, err := s.Get(context.Background(), key)
+	require.NoError(t, err)
+
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(data))
+}
+
+func testPutGet(t *testing.T, s app.BlobStore) {
+	mustPut(t, s, key1, "agenda")
+	mustPut(t, s, key2, "")
+	requireContent(t, s, key1, "agenda")
+	requireContent(t, s, key2, "")
+
+	mustPut(t, s, key1, "new agenda")
+	requireContent(t, s, key1, "new agenda")
+
+	_, err := s.Get(context.Background(), "events/missing")
+	require.ErrorIs(t, err, blob.ErrBlobNotExists)
+}
+
+func testDelete(t *testing.T, s app.BlobStore) {
+	ctx := context.Background()
+
+	mustPut(t, s, key1, "agenda")
+	mustPut(t, s, key2, "slides")
+
+	require.NoError(t, s.Delete(ctx, key1))
+
+	_, err := s.Get(ctx, key1)
+	require.ErrorIs(t, err, blob.ErrBlobNotExists)
+	requireContent(t, s, key2, "slides")
+
+	require.NoError(t, s.Delete(ctx, key1))
+	require.NoError(t, s.Delete(ctx, key2))
+}
+
+func testSizeMismatch(t *testing.T, s app.BlobStore) {
+	ctx := context.Background()
+
+	require.Error(t, s.Put(ctx, key1, strings.NewReader("agenda"), 3, "text/plain"))
+	require.Error(t, s.Put(ctx, key1, strings.NewReader("agenda"), 10, "text/plain"))
+
+	_, err := s.Get(ctx, key1)
+	require.ErrorIs(t, err, blob.ErrBlobNotExists)
+}
@@ -0,0 +1,110 @@
+package fsblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+)
+
+// Store keeps blobs as files under a directory, the slash separated parts of a key become
+// subdirectories.
+type Store struct {
+	dir string
+}
+
+// New returns a store in dir, the directory is created if missing.
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: filepath.Clean(dir)}, nil
+}
+
+// Put writes the content through a temporary file, so a reader sees either the old content or
+// the whole new one.
+func (s *Store) Put(_ context.Context, key string, content io.Reader, size int64, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	written, err := io.Copy(file, io.LimitReader(content, size+1))
+	if err == nil && written != size {
+		err = fmt.Errorf("%s: %d bytes instead of %d: %w", key, written, size, blob.ErrSizeMismatch)
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if err = errors.Join(err, file.Close()); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (s *Store) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, blob.ErrBlobNotExists)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Delete removes the blob and the directories it leaves empty, a missing blob is not an error.
+func (s *Store) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for dir := filepath.Dir(path); dir != s.dir; dir = filepath.Dir(dir) {
+		// Removing fails for a directory which still has other blobs.
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// path maps the key to a file inside the directory of the store.
+func (s *Store) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%q: %w", key, blob.ErrInvalidKey)
+	}
+
+	return filepath.Join(s.dir, name), nil
+}
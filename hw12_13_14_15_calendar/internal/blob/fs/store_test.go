@@ -0,0 +1,46 @@
+package fsblob
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/blobtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) app.BlobStore {
+		t.Helper()
+
+		store, err := New(t.TempDir())
+		require.NoError(t, err)
+
+		return store
+	})
+}
+
+func TestStoreKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := New(filepath.Join(dir, "blobs"))
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../escape", "/etc/passwd", "events/../../escape"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "")
+		require.ErrorIs(t, err, blob.ErrInvalidKey, key)
+	}
+
+	require.NoError(t, store.Put(ctx, "events/e1/a1", strings.NewReader("x"), 1, ""))
+	require.NoError(t, store.Delete(ctx, "events/e1/a1"))
+
+	// Directories left empty are removed, the root stays.
+	entries, err := os.ReadDir(filepath.Join(dir, "blobs"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,130 @@
+package s3blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	signingService   = "s3"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// signer implements AWS Signature Version 4 for S3 requests without a query string.
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// sign sets the date, the payload hash and the Authorization header. Every header already set
+// on the request is signed together with the host.
+func (s signer) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers, signedHeaders := canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, signingService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{signingAlgorithm, amzDate, scope, hashHex(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, signingService)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", signingAlgorithm+" Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalHeaders returns the lowercase sorted "name:value\n" lines and the list of their names.
+func canonicalHeaders(req *http.Request) (headers, signedHeaders string) {
+	values := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		values["host"] = req.URL.Host
+	}
+
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" || name == "user-agent" {
+			continue
+		}
+
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return b.String(), strings.Join(names, ";")
+}
+
+// escapePath percent-encodes everything but the unreserved characters and slashes, as the
+// canonical request of S3 requires.
+func escapePath(path string) string {
+	var b strings.Builder
+
+	for i := range len(path) {
+		c := path[i]
+		if isUnreserved(c) || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,167 @@
+package s3blob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+)
+
+const (
+	defaultRegion = "us-east-1"
+
+	// unsignedPayload lets uploads stream instead of hashing the content up front, the
+	// signature still covers the headers and the key.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// maxErrorBody bounds how much of an error response is read for its code.
+	maxErrorBody = 64 << 10
+)
+
+var ErrMissingBucket = errors.New("s3 bucket is not configured")
+
+// Store keeps blobs in a bucket of an S3-compatible object storage (AWS S3, MinIO, Ceph).
+// Objects are addressed path-style, which every implementation supports, and requests are
+// signed with AWS Signature Version 4.
+type Store struct {
+	endpoint *url.URL
+	bucket   string
+	signer   signer
+	client   *http.Client
+	now      func() time.Time
+}
+
+// New returns a store for the bucket at the endpoint of cfg, e.g. http://localhost:9000.
+func New(cfg config.S3Conf) (*Store, error) {
+	if cfg.Bucket == "" {
+		return nil, ErrMissingBucket
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3 endpoint: %w", err)
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q: must be an http or https url", cfg.Endpoint)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	return &Store{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		signer:   signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: region},
+		client:   &http.Client{},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *Store) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = size
+	if size == 0 {
+		// A zero ContentLength with a non-nil body means unknown length to net/http.
+		req.Body = http.NoBody
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Delete removes the object, S3 does not report a missing one.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, blob.ErrBlobNotExists) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, fmt.Errorf("%q: %w", key, blob.ErrInvalidKey)
+	}
+
+	target := *s.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + s.bucket + "/" + key
+	target.RawPath = escapePath(target.Path)
+
+	return http.NewRequestWithContext(ctx, method, target.String(), body)
+}
+
+// do signs and sends the request, a response other than 2xx is closed and turned into an error.
+func (s *Store) do(req *http.Request) (*http.Response, error) {
+	s.signer.sign(req, unsignedPayload, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&apiErr)
+
+	if resp.StatusCode == http.StatusNotFound && apiErr.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("%s: %w", req.URL.Path, blob.ErrBlobNotExists)
+	}
+
+	return nil, fmt.Errorf("s3 %s %s: %s %s %s", req.Method, req.URL.Path, resp.Status, apiErr.Code, apiErr.Message)
+}
@@ -0,0 +1,164 @@
+package s3blob
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/blobtest"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBucket    = "attachments"
+	testAccessKey = "minioadmin"
+	testSecretKey = "miniosecret"
+)
+
+// fakeS3 is a stand-in for MinIO: a single bucket of objects behind path-style URLs, which
+// rejects requests whose signature does not match the one it computes itself.
+type fakeS3 struct {
+	signer  signer
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	fake := &fakeS3{
+		signer:  signer{accessKey: testAccessKey, secretKey: testSecretKey, region: defaultRegion},
+		objects: make(map[string]string),
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.verify(r) {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+
+		f.objects[key] = string(data)
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		_, _ = io.WriteString(w, data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verify signs a copy of the request holding only the signed headers and compares the result.
+func (f *fakeS3) verify(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+
+	_, signed, ok := strings.Cut(authorization, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+
+	signed, _, _ = strings.Cut(signed, ",")
+
+	now, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	signedReq := &http.Request{Method: r.Method, URL: r.URL, Host: r.Host, Header: http.Header{}}
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" {
+			signedReq.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+
+	f.signer.sign(signedReq, r.Header.Get("X-Amz-Content-Sha256"), now)
+
+	return signedReq.Header.Get("Authorization") == authorization
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, "<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+}
+
+func newTestStore(t *testing.T, endpoint, bucket, secretKey string) *Store {
+	t.Helper()
+
+	store, err := New(config.S3Conf{
+		Endpoint:  endpoint,
+		Bucket:    bucket,
+		AccessKey: testAccessKey,
+		SecretKey: secretKey,
+	})
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestStore(t *testing.T) {
+	blobtest.Run(t, func(t *testing.T) app.BlobStore {
+		t.Helper()
+
+		return newTestStore(t, newFakeS3(t).URL, testBucket, testSecretKey)
+	})
+}
+
+func TestStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeS3(t)
+
+	wrongSecret := newTestStore(t, server.URL, testBucket, "wrong")
+	err := wrongSecret.Put(ctx, "events/e1/a1", strings.NewReader("x"), 1, "")
+	require.ErrorContains(t, err, "SignatureDoesNotMatch")
+
+	missingBucket := newTestStore(t, server.URL, "missing", testSecretKey)
+	_, err = missingBucket.Get(ctx, "events/e1/a1")
+	require.ErrorContains(t, err, "NoSuchBucket")
+
+	// Keys are escaped the way the signature expects.
+	store := newTestStore(t, server.URL, testBucket, testSecretKey)
+	require.NoError(t, store.Put(ctx, "events/e1/agenda (v2)+ü.txt", strings.NewReader("x"), 1, "text/plain"))
+
+	_, err = New(config.S3Conf{Endpoint: "localhost:9000", Bucket: testBucket})
+	require.Error(t, err)
+
+	_, err = New(config.S3Conf{Endpoint: server.URL})
+	require.ErrorIs(t, err, ErrMissingBucket)
+}
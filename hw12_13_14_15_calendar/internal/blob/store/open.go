@@ -0,0 +1,27 @@
+// Package blobstore opens the blob store selected by the configuration. It is apart from
+// package blob because the stores themselves import that one.
+package blobstore
+
+import (
+	"fmt"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+	fsblob "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/fs"
+	s3blob "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/s3"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+)
+
+// Open returns the store of attachment contents, nil when attachments are off.
+func Open(cfg config.AttachmentsConf) (app.BlobStore, error) {
+	switch cfg.Store {
+	case "":
+		return nil, nil
+	case blob.StoreLocal:
+		return fsblob.New(cfg.Dir)
+	case blob.StoreS3:
+		return s3blob.New(cfg.S3)
+	default:
+		return nil, fmt.Errorf("%w: %s", blob.ErrUnknownStore, cfg.Store)
+	}
+}
@@ -0,0 +1,23 @@
+package blobstore
+
+import (
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob"
+	fsblob "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/fs"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	store, err := Open(config.AttachmentsConf{})
+	require.NoError(t, err)
+	require.Nil(t, store)
+
+	store, err = Open(config.AttachmentsConf{Store: blob.StoreLocal, Dir: t.TempDir()})
+	require.NoError(t, err)
+	require.IsType(t, &fsblob.Store{}, store)
+
+	_, err = Open(config.AttachmentsConf{Store: "ftp"})
+	require.ErrorIs(t, err, blob.ErrUnknownStore)
+}
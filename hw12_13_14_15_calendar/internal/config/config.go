@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,10 +15,18 @@ func init() {
 }
 
 type Config struct {
-	Logger   LoggerConf `mapstructure:"logger"`
-	App      AppConf    `mapstructure:"app"`
-	Database DBConf     `mapstructure:"database"`
-	Rabbit   RabbitConf `mapstructure:"rabbit"`
+	Logger      LoggerConf      `mapstructure:"logger"`
+	App         AppConf         `mapstructure:"app"`
+	Database    DBConf          `mapstructure:"database"`
+	SQLite      SQLiteConf      `mapstructure:"sqlite"`
+	Memory      MemoryConf      `mapstructure:"memory"`
+	Rabbit      RabbitConf      `mapstructure:"rabbit"`
+	Metrics     MetricsConf     `mapstructure:"metrics"`
+	Tracing     TracingConf     `mapstructure:"tracing"`
+	Health      HealthConf      `mapstructure:"health"`
+	Limits      LimitsConf      `mapstructure:"limits"`
+	Attachments AttachmentsConf `mapstructure:"attachments"`
+	Templates   TemplatesConf   `mapstructure:"templates"`
 }
 
 type LoggerConf struct {
@@ -30,6 +39,10 @@ type AppConf struct {
 	Host    string `mapstructure:"host"`
 	Port    string `mapstructure:"port"`
 	Storage string `mapstructure:"storage"`
+	// TrashRetention is how long deleted events are kept before the scheduler purges them.
+	TrashRetention time.Duration `mapstructure:"trash_retention"`
+	// DigestInterval is how often the scheduler looks for digests due, it bounds their delay.
+	DigestInterval time.Duration `mapstructure:"digest_interval"`
 }
 
 type DBConf struct {
@@ -38,6 +51,43 @@ type DBConf struct {
 	DBName string `mapstructure:"dbname"`
 	User   string `mapstructure:"user"`
 	Pass   string `mapstructure:"pass"`
+	// AutoMigrate applies pending migrations on startup instead of refusing to start.
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+	// Pool limits, zero keeps the database/sql default.
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	// ReadTimeout and WriteTimeout bound every storage operation, zero leaves it to the caller.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// ReadRetries is how many times a read is repeated after a connection error, the pause
+	// starts at RetryBackoff and doubles.
+	ReadRetries  int           `mapstructure:"read_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// Replicas serve the list queries, they share the database name, credentials and limits
+	// with the primary and are pinged every ReplicaCheckInterval.
+	Replicas             []ReplicaConf `mapstructure:"replicas"`
+	ReplicaCheckInterval time.Duration `mapstructure:"replica_check_interval"`
+}
+
+type ReplicaConf struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+}
+
+// SQLiteConf is the database file used by the "sqlite" storage, it is created if missing.
+type SQLiteConf struct {
+	Path string `mapstructure:"path"`
+}
+
+// MemoryConf makes the "memory" storage durable, without Dir it is lost on restart. Fsync is
+// always, interval or never, zero SnapshotInterval leaves compaction to startup and shutdown.
+type MemoryConf struct {
+	Dir              string        `mapstructure:"dir"`
+	Fsync            string        `mapstructure:"fsync"`
+	FsyncInterval    time.Duration `mapstructure:"fsync_interval"`
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"`
 }
 
 type RabbitConf struct {
@@ -47,6 +97,53 @@ type RabbitConf struct {
 	Pass string `mapstructure:"pass"`
 }
 
+type MetricsConf struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+}
+
+type TracingConf struct {
+	Exporter string `mapstructure:"exporter"`
+	Endpoint string `mapstructure:"endpoint"`
+	Insecure bool   `mapstructure:"insecure"`
+}
+
+type HealthConf struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+}
+
+type LimitsConf struct {
+	// RPS and Burst configure per-IP token buckets, zero RPS disables rate limiting.
+	RPS            float64 `mapstructure:"rps"`
+	Burst          int     `mapstructure:"burst"`
+	MaxBodySize    int64   `mapstructure:"max_body_size"`
+	MaxMessageSize int     `mapstructure:"max_message_size"`
+}
+
+// AttachmentsConf selects where attachment contents are kept: "local" uses Dir, "s3" an
+// S3-compatible bucket, an empty Store turns attachments off. Zero limits keep the defaults.
+type AttachmentsConf struct {
+	Store       string `mapstructure:"store"`
+	Dir         string `mapstructure:"dir"`
+	MaxSize     int64  `mapstructure:"max_size"`
+	MaxPerEvent int    `mapstructure:"max_per_event"`
+	S3          S3Conf `mapstructure:"s3"`
+}
+
+type S3Conf struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// TemplatesConf points the sender to templates overriding the built-in ones, see render.New.
+type TemplatesConf struct {
+	Dir string `mapstructure:"dir"`
+}
+
 func NewConfig() Config {
 	var config Config
 
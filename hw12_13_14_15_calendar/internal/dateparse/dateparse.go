@@ -0,0 +1,169 @@
+// Package dateparse parses dates typed by people: absolute dates like "2025-02-01 10:00" and
+// phrases relative to now like "tomorrow 10:00", "next monday at 9am" or "in 2 hours".
+package dateparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("unknown date format")
+
+var (
+	absoluteLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+	relativeRe = regexp.MustCompile(`^in (\d+) (minute|hour|day|week)s?$`)
+	clockRe    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+	weekdays = map[string]time.Weekday{
+		"sunday":    time.Sunday,
+		"monday":    time.Monday,
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+		"saturday":  time.Saturday,
+	}
+
+	units = map[string]time.Duration{
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+		"week":   7 * 24 * time.Hour,
+	}
+)
+
+// Parse returns the moment described by s in the location of now. A phrase naming only a day
+// gives its midnight, a bare clock time means today.
+func Parse(s string, now time.Time) (time.Time, error) {
+	phrase := strings.Join(strings.Fields(s), " ")
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, phrase, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	phrase = strings.ToLower(phrase)
+
+	if m := relativeRe.FindStringSubmatch(phrase); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return now.Add(time.Duration(n) * units[m[2]]).Truncate(time.Minute), nil
+	}
+
+	dayPart, clockPart := splitClock(phrase)
+
+	day, err := parseDay(dayPart, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	if clockPart == "" {
+		return day, nil
+	}
+
+	hour, minute, err := parseClock(clockPart)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute), nil
+}
+
+// splitClock separates the trailing clock time, optionally introduced with "at", from the day.
+func splitClock(phrase string) (day, clock string) {
+	if before, after, ok := strings.Cut(phrase, " at "); ok {
+		return before, after
+	}
+
+	if idx := strings.LastIndex(phrase, " "); idx >= 0 && clockRe.MatchString(phrase[idx+1:]) {
+		return phrase[:idx], phrase[idx+1:]
+	}
+
+	if clockRe.MatchString(phrase) && (strings.Contains(phrase, ":") || strings.HasSuffix(phrase, "m")) {
+		return "today", phrase
+	}
+
+	return phrase, ""
+}
+
+func parseDay(phrase string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch phrase {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", phrase, now.Location()); err == nil {
+		return t, nil
+	}
+
+	modifier, name, ok := strings.Cut(phrase, " ")
+	if !ok {
+		modifier, name = "", phrase
+	}
+
+	weekday, known := weekdays[name]
+	if !known {
+		return time.Time{}, ErrUnknownFormat
+	}
+
+	ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+
+	switch modifier {
+	case "":
+	case "next":
+		if ahead == 0 {
+			ahead = 7
+		}
+	case "last":
+		ahead -= 7
+	default:
+		return time.Time{}, ErrUnknownFormat
+	}
+
+	return today.AddDate(0, 0, ahead), nil
+}
+
+func parseClock(clock string) (hour, minute int, err error) {
+	m := clockRe.FindStringSubmatch(clock)
+	if m == nil {
+		return 0, 0, ErrUnknownFormat
+	}
+
+	hour, _ = strconv.Atoi(m[1])
+
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+
+	if m[3] != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, ErrUnknownFormat
+		}
+
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0, 0, ErrUnknownFormat
+	}
+
+	return hour, minute, nil
+}
@@ -0,0 +1,65 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2025, 2, 5, 14, 37, 12, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{input: "2025-02-01 10:00", expected: time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{input: "2025-02-01T10:00", expected: time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{input: "2025-02-01", expected: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "today", expected: time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{input: "16:30", expected: time.Date(2025, 2, 5, 16, 30, 0, 0, time.UTC)},
+		{input: "9am", expected: time.Date(2025, 2, 5, 9, 0, 0, 0, time.UTC)},
+		{input: "Tomorrow 10:00", expected: time.Date(2025, 2, 6, 10, 0, 0, 0, time.UTC)},
+		{input: "tomorrow at 12am", expected: time.Date(2025, 2, 6, 0, 0, 0, 0, time.UTC)},
+		{input: "yesterday  12pm", expected: time.Date(2025, 2, 4, 12, 0, 0, 0, time.UTC)},
+		{input: "friday", expected: time.Date(2025, 2, 7, 0, 0, 0, 0, time.UTC)},
+		{input: "wednesday", expected: time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{input: "next wednesday", expected: time.Date(2025, 2, 12, 0, 0, 0, 0, time.UTC)},
+		{input: "next monday", expected: time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)},
+		{input: "next monday at 9:15pm", expected: time.Date(2025, 2, 10, 21, 15, 0, 0, time.UTC)},
+		{input: "last monday", expected: time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{input: "2025-03-01 at 8:00", expected: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{input: "in 2 hours", expected: time.Date(2025, 2, 5, 16, 37, 0, 0, time.UTC)},
+		{input: "in 1 week", expected: time.Date(2025, 2, 12, 14, 37, 0, 0, time.UTC)},
+		{input: "in 30 minutes", expected: time.Date(2025, 2, 5, 15, 7, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input, now)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+
+	got, err := Parse("tomorrow 10:00", time.Date(2025, 2, 5, 23, 30, 0, 0, loc))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 2, 6, 10, 0, 0, 0, loc), got)
+}
+
+func TestParseErrors(t *testing.T) {
+	now := time.Date(2025, 2, 5, 14, 37, 0, 0, time.UTC)
+
+	for _, input := range []string{"", "10", "someday", "next week", "soon monday", "tomorrow 25:00", "13pm", "0am"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(input, now)
+			require.ErrorIs(t, err, ErrUnknownFormat)
+		})
+	}
+}
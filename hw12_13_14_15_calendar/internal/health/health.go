@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports whether a dependency of the service is reachable.
+type Check func(ctx context.Context) error
+
+type Report struct {
+	Status            string            `json:"status"`
+	Checks            map[string]string `json:"checks,omitempty"`
+	LastSuccessfulRun string            `json:"last_successful_run,omitempty"`
+}
+
+type Health struct {
+	mu      sync.RWMutex
+	names   []string
+	checks  map[string]Check
+	lastRun time.Time
+}
+
+func New() *Health {
+	return &Health{
+		checks: make(map[string]Check),
+	}
+}
+
+func (h *Health) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+		sort.Strings(h.names)
+	}
+
+	h.checks[name] = check
+}
+
+// MarkRun remembers the time of the last successfully finished job.
+func (h *Health) MarkRun(t time.Time) {
+	h.mu.Lock()
+	h.lastRun = t
+	h.mu.Unlock()
+}
+
+// Check runs all registered checks and reports whether every one of them passed.
+func (h *Health) Check(ctx context.Context) (Report, bool) {
+	h.mu.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, h.checks[name])
+	}
+	lastRun := h.lastRun
+	h.mu.RUnlock()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]string, len(names)),
+	}
+
+	for i, check := range checks {
+		if err := check(ctx); err != nil {
+			report.Status = StatusFail
+			report.Checks[names[i]] = err.Error()
+			continue
+		}
+
+		report.Checks[names[i]] = StatusOK
+	}
+
+	if !lastRun.IsZero() {
+		report.LastSuccessfulRun = lastRun.Format(time.RFC3339)
+	}
+
+	return report, report.Status == StatusOK
+}
+
+// Liveness reports that the process is up and able to serve requests.
+func (h *Health) Liveness(w http.ResponseWriter, _ *http.Request) {
+	renderReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readiness reports whether all dependencies of the service are reachable.
+func (h *Health) Readiness(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.Check(r.Context())
+	if !ok {
+		renderReport(w, http.StatusServiceUnavailable, report)
+		return
+	}
+
+	renderReport(w, http.StatusOK, report)
+}
+
+func renderReport(w http.ResponseWriter, statusCode int, report Report) {
+	jsonResp, _ := json.Marshal(report)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(jsonResp)
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		h := New()
+		h.AddCheck("storage", func(context.Context) error { return nil })
+
+		lastRun := time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)
+		h.MarkRun(lastRun)
+
+		report, ok := h.Check(context.Background())
+		require.True(t, ok)
+		require.Equal(t, StatusOK, report.Status)
+		require.Equal(t, StatusOK, report.Checks["storage"])
+		require.Equal(t, lastRun.Format(time.RFC3339), report.LastSuccessfulRun)
+	})
+
+	t.Run("failed check", func(t *testing.T) {
+		h := New()
+		h.AddCheck("storage", func(context.Context) error { return nil })
+		h.AddCheck("queue", func(context.Context) error { return errors.New("connection closed") })
+
+		report, ok := h.Check(context.Background())
+		require.False(t, ok)
+		require.Equal(t, StatusFail, report.Status)
+		require.Equal(t, "connection closed", report.Checks["queue"])
+	})
+
+	t.Run("handlers", func(t *testing.T) {
+		h := New()
+		h.AddCheck("queue", func(context.Context) error { return errors.New("connection closed") })
+
+		w := httptest.NewRecorder()
+		h.Liveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		h.Readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Contains(t, w.Body.String(), `"queue":"connection closed"`)
+	})
+}
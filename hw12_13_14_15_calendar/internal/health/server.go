@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+)
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+	Warn(msg string)
+	Debug(msg string)
+}
+
+// Server exposes /healthz and /readyz for services without a public HTTP API.
+type Server struct {
+	logger Logger
+	health *Health
+	srv    *http.Server
+}
+
+func NewServer(cfg config.HealthConf, logger Logger, health *Health) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.Liveness)
+	mux.HandleFunc("/readyz", health.Readiness)
+
+	return &Server{
+		logger: logger,
+		health: health,
+		// The server is built here, Stop may run before or concurrently with Start.
+		srv: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, cfg.Port),
+			Handler:           mux,
+			ReadHeaderTimeout: 1 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	s.logger.Info(fmt.Sprintf("health server starting at %s", s.srv.Addr))
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info("health server stopping...")
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,394 @@
+// Package ical encodes and decodes the subset of iCalendar (RFC 5545) used by the calendar:
+// VEVENT components with a summary, description, start, end and a single display alarm.
+// Recurrence rules and attendees are ignored.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	productID     = "-//evg555//hw-otus calendar//EN"
+	dateLayout    = "20060102"
+	dateTimeUTC   = "20060102T150405Z"
+	dateTimeLocal = "20060102T150405"
+	maxLineOctets = 75
+)
+
+var (
+	ErrNoCalendar    = errors.New("no VCALENDAR component")
+	ErrMalformedLine = errors.New("malformed content line")
+	ErrInvalidDate   = errors.New("invalid date")
+
+	durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+)
+
+// Event is a single VEVENT. AlarmDays is the number of days before Start the client should
+// remind about the event, zero means no alarm.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	AlarmDays   int32
+}
+
+// Encode writes events as one VCALENDAR object. stamp is used as DTSTAMP of every event.
+func Encode(w io.Writer, events []Event, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	write := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", productID)
+
+	for _, event := range events {
+		write("BEGIN", "VEVENT")
+		write("UID", escapeText(event.UID))
+		write("DTSTAMP", stamp.UTC().Format(dateTimeUTC))
+		write("DTSTART", event.Start.UTC().Format(dateTimeUTC))
+		write("DTEND", event.End.UTC().Format(dateTimeUTC))
+		write("SUMMARY", escapeText(event.Summary))
+
+		if event.Description != "" {
+			write("DESCRIPTION", escapeText(event.Description))
+		}
+
+		if event.AlarmDays > 0 {
+			write("BEGIN", "VALARM")
+			write("ACTION", "DISPLAY")
+			write("DESCRIPTION", escapeText(event.Summary))
+			write("TRIGGER", fmt.Sprintf("-P%dD", event.AlarmDays))
+			write("END", "VALARM")
+		}
+
+		write("END", "VEVENT")
+	}
+
+	write("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+// Decode reads VEVENT components of a VCALENDAR object. Floating times and dates are
+// interpreted in loc.
+func Decode(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{loc: loc}
+
+	for _, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			d.begin(strings.ToUpper(prop.value))
+		case "END":
+			err = d.end(strings.ToUpper(prop.value))
+		default:
+			err = d.property(prop)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !d.sawCalendar {
+		return nil, ErrNoCalendar
+	}
+
+	return d.events, nil
+}
+
+// decoder tracks the component nesting, only VEVENT directly inside VCALENDAR and its VALARM
+// are read.
+type decoder struct {
+	loc         *time.Location
+	stack       []string
+	events      []Event
+	event       *Event
+	hasEnd      bool
+	allDay      bool
+	duration    time.Duration
+	sawCalendar bool
+}
+
+func (d *decoder) begin(component string) {
+	d.stack = append(d.stack, component)
+
+	switch {
+	case len(d.stack) == 1 && component == "VCALENDAR":
+		d.sawCalendar = true
+	case len(d.stack) == 2 && component == "VEVENT":
+		d.event = &Event{}
+		d.hasEnd, d.allDay, d.duration = false, false, 0
+	}
+}
+
+func (d *decoder) end(component string) error {
+	if len(d.stack) == 0 || d.stack[len(d.stack)-1] != component {
+		return fmt.Errorf("unexpected END:%s: %w", component, ErrMalformedLine)
+	}
+
+	if len(d.stack) == 2 && d.event != nil {
+		if !d.hasEnd {
+			d.event.End = eventEnd(d.event.Start, d.duration, d.allDay)
+		}
+
+		d.events = append(d.events, *d.event)
+		d.event = nil
+	}
+
+	d.stack = d.stack[:len(d.stack)-1]
+
+	return nil
+}
+
+func (d *decoder) property(prop property) (err error) {
+	switch {
+	case d.event == nil:
+		return nil
+	case len(d.stack) == 3 && d.stack[2] == "VALARM":
+		if prop.name == "TRIGGER" {
+			d.event.AlarmDays = alarmDays(prop.value)
+		}
+
+		return nil
+	case len(d.stack) != 2:
+		return nil
+	}
+
+	switch prop.name {
+	case "UID":
+		d.event.UID = unescapeText(prop.value)
+	case "SUMMARY":
+		d.event.Summary = unescapeText(prop.value)
+	case "DESCRIPTION":
+		d.event.Description = unescapeText(prop.value)
+	case "DTSTART":
+		d.event.Start, d.allDay, err = parseTime(prop, d.loc)
+	case "DTEND":
+		d.event.End, _, err = parseTime(prop, d.loc)
+		d.hasEnd = true
+	case "DURATION":
+		d.duration, err = parseDuration(prop.value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", prop.name, err)
+	}
+
+	return nil
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseLine splits "NAME;PARAM=VALUE:value" into its parts. Parameter values may be quoted
+// and contain ':' and ';'.
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	quoted := false
+	colon := -1
+
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+
+	if colon <= 0 {
+		return property{}, fmt.Errorf("%q: %w", line, ErrMalformedLine)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	prop.value = line[colon+1:]
+
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return property{}, fmt.Errorf("%q: %w", line, ErrMalformedLine)
+		}
+
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+func parseTime(prop property, loc *time.Location) (time.Time, bool, error) {
+	if prop.params["VALUE"] == "DATE" || len(prop.value) == len(dateLayout) {
+		t, err := time.ParseInLocation(dateLayout, prop.value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%s: %w", prop.value, ErrInvalidDate)
+		}
+
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(prop.value, "Z") {
+		t, err := time.Parse(dateTimeUTC, prop.value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%s: %w", prop.value, ErrInvalidDate)
+		}
+
+		return t, false, nil
+	}
+
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+
+	t, err := time.ParseInLocation(dateTimeLocal, prop.value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: %w", prop.value, ErrInvalidDate)
+	}
+
+	return t, false, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("%s: %w", value, ErrInvalidDate)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, err
+		}
+
+		d += time.Duration(n) * unit
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// alarmDays converts a relative TRIGGER before the start to whole days, rounding up.
+// Absolute or positive triggers are not supported and give no alarm.
+func alarmDays(value string) int32 {
+	d, err := parseDuration(value)
+	if err != nil || d >= 0 {
+		return 0
+	}
+
+	day := 24 * time.Hour
+
+	return int32((-d + day - 1) / day)
+}
+
+// eventEnd applies the RFC 5545 defaults for an event without DTEND.
+func eventEnd(start time.Time, duration time.Duration, allDay bool) time.Time {
+	switch {
+	case duration > 0:
+		return start.Add(duration)
+	case allDay:
+		return start.AddDate(0, 0, 1)
+	default:
+		return start
+	}
+}
+
+// unfold reads content lines joining the continuation ones which start with a space or a tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// writeLine writes the content line folded to 75 octets without splitting UTF-8 sequences.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var (
+	textEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
@@ -0,0 +1,113 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	events := []Event{
+		{
+			UID:         "1",
+			Summary:     "Planning; sprint, 42",
+			Description: strings.Repeat("long description ", 10) + "\nsecond line",
+			Start:       time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC),
+			End:         time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC),
+			AlarmDays:   2,
+		},
+		{
+			UID:     "2",
+			Summary: "Обед",
+			Start:   time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC),
+			End:     time.Date(2025, 2, 1, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events, time.Now()))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), maxLineOctets)
+	}
+
+	decoded, err := Decode(&buf, time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, events, decoded)
+}
+
+func TestDecode(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		body          string
+		expected      []Event
+		expectedError error
+	}{
+		{
+			name: "time zone, duration and alarm",
+			body: "BEGIN:VCALENDAR\nBEGIN:VTIMEZONE\nTZID:Europe/Moscow\nEND:VTIMEZONE\nBEGIN:VEVENT\n" +
+				"UID:abc\nSUMMARY:Standup\nDTSTART;TZID=\"Europe/Moscow\":20250201T100000\nDURATION:PT15M\n" +
+				"BEGIN:VALARM\nTRIGGER:-PT36H\nEND:VALARM\nEND:VEVENT\nEND:VCALENDAR\n",
+			expected: []Event{{
+				UID:       "abc",
+				Summary:   "Standup",
+				Start:     time.Date(2025, 2, 1, 10, 0, 0, 0, moscow),
+				End:       time.Date(2025, 2, 1, 10, 15, 0, 0, moscow),
+				AlarmDays: 2,
+			}},
+		},
+		{
+			name: "all-day floating event",
+			body: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:day\r\nSUMMARY:Holi\r\n day\r\n" +
+				"DTSTART;VALUE=DATE:20250308\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			expected: []Event{{
+				UID:     "day",
+				Summary: "Holiday",
+				Start:   time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name:          "no calendar",
+			body:          "BEGIN:VEVENT\nEND:VEVENT\n",
+			expectedError: ErrNoCalendar,
+		},
+		{
+			name:          "unbalanced components",
+			body:          "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+			expectedError: ErrMalformedLine,
+		},
+		{
+			name:          "invalid date",
+			body:          "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\nEND:VCALENDAR\n",
+			expectedError: ErrInvalidDate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Decode(strings.NewReader(tt.body), time.UTC)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, events, len(tt.expected))
+
+			for i, expected := range tt.expected {
+				require.Equal(t, expected.UID, events[i].UID)
+				require.Equal(t, expected.Summary, events[i].Summary)
+				require.Equal(t, expected.AlarmDays, events[i].AlarmDays)
+				require.True(t, expected.Start.Equal(events[i].Start), events[i].Start)
+				require.True(t, expected.End.Equal(events[i].End), events[i].End)
+			}
+		})
+	}
+}
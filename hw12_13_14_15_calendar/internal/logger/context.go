@@ -0,0 +1,38 @@
+package logger
+
+import "context"
+
+const RequestIDKey = "request_id"
+
+type fieldsKey struct{}
+
+// ContextWith returns a copy of ctx carrying key=value, which will be added
+// to every line logged with this context.
+func ContextWith(ctx context.Context, key string, value interface{}) context.Context {
+	parent := FieldsFromContext(ctx)
+
+	fields := make(map[string]interface{}, len(parent)+1)
+	for k, v := range parent {
+		fields[k] = v
+	}
+
+	fields[key] = value
+
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func FieldsFromContext(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).(map[string]interface{})
+
+	return fields
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return ContextWith(ctx, RequestIDKey, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := FieldsFromContext(ctx)[RequestIDKey].(string)
+
+	return requestID
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -12,6 +13,7 @@ var ErrFormatNotExist = errors.New("format not exist")
 
 type Logger struct {
 	logger *log.Logger
+	fields log.Fields
 }
 
 func New(cfg config.LoggerConf) Logger {
@@ -44,18 +46,59 @@ func New(cfg config.LoggerConf) Logger {
 	}
 }
 
+// With returns a logger which adds key=value to every logged line.
+func (l Logger) With(key string, value interface{}) Logger {
+	fields := make(log.Fields, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+
+	fields[key] = value
+
+	return Logger{
+		logger: l.logger,
+		fields: fields,
+	}
+}
+
 func (l Logger) Info(msg string) {
-	l.logger.Info(msg)
+	l.entry(context.Background()).Info(msg)
 }
 
 func (l Logger) Error(msg string) {
-	l.logger.Error(msg)
+	l.entry(context.Background()).Error(msg)
 }
 
 func (l Logger) Warn(msg string) {
-	l.logger.Warn(msg)
+	l.entry(context.Background()).Warn(msg)
 }
 
 func (l Logger) Debug(msg string) {
-	l.logger.Debug(msg)
+	l.entry(context.Background()).Debug(msg)
+}
+
+func (l Logger) InfoContext(ctx context.Context, msg string) {
+	l.entry(ctx).Info(msg)
+}
+
+func (l Logger) ErrorContext(ctx context.Context, msg string) {
+	l.entry(ctx).Error(msg)
+}
+
+func (l Logger) WarnContext(ctx context.Context, msg string) {
+	l.entry(ctx).Warn(msg)
+}
+
+func (l Logger) DebugContext(ctx context.Context, msg string) {
+	l.entry(ctx).Debug(msg)
+}
+
+func (l Logger) entry(ctx context.Context) *log.Entry {
+	entry := l.logger.WithFields(l.fields)
+
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		entry = entry.WithFields(fields)
+	}
+
+	return entry
 }
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
@@ -143,4 +144,47 @@ func TestLogger(t *testing.T) {
 			New(cfg.Logger)
 		}, "expected panic for invalid log format, but none occurred")
 	})
+	t.Run("with fields", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		cfg := config.Config{
+			Logger: config.LoggerConf{
+				Level:  "info",
+				Format: "json",
+			},
+		}
+
+		logger := New(cfg.Logger)
+		logger.logger.Out = &buf
+
+		logger.With("service", "calendar").Info("this is a info message")
+
+		output := buf.String()
+		require.Contains(t, output, `"service":"calendar"`)
+	})
+
+	t.Run("context fields", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		cfg := config.Config{
+			Logger: config.LoggerConf{
+				Level:  "info",
+				Format: "json",
+			},
+		}
+
+		logger := New(cfg.Logger)
+		logger.logger.Out = &buf
+
+		ctx := WithRequestID(context.Background(), "test request id")
+		ctx = ContextWith(ctx, "event_id", "test uuid")
+
+		require.Equal(t, "test request id", RequestIDFromContext(ctx))
+
+		logger.ErrorContext(ctx, "this is an error message")
+
+		output := buf.String()
+		require.Contains(t, output, `"request_id":"test request id"`)
+		require.Contains(t, output, `"event_id":"test uuid"`)
+	})
 }
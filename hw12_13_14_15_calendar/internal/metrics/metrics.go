@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "calendar"
+
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latencies by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	grpcRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Total number of gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC request latencies by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	storageOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operations_total",
+		Help:      "Total number of storage operations by operation and status.",
+	}, []string{"operation", "status"})
+
+	storageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Storage operation latencies by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	schedulerJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "job_duration_seconds",
+		Help:      "Scheduler job run durations by status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"status"})
+
+	notificationsEnqueuedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "notifications_enqueued_total",
+		Help:      "Total number of notifications added to the queue.",
+	})
+
+	notificationsSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sender",
+		Name:      "notifications_total",
+		Help:      "Total number of notifications processed by sender by status.",
+	}, []string{"status"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		grpcRequestsTotal,
+		grpcRequestDuration,
+		storageOperationsTotal,
+		storageOperationDuration,
+		schedulerJobDuration,
+		notificationsEnqueuedTotal,
+		notificationsSentTotal,
+	)
+}
+
+func ObserveHTTPRequest(route, method string, code int, duration time.Duration) {
+	httpRequestsTotal.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+func ObserveGRPCRequest(method, code string, duration time.Duration) {
+	grpcRequestsTotal.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+func ObserveStorageOperation(operation string, err error, duration time.Duration) {
+	storageOperationsTotal.WithLabelValues(operation, status(err)).Inc()
+	storageOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func ObserveSchedulerJob(err error, duration time.Duration) {
+	schedulerJobDuration.WithLabelValues(status(err)).Observe(duration.Seconds())
+}
+
+func IncNotificationsEnqueued() {
+	notificationsEnqueuedTotal.Inc()
+}
+
+func IncNotificationsDelivered() {
+	notificationsSentTotal.WithLabelValues("delivered").Inc()
+}
+
+func IncNotificationsFailed() {
+	notificationsSentTotal.WithLabelValues("failed").Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return statusError
+	}
+
+	return statusOK
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+	Warn(msg string)
+	Debug(msg string)
+}
+
+// Server exposes collected metrics at /metrics for Prometheus scraping.
+type Server struct {
+	logger Logger
+	srv    *http.Server
+}
+
+func NewServer(cfg config.MetricsConf, logger Logger) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return &Server{
+		logger: logger,
+		// The server is built here, Stop may run before or concurrently with Start.
+		srv: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, cfg.Port),
+			Handler:           mux,
+			ReadHeaderTimeout: 1 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	s.logger.Info(fmt.Sprintf("metrics server starting at %s", s.srv.Addr))
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info("metrics server stopping...")
+	return s.srv.Shutdown(ctx)
+}
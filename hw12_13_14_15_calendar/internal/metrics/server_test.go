@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string)  {}
+func (nopLogger) Error(string) {}
+func (nopLogger) Warn(string)  {}
+func (nopLogger) Debug(string) {}
+
+func TestServerStopBeforeStart(t *testing.T) {
+	s := NewServer(config.MetricsConf{Host: "127.0.0.1", Port: "0"}, nopLogger{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- s.Start(ctx)
+	}()
+
+	require.NoError(t, s.Stop(context.Background()))
+	cancel()
+
+	select {
+	case err := <-started:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server is still running after stop")
+	}
+}
@@ -0,0 +1,326 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Storage wraps app.Storage and records latency and status of every operation.
+type Storage struct {
+	storage app.Storage
+}
+
+func NewStorage(storage app.Storage) *Storage {
+	return &Storage{
+		storage: storage,
+	}
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (*storage.Event, error) {
+	start := time.Now()
+	event, err := s.storage.GetEvent(ctx, id)
+	ObserveStorageOperation("get_event", err, time.Since(start))
+
+	return event, err
+}
+
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error {
+	start := time.Now()
+	err := s.storage.CreateEvent(ctx, event, audit)
+	ObserveStorageOperation("create_event", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) UpdateEvent(ctx context.Context, id string, event storage.Event, audit *storage.AuditEntry) error {
+	start := time.Now()
+	err := s.storage.UpdateEvent(ctx, id, event, audit)
+	ObserveStorageOperation("update_event", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) DeleteEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) error {
+	start := time.Now()
+	err := s.storage.DeleteEvent(ctx, event, audit)
+	ObserveStorageOperation("delete_event", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ApplyBatch(ctx context.Context, ops []storage.Operation, atomic bool) ([]error, error) {
+	start := time.Now()
+	results, err := s.storage.ApplyBatch(ctx, ops, atomic)
+	ObserveStorageOperation("apply_batch", err, time.Since(start))
+
+	return results, err
+}
+
+func (s *Storage) ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListEventsForDay(ctx, date)
+	ObserveStorageOperation("list_events_for_day", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) ListEventsForWeek(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListEventsForWeek(ctx, date)
+	ObserveStorageOperation("list_events_for_week", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) ListEventsForMonth(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListEventsForMonth(ctx, date)
+	ObserveStorageOperation("list_events_for_month", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) ListEventsNear(
+	ctx context.Context, center storage.Point, radius float64, from, to time.Time,
+) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListEventsNear(ctx, center, radius, from, to)
+	ObserveStorageOperation("list_events_near", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) ListEventsForNotify(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListEventsForNotify(ctx, date)
+	ObserveStorageOperation("list_events_for_notify", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) DeleteOldEvents(ctx context.Context, date time.Time) error {
+	start := time.Now()
+	err := s.storage.DeleteOldEvents(ctx, date)
+	ObserveStorageOperation("delete_old_events", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ListDeletedEvents(ctx context.Context) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListDeletedEvents(ctx)
+	ObserveStorageOperation("list_deleted_events", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) RestoreEvent(ctx context.Context, id string, audit *storage.AuditEntry) error {
+	start := time.Now()
+	err := s.storage.RestoreEvent(ctx, id, audit)
+	ObserveStorageOperation("restore_event", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) PurgeDeletedEvents(ctx context.Context, before time.Time) error {
+	start := time.Now()
+	err := s.storage.PurgeDeletedEvents(ctx, before)
+	ObserveStorageOperation("purge_deleted_events", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ListAuditEntries(ctx context.Context, eventID string) ([]*storage.AuditEntry, error) {
+	start := time.Now()
+	entries, err := s.storage.ListAuditEntries(ctx, eventID)
+	ObserveStorageOperation("list_audit_entries", err, time.Since(start))
+
+	return entries, err
+}
+
+func (s *Storage) ListTags(ctx context.Context, prefix string) ([]*storage.TagUsage, error) {
+	start := time.Now()
+	usages, err := s.storage.ListTags(ctx, prefix)
+	ObserveStorageOperation("list_tags", err, time.Since(start))
+
+	return usages, err
+}
+
+func (s *Storage) CreateAttachment(ctx context.Context, attachment storage.Attachment) error {
+	start := time.Now()
+	err := s.storage.CreateAttachment(ctx, attachment)
+	ObserveStorageOperation("create_attachment", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) GetAttachment(ctx context.Context, id string) (*storage.Attachment, error) {
+	start := time.Now()
+	attachment, err := s.storage.GetAttachment(ctx, id)
+	ObserveStorageOperation("get_attachment", err, time.Since(start))
+
+	return attachment, err
+}
+
+func (s *Storage) ListAttachments(ctx context.Context, eventID string) ([]*storage.Attachment, error) {
+	start := time.Now()
+	attachments, err := s.storage.ListAttachments(ctx, eventID)
+	ObserveStorageOperation("list_attachments", err, time.Since(start))
+
+	return attachments, err
+}
+
+func (s *Storage) ListOrphanedAttachments(ctx context.Context) ([]*storage.Attachment, error) {
+	start := time.Now()
+	attachments, err := s.storage.ListOrphanedAttachments(ctx)
+	ObserveStorageOperation("list_orphaned_attachments", err, time.Since(start))
+
+	return attachments, err
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.storage.DeleteAttachment(ctx, id)
+	ObserveStorageOperation("delete_attachment", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) GetPreferences(ctx context.Context, userID string) (*storage.Preferences, error) {
+	start := time.Now()
+	preferences, err := s.storage.GetPreferences(ctx, userID)
+	ObserveStorageOperation("get_preferences", err, time.Since(start))
+
+	return preferences, err
+}
+
+func (s *Storage) SetPreferences(ctx context.Context, preferences storage.Preferences) error {
+	start := time.Now()
+	err := s.storage.SetPreferences(ctx, preferences)
+	ObserveStorageOperation("set_preferences", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ListPreferences(ctx context.Context, delivery string) ([]*storage.Preferences, error) {
+	start := time.Now()
+	preferences, err := s.storage.ListPreferences(ctx, delivery)
+	ObserveStorageOperation("list_preferences", err, time.Since(start))
+
+	return preferences, err
+}
+
+func (s *Storage) DeletePreferences(ctx context.Context, userID string) error {
+	start := time.Now()
+	err := s.storage.DeletePreferences(ctx, userID)
+	ObserveStorageOperation("delete_preferences", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) SetOptOut(ctx context.Context, optOut storage.OptOut) error {
+	start := time.Now()
+	err := s.storage.SetOptOut(ctx, optOut)
+	ObserveStorageOperation("set_opt_out", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) DeleteOptOut(ctx context.Context, eventID, userID string) error {
+	start := time.Now()
+	err := s.storage.DeleteOptOut(ctx, eventID, userID)
+	ObserveStorageOperation("delete_opt_out", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ListOptOuts(ctx context.Context, userID string) ([]*storage.OptOut, error) {
+	start := time.Now()
+	optOuts, err := s.storage.ListOptOuts(ctx, userID)
+	ObserveStorageOperation("list_opt_outs", err, time.Since(start))
+
+	return optOuts, err
+}
+
+func (s *Storage) CreateCalendar(ctx context.Context, calendar storage.Calendar) error {
+	start := time.Now()
+	err := s.storage.CreateCalendar(ctx, calendar)
+	ObserveStorageOperation("create_calendar", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) UpdateCalendar(ctx context.Context, calendar storage.Calendar) error {
+	start := time.Now()
+	err := s.storage.UpdateCalendar(ctx, calendar)
+	ObserveStorageOperation("update_calendar", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) DeleteCalendar(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.storage.DeleteCalendar(ctx, id)
+	ObserveStorageOperation("delete_calendar", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, id string) (*storage.Calendar, error) {
+	start := time.Now()
+	calendar, err := s.storage.GetCalendar(ctx, id)
+	ObserveStorageOperation("get_calendar", err, time.Since(start))
+
+	return calendar, err
+}
+
+func (s *Storage) ListCalendars(ctx context.Context, userID string) ([]*storage.Calendar, error) {
+	start := time.Now()
+	calendars, err := s.storage.ListCalendars(ctx, userID)
+	ObserveStorageOperation("list_calendars", err, time.Since(start))
+
+	return calendars, err
+}
+
+func (s *Storage) ListCalendarEvents(ctx context.Context, calendarID string) ([]*storage.Event, error) {
+	start := time.Now()
+	events, err := s.storage.ListCalendarEvents(ctx, calendarID)
+	ObserveStorageOperation("list_calendar_events", err, time.Since(start))
+
+	return events, err
+}
+
+func (s *Storage) SetShare(ctx context.Context, share storage.Share) error {
+	start := time.Now()
+	err := s.storage.SetShare(ctx, share)
+	ObserveStorageOperation("set_share", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) DeleteShare(ctx context.Context, calendarID, userID string) error {
+	start := time.Now()
+	err := s.storage.DeleteShare(ctx, calendarID, userID)
+	ObserveStorageOperation("delete_share", err, time.Since(start))
+
+	return err
+}
+
+func (s *Storage) ListShares(ctx context.Context, calendarID string) ([]*storage.Share, error) {
+	start := time.Now()
+	shares, err := s.storage.ListShares(ctx, calendarID)
+	ObserveStorageOperation("list_shares", err, time.Since(start))
+
+	return shares, err
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.storage.Ping(ctx)
+}
+
+func (s *Storage) Close(ctx context.Context) error {
+	return s.storage.Close(ctx)
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app/mocks"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	testErr := errors.New("test error")
+
+	mockStorage := new(mocks.Storage)
+	mockStorage.On("CreateEvent", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+	mockStorage.On("CreateEvent", ctx, mock.Anything, mock.Anything).Return(testErr).Once()
+
+	s := NewStorage(mockStorage)
+
+	okCounter := storageOperationsTotal.WithLabelValues("create_event", statusOK)
+	errCounter := storageOperationsTotal.WithLabelValues("create_event", statusError)
+
+	okBefore := testutil.ToFloat64(okCounter)
+	errBefore := testutil.ToFloat64(errCounter)
+
+	err := s.CreateEvent(ctx, storage.Event{ID: "1"}, nil)
+	require.NoError(t, err)
+
+	err = s.CreateEvent(ctx, storage.Event{ID: "1"}, nil)
+	require.ErrorIs(t, err, testErr)
+
+	require.Equal(t, okBefore+1, testutil.ToFloat64(okCounter))
+	require.Equal(t, errBefore+1, testutil.ToFloat64(errCounter))
+}
@@ -1,22 +1,40 @@
 package rabbit
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrConnectionClosed = errors.New("connection to rabbit is closed")
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/queue/rabbit")
+
 const (
 	queueName    = "events"
 	exchangeName = "events_exchange"
+
+	// delayQueueExpiry is how long an idle delay queue outlives its last message.
+	delayQueueExpiry = time.Hour
 )
 
+// Client publishes notifications and consumes them. Publishing is serialized, the scheduler
+// adds reminders and digests from different goroutines.
 type Client struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
+	mu      sync.Mutex
 }
 
 func New(cfg config.RabbitConf) *Client {
@@ -72,6 +90,15 @@ func New(cfg config.RabbitConf) *Client {
 	}
 }
 
+// Ping reports whether the connection to the broker is still open.
+func (c *Client) Ping(_ context.Context) error {
+	if c.conn.IsClosed() {
+		return ErrConnectionClosed
+	}
+
+	return nil
+}
+
 func (c *Client) Close() error {
 	err := c.channel.Close()
 	if err != nil {
@@ -81,18 +108,47 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
-func (c *Client) Add(message app.Notification) error {
+// Add publishes the notification, one with DeliverAt in the future waits in a delay queue and
+// reaches the events queue when it is due.
+func (c *Client) Add(ctx context.Context, message app.Notification) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exchange, routingKey := exchangeName, ""
+
+	if delay := time.Until(message.DeliverAt); delay > 0 {
+		exchange = ""
+
+		routingKey, err = c.declareDelayQueue(delay)
+		if err != nil {
+			return fmt.Errorf("failed to declare delay queue: %w", err)
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, exchangeName+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(exchangeName),
+		),
+	)
+	defer func() { tracing.Finish(span, err) }()
+
 	body, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headersCarrier(headers))
+
 	err = c.channel.Publish(
-		exchangeName,
-		"",
+		exchange,
+		routingKey,
 		false,
 		false,
 		amqp.Publishing{
+			Headers:     headers,
 			ContentType: "text/plain",
 			Body:        body,
 		})
@@ -103,6 +159,30 @@ func (c *Client) Add(message app.Notification) error {
 	return nil
 }
 
+// declareDelayQueue returns the queue holding messages for the delay rounded up to a minute.
+// Messages expire to the exchange of the events queue. The TTL is set on the queue rather than
+// on messages, so a message never waits behind a later one, and the queue is removed once idle.
+func (c *Client) declareDelayQueue(delay time.Duration) (string, error) {
+	delay = (delay + time.Minute - 1).Truncate(time.Minute)
+	name := fmt.Sprintf("%s.delay.%dm", queueName, int64(delay/time.Minute))
+
+	_, err := c.channel.QueueDeclare(
+		name,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-expires":                 (delay + delayQueueExpiry).Milliseconds(),
+			"x-dead-letter-exchange":    exchangeName,
+			"x-dead-letter-routing-key": "",
+		},
+	)
+
+	return name, err
+}
+
 func (c *Client) Get() <-chan amqp.Delivery {
 	consume, err := c.channel.Consume(
 		queueName,
@@ -119,3 +199,42 @@ func (c *Client) Get() <-chan amqp.Delivery {
 
 	return consume
 }
+
+// StartConsumerSpan starts a span for processing of delivery that continues
+// the trace injected by the publisher into the message headers.
+func StartConsumerSpan(ctx context.Context, delivery amqp.Delivery) (context.Context, trace.Span) {
+	parent := otel.GetTextMapPropagator().Extract(ctx, headersCarrier(delivery.Headers))
+
+	return tracer.Start(parent, queueName+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingDestinationName(queueName),
+		),
+	)
+}
+
+// headersCarrier adapts AMQP message headers to propagation.TextMapCarrier.
+type headersCarrier amqp.Table
+
+func (c headersCarrier) Get(key string) string {
+	value, ok := c[key].(string)
+	if !ok {
+		return ""
+	}
+
+	return value
+}
+
+func (c headersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long a key may stay unused before its bucket is dropped.
+const idleTimeout = 10 * time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter is a set of token buckets keyed by client IP address.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	limit     rate.Limit
+	burst     int
+	lastSweep time.Time
+}
+
+func New(rps float64, burst int) *Limiter {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false and the time after which the request may be retried.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, 0
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
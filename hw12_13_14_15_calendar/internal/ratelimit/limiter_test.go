@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("burst exhausted", func(t *testing.T) {
+		limiter := New(1, 2)
+
+		for i := 0; i < 2; i++ {
+			ok, _ := limiter.Allow("user")
+			require.True(t, ok)
+		}
+
+		ok, retryAfter := limiter.Allow("user")
+		require.False(t, ok)
+		require.Positive(t, retryAfter)
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		limiter := New(1, 1)
+
+		ok, _ := limiter.Allow("user 1")
+		require.True(t, ok)
+
+		ok, _ = limiter.Allow("user 1")
+		require.False(t, ok)
+
+		ok, _ = limiter.Allow("user 2")
+		require.True(t, ok)
+	})
+}
@@ -0,0 +1,74 @@
+// Package render turns notifications into the text sent to users. Each notification type has a
+// template of the same name, the built-in ones can be replaced by files in a directory.
+package render
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+)
+
+var ErrUnknownType = errors.New("unknown notification type")
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+type Renderer struct {
+	templates *template.Template
+}
+
+// New parses the built-in templates and then the *.tmpl files of dir, which override the
+// templates they define. An empty dir keeps the built-in ones.
+func New(dir string) (*Renderer, error) {
+	templates, err := template.ParseFS(builtin, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			text, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err = templates.New(filepath.Base(file)).Parse(string(text)); err != nil {
+				return nil, fmt.Errorf("template %s: %w", file, err)
+			}
+		}
+	}
+
+	return &Renderer{templates: templates}, nil
+}
+
+// Render returns the text of the notification, one without a type is a reminder.
+func (r *Renderer) Render(notification app.Notification) (string, error) {
+	name := notification.Type
+	if name == "" {
+		name = app.NotificationReminder
+	}
+
+	tmpl := r.templates.Lookup(name)
+	if tmpl == nil {
+		return "", fmt.Errorf("%s: %w", name, ErrUnknownType)
+	}
+
+	var text strings.Builder
+
+	if err := tmpl.Execute(&text, notification); err != nil {
+		return "", fmt.Errorf("template %s: %w", name, err)
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,81 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	renderer, err := New("")
+	require.NoError(t, err)
+
+	location := &app.Location{Address: "Red Square", Point: &app.Point{Latitude: 55.75, Longitude: 37.62}}
+
+	tests := []struct {
+		name         string
+		notification app.Notification
+		want         string
+	}{
+		{
+			name:         "reminder",
+			notification: app.Notification{Type: app.NotificationReminder, Title: "Standup", Date: "2025-03-01 09:00"},
+			want:         "event 'Standup' on 2025-03-01 09:00",
+		},
+		{
+			name: "reminder without type",
+			notification: app.Notification{
+				Title: "Parade", Date: "2025-05-09 10:00", Location: location, MeetingURL: "https://meet.example.com/p",
+			},
+			want: "event 'Parade' on 2025-05-09 10:00 at Red Square (55.750000, 37.620000), " +
+				"join at https://meet.example.com/p",
+		},
+		{
+			name: "digest",
+			notification: app.Notification{Type: app.NotificationDigest, Date: "2025-03-01", Agenda: []app.AgendaItem{
+				{Title: "Standup", Start: "09:00", End: "09:15", MeetingURL: "https://meet.example.com/s"},
+				{Title: "Lunch", Start: "13:00", End: "14:00", Location: &app.Location{Address: "Cafe"}},
+			}},
+			want: "agenda for 2025-03-01:\n" +
+				"09:00-09:15 Standup, join at https://meet.example.com/s\n" +
+				"13:00-14:00 Lunch at Cafe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := renderer.Render(tt.notification)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, text)
+		})
+	}
+
+	_, err = renderer.Render(app.Notification{Type: "weekly"})
+	require.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestRenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	override := `{{define "reminder"}}Reminder: {{.Title}}{{end}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reminder.tmpl"), []byte(override), 0o600))
+
+	renderer, err := New(dir)
+	require.NoError(t, err)
+
+	text, err := renderer.Render(app.Notification{Title: "Standup"})
+	require.NoError(t, err)
+	require.Equal(t, "Reminder: Standup", text)
+
+	// Templates without an override stay built-in.
+	text, err = renderer.Render(app.Notification{Type: app.NotificationDigest, Date: "2025-03-01"})
+	require.NoError(t, err)
+	require.Equal(t, "agenda for 2025-03-01:", text)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("{{"), 0o600))
+
+	_, err = New(dir)
+	require.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h Handler) CreateCalendar(ctx context.Context, req *pb.CalendarRequest) (*pb.Response, error) {
+	calendar := fromPbCalendar(req.GetCalendar())
+	ctx = logger.ContextWith(ctx, "calendar_id", calendar.ID)
+
+	err := h.app.CreateCalendar(ctx, calendar)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) GetCalendar(ctx context.Context, req *pb.GetCalendarRequest) (*pb.CalendarResponse, error) {
+	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "calendar_id", id)
+
+	calendar, err := h.app.GetCalendar(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.CalendarResponse{Resp: &pb.Response{}, Calendar: toPbCalendar(calendar)}, nil
+}
+
+func (h Handler) UpdateCalendar(ctx context.Context, req *pb.CalendarRequest) (*pb.Response, error) {
+	calendar := fromPbCalendar(req.GetCalendar())
+	ctx = logger.ContextWith(ctx, "calendar_id", calendar.ID)
+
+	err := h.app.UpdateCalendar(ctx, calendar.ID, calendar)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) DeleteCalendar(ctx context.Context, req *pb.DeleteCalendarRequest) (*pb.Response, error) {
+	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "calendar_id", id)
+
+	err := h.app.DeleteCalendar(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) ListCalendars(ctx context.Context, _ *pb.ListCalendarsRequest) (*pb.ListCalendarsResponse, error) {
+	calendars, err := h.app.ListCalendars(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	resp := pb.ListCalendarsResponse{
+		Resp:      &pb.Response{},
+		Calendars: make([]*pb.Calendar, 0, len(calendars)),
+	}
+
+	for _, calendar := range calendars {
+		resp.Calendars = append(resp.Calendars, toPbCalendar(calendar))
+	}
+
+	return &resp, nil
+}
+
+func (h Handler) ShareCalendar(ctx context.Context, req *pb.ShareRequest) (*pb.Response, error) {
+	share := req.GetShare()
+	ctx = logger.ContextWith(ctx, "calendar_id", share.GetCalendarId())
+
+	err := h.app.ShareCalendar(ctx, share.GetCalendarId(), share.GetUserId(), share.GetPermission())
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) UnshareCalendar(ctx context.Context, req *pb.UnshareRequest) (*pb.Response, error) {
+	ctx = logger.ContextWith(ctx, "calendar_id", req.GetCalendarId())
+
+	err := h.app.UnshareCalendar(ctx, req.GetCalendarId(), req.GetUserId())
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) ListShares(ctx context.Context, req *pb.ListSharesRequest) (*pb.ListSharesResponse, error) {
+	ctx = logger.ContextWith(ctx, "calendar_id", req.GetCalendarId())
+
+	shares, err := h.app.ListShares(ctx, req.GetCalendarId())
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	resp := pb.ListSharesResponse{
+		Resp:   &pb.Response{},
+		Shares: make([]*pb.Share, 0, len(shares)),
+	}
+
+	for _, share := range shares {
+		resp.Shares = append(resp.Shares, &pb.Share{
+			CalendarId: share.CalendarID,
+			UserId:     share.UserID,
+			Permission: share.Permission,
+		})
+	}
+
+	return &resp, nil
+}
+
+func fromPbCalendar(calendar *pb.Calendar) app.Calendar {
+	return app.Calendar{
+		ID:       calendar.GetId(),
+		Name:     calendar.GetName(),
+		Color:    calendar.GetColor(),
+		TimeZone: calendar.GetTimeZone(),
+	}
+}
+
+func toPbCalendar(calendar app.Calendar) *pb.Calendar {
+	return &pb.Calendar{
+		Id:         calendar.ID,
+		OwnerId:    calendar.OwnerID,
+		Name:       calendar.Name,
+		Color:      calendar.Color,
+		TimeZone:   calendar.TimeZone,
+		Permission: calendar.Permission,
+	}
+}
+
+// statusError maps application errors to gRPC status codes.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, app.ErrEventNotFound), errors.Is(err, app.ErrCalendarNotFound),
+		errors.Is(err, app.ErrShareNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, app.ErrAccessDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, app.ErrCalendarNotEmpty):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, app.ErrInvalidPermission), errors.Is(err, app.ErrInvalidColor),
+		errors.Is(err, app.ErrInvalidTimeZone), errors.Is(err, app.ErrEmptyCalendarName),
+		errors.Is(err, app.ErrEmptyCalendarID), errors.Is(err, app.ErrInvalidTag),
+		errors.Is(err, app.ErrTooManyTags), errors.Is(err, app.ErrInvalidCategory),
+		errors.Is(err, app.ErrInvalidLocation), errors.Is(err, app.ErrInvalidMeetingURL),
+		errors.Is(err, app.ErrInvalidArea):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -2,9 +2,13 @@ package internalgrpc
 
 import (
 	"context"
+	"errors"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Handler struct {
@@ -15,17 +19,38 @@ type Handler struct {
 }
 
 func (h Handler) CreateEvent(ctx context.Context, req *pb.CreateRequest) (*pb.Response, error) {
-	err := h.app.CreateEvent(ctx, req.GetId(), req.GetTitle())
+	ctx = logger.ContextWith(ctx, "event_id", req.GetId())
+
+	err := h.app.CreateEvent(ctx, req.GetId(), req.GetTitle(), req.GetCalendarId())
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		return renderErrorResponse(err), err
 	}
 
 	return &pb.Response{}, nil
 }
 
+func (h Handler) GetEvent(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	event, err := h.app.GetEvent(ctx, id)
+	if errors.Is(err, app.ErrEventNotFound) || errors.Is(err, app.ErrAccessDenied) {
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, statusError(err)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return &pb.GetResponse{Resp: renderErrorResponse(err)}, err
+	}
+
+	return &pb.GetResponse{Resp: &pb.Response{}, Event: toPbEvent(event)}, nil
+}
+
 func (h Handler) UpdateEvent(ctx context.Context, req *pb.UpdateRequest) (*pb.Response, error) {
 	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "event_id", id)
 
 	event := app.Event{
 		ID:          id,
@@ -35,11 +60,17 @@ func (h Handler) UpdateEvent(ctx context.Context, req *pb.UpdateRequest) (*pb.Re
 		Description: req.GetEvent().GetDescription(),
 		UserID:      req.GetEvent().GetUserId(),
 		NotifyDays:  req.GetEvent().GetNotifyDays(),
+		CalendarID:  req.GetEvent().GetCalendarId(),
+		Category:    req.GetEvent().GetCategory(),
+		Color:       req.GetEvent().GetColor(),
+		Tags:        req.GetEvent().GetTags(),
+		Location:    fromPbLocation(req.GetEvent().GetLocation()),
+		MeetingURL:  req.GetEvent().GetMeetingUrl(),
 	}
 
 	err := h.app.UpdateEvent(ctx, id, event)
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		return renderErrorResponse(err), err
 	}
 
@@ -48,10 +79,11 @@ func (h Handler) UpdateEvent(ctx context.Context, req *pb.UpdateRequest) (*pb.Re
 
 func (h Handler) DeleteEvent(ctx context.Context, req *pb.DeleteRequest) (*pb.Response, error) {
 	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "event_id", id)
 
 	err := h.app.DeleteEvent(ctx, id)
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		return renderErrorResponse(err), err
 	}
 
@@ -59,9 +91,16 @@ func (h Handler) DeleteEvent(ctx context.Context, req *pb.DeleteRequest) (*pb.Re
 }
 
 func (h Handler) ListEvents(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
-	events, err := h.app.ListEvents(ctx, req.GetDate(), req.GetPeriod())
+	filter := app.ListFilter{
+		CalendarID: req.GetCalendarId(),
+		Category:   req.GetCategory(),
+		Tags:       req.GetTags(),
+		Near:       fromPbArea(req.GetNear()),
+	}
+
+	events, err := h.app.ListEvents(ctx, req.GetDate(), req.GetPeriod(), filter)
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		return &pb.ListResponse{Resp: renderErrorResponse(err)}, err
 	}
 
@@ -71,20 +110,253 @@ func (h Handler) ListEvents(ctx context.Context, req *pb.ListRequest) (*pb.ListR
 	}
 
 	for _, event := range events {
-		resp.Events = append(resp.Events, &pb.Event{
-			Id:          event.ID,
-			Title:       event.Title,
-			StartDate:   event.StartDate,
-			EndDate:     event.EndDate,
-			Description: event.Description,
-			UserId:      event.UserID,
-			NotifyDays:  event.NotifyDays,
+		resp.Events = append(resp.Events, toPbEvent(event))
+	}
+
+	return &resp, nil
+}
+
+func (h Handler) SuggestTags(ctx context.Context, req *pb.SuggestTagsRequest) (*pb.SuggestTagsResponse, error) {
+	tags, err := h.app.SuggestTags(ctx, req.GetPrefix(), int(req.GetLimit()))
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return &pb.SuggestTagsResponse{Resp: renderErrorResponse(err)}, err
+	}
+
+	resp := pb.SuggestTagsResponse{
+		Resp: &pb.Response{},
+		Tags: make([]*pb.Tag, 0, len(tags)),
+	}
+
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, &pb.Tag{Name: tag.Name, Count: int64(tag.Count)})
+	}
+
+	return &resp, nil
+}
+
+func (h Handler) ListTrash(ctx context.Context, _ *pb.ListTrashRequest) (*pb.ListResponse, error) {
+	events, err := h.app.ListTrash(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return &pb.ListResponse{Resp: renderErrorResponse(err)}, err
+	}
+
+	resp := pb.ListResponse{
+		Resp:   &pb.Response{},
+		Events: make([]*pb.Event, 0, len(events)),
+	}
+
+	for _, event := range events {
+		resp.Events = append(resp.Events, toPbEvent(event))
+	}
+
+	return &resp, nil
+}
+
+func (h Handler) RestoreEvent(ctx context.Context, req *pb.RestoreRequest) (*pb.Response, error) {
+	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	err := h.app.RestoreEvent(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return renderErrorResponse(err), err
+	}
+
+	return &pb.Response{}, nil
+}
+
+func (h Handler) GetEventHistory(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	id := req.GetId()
+	ctx = logger.ContextWith(ctx, "event_id", id)
+
+	entries, err := h.app.GetEventHistory(ctx, id)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		return &pb.HistoryResponse{Resp: renderErrorResponse(err)}, err
+	}
+
+	resp := pb.HistoryResponse{
+		Resp:    &pb.Response{},
+		Entries: make([]*pb.AuditEntry, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		pbEntry := &pb.AuditEntry{
+			Id:        entry.ID,
+			EventId:   entry.EventID,
+			Actor:     entry.Actor,
+			Action:    entry.Action,
+			CreatedAt: entry.CreatedAt,
+		}
+
+		if entry.Before != nil {
+			pbEntry.Before = toPbEvent(*entry.Before)
+		}
+
+		if entry.After != nil {
+			pbEntry.After = toPbEvent(*entry.After)
+		}
+
+		resp.Entries = append(resp.Entries, pbEntry)
+	}
+
+	return &resp, nil
+}
+
+func (h Handler) WatchEvents(req *pb.WatchRequest, stream pb.EventService_WatchEventsServer) error {
+	ctx := stream.Context()
+
+	changes, err := h.app.WatchEvents(ctx, app.WatchFilter{
+		UserID:   req.GetUserId(),
+		FromDate: req.GetFromDate(),
+		ToDate:   req.GetToDate(),
+		Epoch:    req.GetEpoch(),
+		FromSeq:  req.GetFromSeq(),
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+
+		if errors.Is(err, app.ErrWatchResync) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for change := range changes {
+		err = stream.Send(&pb.EventChange{
+			Epoch: change.Epoch,
+			Seq:   change.Seq,
+			Type:  change.Type,
+			Event: toPbEvent(change.Event),
 		})
+		if err != nil {
+			h.logger.ErrorContext(ctx, err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BatchEvents applies several operations at once. An aborted atomic batch is not an RPC
+// error: the response carries the per-operation results so the client can see what failed.
+func (h Handler) BatchEvents(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	ops := make([]app.BatchOperation, 0, len(req.GetOperations()))
+	for _, op := range req.GetOperations() {
+		ops = append(ops, app.BatchOperation{Type: op.GetType(), Event: fromPbEvent(op.GetEvent())})
+	}
+
+	results, err := h.app.BatchEvents(ctx, ops, req.GetAtomic())
+
+	switch {
+	case errors.Is(err, app.ErrEmptyBatch), errors.Is(err, app.ErrBatchTooLarge):
+		h.logger.ErrorContext(ctx, err.Error())
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil && !errors.Is(err, app.ErrBatchAborted):
+		h.logger.ErrorContext(ctx, err.Error())
+		return &pb.BatchResponse{Resp: renderErrorResponse(err)}, err
+	}
+
+	resp := pb.BatchResponse{
+		Resp:    &pb.Response{},
+		Results: make([]*pb.OperationResult, 0, len(results)),
+	}
+
+	if err != nil {
+		h.logger.WarnContext(ctx, err.Error())
+		resp.Resp = renderErrorResponse(err)
+	}
+
+	for _, result := range results {
+		opResult := &pb.OperationResult{Id: result.ID}
+		if result.Err != nil {
+			opResult.Error = true
+			opResult.Message = result.Err.Error()
+		}
+
+		resp.Results = append(resp.Results, opResult)
 	}
 
 	return &resp, nil
 }
 
+func fromPbEvent(event *pb.Event) app.Event {
+	return app.Event{
+		ID:          event.GetId(),
+		Title:       event.GetTitle(),
+		StartDate:   event.GetStartDate(),
+		EndDate:     event.GetEndDate(),
+		Description: event.GetDescription(),
+		UserID:      event.GetUserId(),
+		NotifyDays:  event.GetNotifyDays(),
+		CalendarID:  event.GetCalendarId(),
+		Category:    event.GetCategory(),
+		Color:       event.GetColor(),
+		Tags:        event.GetTags(),
+		Location:    fromPbLocation(event.GetLocation()),
+		MeetingURL:  event.GetMeetingUrl(),
+	}
+}
+
+func toPbEvent(event app.Event) *pb.Event {
+	return &pb.Event{
+		Id:          event.ID,
+		Title:       event.Title,
+		StartDate:   event.StartDate,
+		EndDate:     event.EndDate,
+		Description: event.Description,
+		UserId:      event.UserID,
+		NotifyDays:  event.NotifyDays,
+		DeletedAt:   event.DeletedAt,
+		CalendarId:  event.CalendarID,
+		Category:    event.Category,
+		Color:       event.Color,
+		Tags:        event.Tags,
+		Location:    toPbLocation(event.Location),
+		MeetingUrl:  event.MeetingURL,
+	}
+}
+
+func fromPbLocation(location *pb.Location) *app.Location {
+	if location == nil {
+		return nil
+	}
+
+	converted := &app.Location{Address: location.GetAddress()}
+	if point := location.GetPoint(); point != nil {
+		converted.Point = &app.Point{Latitude: point.GetLatitude(), Longitude: point.GetLongitude()}
+	}
+
+	return converted
+}
+
+func toPbLocation(location *app.Location) *pb.Location {
+	if location == nil {
+		return nil
+	}
+
+	converted := &pb.Location{Address: location.Address}
+	if location.Point != nil {
+		converted.Point = &pb.Point{Latitude: location.Point.Latitude, Longitude: location.Point.Longitude}
+	}
+
+	return converted
+}
+
+func fromPbArea(area *pb.Area) *app.Area {
+	if area == nil {
+		return nil
+	}
+
+	return &app.Area{
+		Center: app.Point{Latitude: area.GetCenter().GetLatitude(), Longitude: area.GetCenter().GetLongitude()},
+		Radius: area.GetRadius(),
+	}
+}
+
 func renderErrorResponse(err error) *pb.Response {
 	return &pb.Response{
 		Error:   true,
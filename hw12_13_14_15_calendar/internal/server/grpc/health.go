@@ -0,0 +1,26 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthHandler implements the gRPC health checking protocol on top of health.Health.
+type HealthHandler struct {
+	health *health.Health
+
+	healthpb.UnimplementedHealthServer
+}
+
+func (h HealthHandler) Check(
+	ctx context.Context,
+	_ *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	if _, ok := h.health.Check(ctx); !ok {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
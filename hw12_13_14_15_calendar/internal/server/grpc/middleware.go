@@ -3,14 +3,107 @@ package internalgrpc
 import (
 	"context"
 	"fmt"
+	"math"
+	"net"
+	"strconv"
 	"time"
 
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	requestIDKey       = "x-request-id"
+	userIDKey          = "x-user-id"
+	retryAfterKey      = "retry-after"
+	maxRequestIDLength = 128
+)
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/server/grpc")
+
+// requestIDMiddleware propagates x-request-id metadata from the client or generates a new one
+// and puts it into the request context, so every log line of the request carries it.
+func (s *Server) requestIDMiddleware(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	requestID := newRequestID(ctx)
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID)); err != nil {
+		s.logger.WarnContext(ctx, "failed to set request id header: "+err.Error())
+	}
+
+	return handler(logger.WithRequestID(ctx, requestID), req)
+}
+
+// sessionMiddleware scopes storage reads to the request, so a request reads its own writes
+// even when the storage serves lists from replicas.
+func (s *Server) sessionMiddleware(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	return handler(storage.WithSession(ctx), req)
+}
+
+// streamSessionMiddleware is sessionMiddleware for streaming calls.
+func (s *Server) streamSessionMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: storage.WithSession(ss.Context())})
+}
+
+// actorMiddleware puts x-user-id metadata into the request context, the audit log attributes changes to it.
+func (s *Server) actorMiddleware(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	return handler(withActor(ctx), req)
+}
+
+// streamActorMiddleware is actorMiddleware for streaming calls, WatchEvents filters changes
+// by the calendars the actor can read.
+func (s *Server) streamActorMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: withActor(ss.Context())})
+}
+
+func withActor(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		if actor := md.Get(userIDKey); len(actor) > 0 && actor[0] != "" {
+			return app.WithActor(ctx, actor[0])
+		}
+	}
+
+	return ctx
+}
+
 func (s *Server) loggingMiddleware(
 	ctx context.Context,
 	req interface{},
@@ -34,11 +127,210 @@ func (s *Server) loggingMiddleware(
 		getUserAgent(ctx),
 	)
 
-	s.logger.Info(msg)
+	s.logger.InfoContext(ctx, msg)
+
+	return resp, err
+}
+
+func (s *Server) streamLoggingMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx := ss.Context()
+
+	ctx = logger.WithRequestID(ctx, newRequestID(ctx))
+	start := time.Now()
+
+	err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+
+	msg := fmt.Sprintf("%s [%s] %s %s %d %d %s",
+		getClientIP(ctx),
+		time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+		info.FullMethod,
+		"HTTP/2",
+		status.Code(err),
+		time.Since(start).Milliseconds(),
+		getUserAgent(ctx),
+	)
+
+	s.logger.InfoContext(ctx, msg)
+
+	return err
+}
+
+// contextServerStream overrides the context of a wrapped grpc.ServerStream.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) metricsMiddleware(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	start := time.Now()
+
+	resp, err = handler(ctx, req)
+
+	metrics.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
 
 	return resp, err
 }
 
+// streamMetricsMiddleware is metricsMiddleware for streaming calls, the latency of a stream is
+// how long it stayed open.
+func (s *Server) streamMetricsMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+
+	err := handler(srv, ss)
+
+	metrics.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+
+	return err
+}
+
+// rateLimitMiddleware throttles clients by IP address. The x-user-id metadata is not authenticated,
+// a client could get a fresh bucket with every request by changing it.
+func (s *Server) rateLimitMiddleware(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if s.limiter == nil || info.FullMethod == healthpb.Health_Check_FullMethodName {
+		return handler(ctx, req)
+	}
+
+	ok, retryAfter := s.limiter.Allow(clientKey(ctx))
+	if !ok {
+		seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		if err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, seconds)); err != nil {
+			s.logger.WarnContext(ctx, "failed to set retry-after header: "+err.Error())
+		}
+
+		return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s seconds", seconds)
+	}
+
+	return handler(ctx, req)
+}
+
+// streamRateLimitMiddleware is rateLimitMiddleware for streaming calls. Only opening a stream
+// takes a token, the messages of an open stream are not throttled.
+func (s *Server) streamRateLimitMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if s.limiter == nil || info.FullMethod == healthpb.Health_Watch_FullMethodName {
+		return handler(srv, ss)
+	}
+
+	ok, retryAfter := s.limiter.Allow(clientKey(ss.Context()))
+	if !ok {
+		seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		if err := ss.SetHeader(metadata.Pairs(retryAfterKey, seconds)); err != nil {
+			s.logger.WarnContext(ss.Context(), "failed to set retry-after header: "+err.Error())
+		}
+
+		return status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s seconds", seconds)
+	}
+
+	return handler(srv, ss)
+}
+
+func (s *Server) tracingMiddleware(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	ctx, span := tracer.Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCMethod(info.FullMethod),
+		),
+	)
+	defer func() { tracing.Finish(span, err) }()
+
+	resp, err = handler(ctx, req)
+
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+
+	return resp, err
+}
+
+// streamTracingMiddleware is tracingMiddleware for streaming calls, the span lasts while the
+// stream is open.
+func (s *Server) streamTracingMiddleware(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	ctx := ss.Context()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	ctx, span := tracer.Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCMethod(info.FullMethod),
+		),
+	)
+	defer func() { tracing.Finish(span, err) }()
+
+	err = handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+
+	return err
+}
+
+// metadataCarrier adapts incoming gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
 func getClientIP(ctx context.Context) string {
 	peerInfo, ok := peer.FromContext(ctx)
 	if ok && peerInfo.Addr != nil {
@@ -48,6 +340,39 @@ func getClientIP(ctx context.Context) string {
 	return "unknown IP address"
 }
 
+func clientKey(ctx context.Context) string {
+	if peerInfo, ok := peer.FromContext(ctx); ok && peerInfo.Addr != nil {
+		host, _, err := net.SplitHostPort(peerInfo.Addr.String())
+		if err == nil {
+			return "ip:" + host
+		}
+
+		return "ip:" + peerInfo.Addr.String()
+	}
+
+	return "unknown"
+}
+
+// newRequestID returns the request ID sent by the client or generates a new one.
+func newRequestID(ctx context.Context) string {
+	requestID := getRequestID(ctx)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+
+	return requestID
+}
+
+func getRequestID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		if requestID := md.Get(requestIDKey); len(requestID) > 0 {
+			return requestID[0]
+		}
+	}
+	return ""
+}
+
 func getUserAgent(ctx context.Context) string {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if ok {
@@ -8,15 +8,20 @@ import (
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/ratelimit"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
 type Server struct {
-	srv    *grpc.Server
-	logger Logger
-	app    Application
-	cfg    *config.Config
+	srv     *grpc.Server
+	logger  Logger
+	app     Application
+	health  *health.Health
+	limiter *ratelimit.Limiter
+	cfg     *config.Config
 }
 
 type Logger interface {
@@ -24,34 +29,90 @@ type Logger interface {
 	Error(msg string)
 	Warn(msg string)
 	Debug(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+	WarnContext(ctx context.Context, msg string)
+	DebugContext(ctx context.Context, msg string)
 }
 
 type Application interface {
-	CreateEvent(ctx context.Context, id string, title string) error
+	CreateEvent(ctx context.Context, id, title, calendarID string) error
+	GetEvent(ctx context.Context, id string) (app.Event, error)
 	UpdateEvent(ctx context.Context, id string, event app.Event) error
+	PutEvent(ctx context.Context, event app.Event) (bool, error)
 	DeleteEvent(ctx context.Context, id string) error
-	ListEvents(ctx context.Context, date, period string) ([]app.Event, error)
+	ListEvents(ctx context.Context, date, period string, filter app.ListFilter) ([]app.Event, error)
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]app.Tag, error)
+	WatchEvents(ctx context.Context, filter app.WatchFilter) (<-chan app.Change, error)
+	BatchEvents(ctx context.Context, ops []app.BatchOperation, atomic bool) ([]app.BatchResult, error)
+	ListTrash(ctx context.Context) ([]app.Event, error)
+	RestoreEvent(ctx context.Context, id string) error
+	GetEventHistory(ctx context.Context, id string) ([]app.AuditEntry, error)
+	CreateCalendar(ctx context.Context, calendar app.Calendar) error
+	GetCalendar(ctx context.Context, id string) (app.Calendar, error)
+	UpdateCalendar(ctx context.Context, id string, calendar app.Calendar) error
+	DeleteCalendar(ctx context.Context, id string) error
+	ListCalendars(ctx context.Context) ([]app.Calendar, error)
+	ListCalendarEvents(ctx context.Context, calendarID string) ([]app.Event, error)
+	ShareCalendar(ctx context.Context, calendarID, userID, permission string) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	ListShares(ctx context.Context, calendarID string) ([]app.Share, error)
 }
 
-func NewServer(cfg config.Config, logger Logger, app Application) *Server {
-	return &Server{
-		logger: logger,
-		app:    app,
-		cfg:    &cfg,
+func NewServer(cfg config.Config, logger Logger, app Application, health *health.Health) *Server {
+	var limiter *ratelimit.Limiter
+	if cfg.Limits.RPS > 0 {
+		limiter = ratelimit.New(cfg.Limits.RPS, cfg.Limits.Burst)
 	}
-}
 
-func (s *Server) Start(ctx context.Context) error {
-	s.srv = grpc.NewServer(
-		grpc.ChainUnaryInterceptor(s.loggingMiddleware),
-	)
+	s := &Server{
+		logger:  logger,
+		app:     app,
+		health:  health,
+		limiter: limiter,
+		cfg:     &cfg,
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			s.requestIDMiddleware,
+			s.sessionMiddleware,
+			s.actorMiddleware,
+			s.loggingMiddleware,
+			s.metricsMiddleware,
+			s.rateLimitMiddleware,
+			s.tracingMiddleware,
+		),
+		grpc.ChainStreamInterceptor(
+			s.streamLoggingMiddleware,
+			s.streamSessionMiddleware,
+			s.streamActorMiddleware,
+			s.streamMetricsMiddleware,
+			s.streamRateLimitMiddleware,
+			s.streamTracingMiddleware,
+		),
+	}
+
+	if cfg.Limits.MaxMessageSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.Limits.MaxMessageSize))
+	}
+
+	// The server is built here, Stop may run before or concurrently with Start.
+	s.srv = grpc.NewServer(opts...)
 
 	reflection.Register(s.srv)
 	pb.RegisterEventServiceServer(s.srv, Handler{
 		app:    s.app,
 		logger: s.logger,
 	})
+	healthpb.RegisterHealthServer(s.srv, HealthHandler{
+		health: s.health,
+	})
 
+	return s
+}
+
+func (s *Server) Start(ctx context.Context) error {
 	addr := net.JoinHostPort(s.cfg.App.Host, s.cfg.App.Port)
 
 	listener, err := net.Listen("tcp", addr)
@@ -0,0 +1,180 @@
+package internalgrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/api/pb"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	memorystorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, cfg config.Config, application Application) pb.EventServiceClient {
+	t.Helper()
+
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	server := NewServer(cfg, logg, application, health.New())
+
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = server.srv.Serve(listener)
+	}()
+	t.Cleanup(server.srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewEventServiceClient(conn)
+}
+
+func TestWatchEventsSharedCalendar(t *testing.T) {
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	application := app.New(logg, memorystorage.New())
+
+	alice := app.WithActor(context.Background(), "alice")
+	require.NoError(t, application.CreateCalendar(alice, app.Calendar{ID: "work", Name: "Work"}))
+	require.NoError(t, application.ShareCalendar(alice, "work", "bob", app.PermissionRead))
+
+	carol := app.WithActor(context.Background(), "carol")
+	require.NoError(t, application.CreateCalendar(carol, app.Calendar{ID: "private", Name: "Private"}))
+
+	client := newTestClient(t, config.Config{}, application)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(metadata.AppendToOutgoingContext(ctx, userIDKey, "bob"), &pb.WatchRequest{})
+	require.NoError(t, err)
+
+	// The stream may not be subscribed yet when the first events are created, so keep creating
+	// them until bob sees one. Carol's event goes first and must never reach him.
+	go func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			_ = application.CreateEvent(carol, fmt.Sprintf("private-%d", i), "Secret", "private")
+			_ = application.CreateEvent(alice, fmt.Sprintf("work-%d", i), "Standup", "work")
+
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	change, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, app.ChangeCreated, change.GetType())
+	require.Equal(t, "work", change.GetEvent().GetCalendarId())
+}
+
+func TestWatchEventsRateLimit(t *testing.T) {
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	client := newTestClient(t, config.Config{Limits: config.LimitsConf{RPS: 1, Burst: 1}},
+		app.New(logg, memorystorage.New()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Streams share the bucket with unary calls, this one takes the only token.
+	_, err := client.GetEvent(ctx, &pb.GetRequest{Id: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	rejected := grpcRequests(t, pb.EventService_WatchEvents_FullMethodName, codes.ResourceExhausted)
+
+	stream, err := client.WatchEvents(ctx, &pb.WatchRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, rejected+1, grpcRequests(t, pb.EventService_WatchEvents_FullMethodName, codes.ResourceExhausted))
+
+	header, err := stream.Header()
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, header.Get(retryAfterKey))
+}
+
+func TestWatchEventsTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	client := newTestClient(t, config.Config{}, app.New(logg, memorystorage.New()))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(),
+		"traceparent", "00-"+traceID+"-00f067aa0ba902b7-01"))
+
+	_, err := client.WatchEvents(ctx, &pb.WatchRequest{})
+	require.NoError(t, err)
+
+	// The span ends with the stream, which ends when the client goes away.
+	cancel()
+
+	require.Eventually(t, func() bool {
+		for _, span := range recorder.Ended() {
+			if span.Name() == pb.EventService_WatchEvents_FullMethodName {
+				return span.SpanKind() == trace.SpanKindServer && span.Parent().TraceID().String() == traceID
+			}
+		}
+
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// grpcRequests returns how many calls of the method finished with the code, per the default registry.
+func grpcRequests(t *testing.T, method string, code codes.Code) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "calendar_grpc_requests_total" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["method"] == method && labels["code"] == code.String() {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,146 @@
+package internalhttp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+const (
+	attachmentsRoute = "/events/{id}/attachments"
+	attachmentField  = "file"
+
+	// multipartMemory is how much of an upload is kept in memory, the rest goes to a temporary file.
+	multipartMemory = 1 << 20
+	// multipartOverhead allows for the boundaries and part headers around the file of an upload.
+	multipartOverhead = 64 << 10
+)
+
+var ErrMissingFile = errors.New("missing file field")
+
+func (h *Handler) registerAttachmentRoutes(r *mux.Router) {
+	r.HandleFunc(attachmentsRoute, h.AddAttachment).Methods(http.MethodPost)
+	r.HandleFunc(attachmentsRoute, h.ListAttachments).Methods(http.MethodGet)
+	r.HandleFunc(attachmentsRoute+"/{attachment_id}", h.GetAttachment).Methods(http.MethodGet)
+	r.HandleFunc(attachmentsRoute+"/{attachment_id}", h.DeleteAttachment).Methods(http.MethodDelete)
+}
+
+// AddAttachment takes a multipart/form-data upload with the content in the "file" field.
+func (h *Handler) AddAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	eventID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	file, header, err := r.FormFile(attachmentField)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, ErrMissingFile)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.app.AddAttachment(ctx, eventID, app.AttachmentUpload{
+		Name:        header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Size:        header.Size,
+		Content:     file,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, AttachmentResponse{Attachment: toAttachment(attachment)})
+}
+
+func (h *Handler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	eventID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	attachments, err := h.app.ListAttachments(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := AttachmentsResponse{Attachments: make([]Attachment, 0, len(attachments))}
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, toAttachment(attachment))
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+// GetAttachment sends the content as a download with the original name.
+func (h *Handler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	ctx = logger.ContextWith(ctx, "event_id", vars["id"])
+
+	attachment, content, err := h.app.OpenAttachment(ctx, vars["id"], vars["attachment_id"])
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": attachment.Name,
+	}))
+	// Uploads are served as they are, browsers must not guess another type from the content.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(w, content); err != nil {
+		h.logger.ErrorContext(ctx, "failed to send attachment: "+err.Error())
+	}
+}
+
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	ctx = logger.ContextWith(ctx, "event_id", vars["id"])
+
+	if err := h.app.DeleteAttachment(ctx, vars["id"], vars["attachment_id"]); err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func toAttachment(attachment app.Attachment) Attachment {
+	return Attachment{
+		ID:          attachment.ID,
+		EventID:     attachment.EventID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt.Format(time.RFC3339),
+	}
+}
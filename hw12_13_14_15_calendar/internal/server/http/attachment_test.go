@@ -0,0 +1,147 @@
+package internalhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	fsblob "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/blob/fs"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	memorystorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	attachmentEvent = "00000000-0000-0000-0000-000000000001"
+	attachmentsPath = "/events/" + attachmentEvent + "/attachments"
+)
+
+func newAttachmentServer(t *testing.T, maxSize int64) string {
+	t.Helper()
+
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	application := app.New(logg, memorystorage.New())
+
+	blobs, err := fsblob.New(t.TempDir())
+	require.NoError(t, err)
+
+	application.EnableAttachments(blobs, app.AttachmentLimits{MaxSize: maxSize})
+
+	owner := app.WithActor(context.Background(), "alice")
+	require.NoError(t, application.CreateCalendar(owner, app.Calendar{ID: "work", Name: "Work"}))
+	require.NoError(t, application.CreateEvent(owner, attachmentEvent, "Standup", "work"))
+
+	cfg := config.Config{
+		Limits:      config.LimitsConf{MaxBodySize: 64},
+		Attachments: config.AttachmentsConf{MaxSize: maxSize},
+	}
+
+	r := mux.NewRouter()
+	handler := &Handler{router: r, app: application, logger: logg}
+	handler.registerAttachmentRoutes(r)
+
+	server := &Server{cfg: &cfg}
+	r.Use(server.actorMiddleware, server.bodyLimitMiddleware)
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func uploadRequest(t *testing.T, url, name, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(attachmentField, name)
+	require.NoError(t, err)
+
+	_, err = io.WriteString(part, content)
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, &body)
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(userIDHeader, "alice")
+
+	return req
+}
+
+func send(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, body
+}
+
+func TestAttachments(t *testing.T) {
+	url := newAttachmentServer(t, 1024)
+
+	// Uploads are bounded by the attachment size, not by the much smaller body limit.
+	content := strings.Repeat("agenda ", 100)
+
+	resp, body := send(t, uploadRequest(t, url+attachmentsPath, "agenda.txt", content))
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var added AttachmentResponse
+	require.NoError(t, json.Unmarshal(body, &added))
+	require.Equal(t, "agenda.txt", added.Attachment.Name)
+	require.Equal(t, int64(len(content)), added.Attachment.Size)
+
+	resp, body = send(t, uploadRequest(t, url+attachmentsPath, "huge.bin", strings.Repeat("x", 2048)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode, string(body))
+
+	get := func(path, user string) (*http.Response, []byte) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		req.Header.Set(userIDHeader, user)
+
+		return send(t, req)
+	}
+
+	resp, body = get(attachmentsPath, "alice")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var listed AttachmentsResponse
+	require.NoError(t, json.Unmarshal(body, &listed))
+	require.Equal(t, []Attachment{added.Attachment}, listed.Attachments)
+
+	resp, body = get(attachmentsPath+"/"+added.Attachment.ID, "alice")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, content, string(body))
+	require.Equal(t, `attachment; filename=agenda.txt`, resp.Header.Get("Content-Disposition"))
+	require.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	resp, _ = get(attachmentsPath+"/"+added.Attachment.ID, "mallory")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodDelete,
+		url+attachmentsPath+"/"+added.Attachment.ID, nil)
+	require.NoError(t, err)
+	req.Header.Set(userIDHeader, "alice")
+
+	resp, _ = send(t, req)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = get(attachmentsPath+"/"+added.Attachment.ID, "alice")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
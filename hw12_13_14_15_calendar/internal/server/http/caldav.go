@@ -0,0 +1,723 @@
+package internalhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/ical"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+const (
+	methodPropfind = "PROPFIND"
+	methodReport   = "REPORT"
+
+	davRoot       = "/dav/"
+	davPrincipals = "/dav/principals/"
+	davCalendars  = "/dav/calendars/"
+	icsExtension  = ".ics"
+	eventLayout   = "2006-01-02 15:04"
+	timeRangeUTC  = "20060102T150405Z"
+	davAllow      = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+	davCompliance = "1, 3, calendar-access"
+	xmlType       = "application/xml; charset=utf-8"
+)
+
+var (
+	ErrUIDMismatch       = errors.New("UID does not match the resource name")
+	ErrNoEvent           = errors.New("no VEVENT in calendar object")
+	ErrUnsupportedReport = errors.New("unsupported report")
+	ErrUIDConflict       = errors.New("event with the UID belongs to another calendar")
+)
+
+// registerCalDAVRoutes serves a CalDAV subset for native clients. Calendars of the actor live
+// under /dav/calendars/{calendar}/ and their events are {event_id}.ics resources.
+func (h *Handler) registerCalDAVRoutes(r *mux.Router) {
+	r.Handle("/.well-known/caldav", http.RedirectHandler(davRoot, http.StatusMovedPermanently))
+
+	dav := r.PathPrefix(davRoot).Subrouter()
+	dav.Use(davAuthMiddleware)
+
+	dav.PathPrefix("/").HandlerFunc(h.DAVOptions).Methods(http.MethodOptions)
+	dav.HandleFunc("/", h.PropfindPrincipal).Methods(methodPropfind)
+	dav.HandleFunc("/principals/{user}/", h.PropfindPrincipal).Methods(methodPropfind)
+	dav.HandleFunc("/calendars/", h.PropfindHome).Methods(methodPropfind)
+	dav.HandleFunc("/calendars/{calendar}{slash:/?}", h.PropfindCalendar).Methods(methodPropfind)
+	dav.HandleFunc("/calendars/{calendar}{slash:/?}", h.ReportCalendar).Methods(methodReport)
+	dav.HandleFunc("/calendars/{calendar}/{resource}", h.GetICS).Methods(http.MethodGet, http.MethodHead)
+	dav.HandleFunc("/calendars/{calendar}/{resource}", h.PutICS).Methods(http.MethodPut)
+	dav.HandleFunc("/calendars/{calendar}/{resource}", h.DeleteICS).Methods(http.MethodDelete)
+	dav.HandleFunc("/calendars/{calendar}/{resource}", h.PropfindICS).Methods(methodPropfind)
+}
+
+// davAuthMiddleware asks native clients for credentials, they do not send a user otherwise.
+func davAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions && app.IsAnonymous(r.Context()) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="calendar"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) DAVOptions(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("DAV", davCompliance)
+	w.Header().Set("Allow", davAllow)
+	w.WriteHeader(http.StatusOK)
+}
+
+// PropfindPrincipal answers the discovery requests, it points the client to the calendar home.
+func (h *Handler) PropfindPrincipal(w http.ResponseWriter, r *http.Request) {
+	requested, ok := h.readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	actor := app.ActorFromContext(r.Context())
+	principal := principalHref(actor)
+
+	props := davProps{
+		propResourceType:         emptyElement(davNamespace, "collection") + emptyElement(davNamespace, "principal"),
+		propDisplayName:          xmlText(actor),
+		propCurrentUserPrincipal: hrefValue(principal),
+		propPrincipalURL:         hrefValue(principal),
+		propCalendarHomeSet:      hrefValue(davCalendars),
+	}
+
+	renderMultistatus(w, props.response(r.URL.Path, requested))
+}
+
+func (h *Handler) PropfindHome(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	requested, ok := h.readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	home := davProps{
+		propResourceType:         emptyElement(davNamespace, "collection"),
+		propDisplayName:          xmlText("calendars"),
+		propCurrentUserPrincipal: hrefValue(principalHref(app.ActorFromContext(ctx))),
+	}
+
+	responses := []davResponse{home.response(davCalendars, requested)}
+
+	if davDepth(r) > 0 {
+		calendars, err := h.app.ListCalendars(ctx)
+		if err != nil {
+			h.renderDAVError(w, r, err)
+			return
+		}
+
+		for _, calendar := range calendars {
+			props, err := h.calendarProps(r, calendar)
+			if err != nil {
+				h.renderDAVError(w, r, err)
+				return
+			}
+
+			responses = append(responses, props.response(calendarHref(calendar.ID), requested))
+		}
+	}
+
+	renderMultistatus(w, responses...)
+}
+
+func (h *Handler) PropfindCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["calendar"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+	r = r.WithContext(ctx)
+
+	requested, ok := h.readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	calendar, err := h.app.GetCalendar(ctx, calendarID)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	props, err := h.calendarProps(r, calendar)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	responses := []davResponse{props.response(calendarHref(calendarID), requested)}
+
+	if davDepth(r) > 0 {
+		events, err := h.app.ListCalendarEvents(ctx, calendarID)
+		if err != nil {
+			h.renderDAVError(w, r, err)
+			return
+		}
+
+		for _, event := range events {
+			responses = append(responses, eventProps(event, false).response(eventHref(event), requested))
+		}
+	}
+
+	renderMultistatus(w, responses...)
+}
+
+// ReportCalendar serves calendar-query with an optional time range and calendar-multiget.
+func (h *Handler) ReportCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["calendar"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+	r = r.WithContext(ctx)
+
+	var req reportRequest
+
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		http.Error(w, err.Error(), readErrorStatus(err))
+
+		return
+	}
+
+	requested := req.Prop.requested()
+	withData := requested == nil || containsName(requested, propCalendarData)
+
+	var (
+		responses []davResponse
+		err       error
+	)
+
+	switch req.XMLName {
+	case reportCalendarQuery:
+		responses, err = h.queryEvents(r, calendarID, req.eventTimeRange(), requested, withData)
+	case reportCalendarMultiget:
+		responses, err = h.multigetEvents(r, calendarID, req.Hrefs, requested, withData)
+	default:
+		err = fmt.Errorf("%s: %w", req.XMLName.Local, ErrUnsupportedReport)
+	}
+
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	renderMultistatus(w, responses...)
+}
+
+func (h *Handler) queryEvents(
+	r *http.Request,
+	calendarID string,
+	tr *timeRange,
+	requested []xml.Name,
+	withData bool,
+) ([]davResponse, error) {
+	from, to, err := parseTimeRange(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := h.app.ListCalendarEvents(r.Context(), calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]davResponse, 0, len(events))
+
+	for _, event := range events {
+		if !overlaps(event, from, to) {
+			continue
+		}
+
+		responses = append(responses, eventProps(event, withData).response(eventHref(event), requested))
+	}
+
+	return responses, nil
+}
+
+func (h *Handler) multigetEvents(
+	r *http.Request,
+	calendarID string,
+	hrefs []string,
+	requested []xml.Name,
+	withData bool,
+) ([]davResponse, error) {
+	responses := make([]davResponse, 0, len(hrefs))
+
+	for _, href := range hrefs {
+		event, err := h.eventByHref(r, calendarID, href)
+
+		switch {
+		case errors.Is(err, app.ErrEventNotFound):
+			responses = append(responses, davResponse{Href: href, Status: statusLine(http.StatusNotFound)})
+		case err != nil:
+			return nil, err
+		default:
+			responses = append(responses, eventProps(event, withData).response(href, requested))
+		}
+	}
+
+	return responses, nil
+}
+
+func (h *Handler) GetICS(w http.ResponseWriter, r *http.Request) {
+	event, err := h.resourceEvent(r)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	body, err := encodeEvents(event)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("ETag", eventETag(event))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// PutICS creates or replaces the event honoring If-Match and If-None-Match preconditions.
+func (h *Handler) PutICS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["calendar"]
+
+	id, ok := hrefEventID(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx = logger.ContextWith(ctx, "event_id", id)
+	r = r.WithContext(ctx)
+
+	calendar, err := h.app.GetCalendar(ctx, calendarID)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	loc, err := time.LoadLocation(calendar.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	events, err := ical.Decode(r.Body, loc)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	if len(events) == 0 {
+		h.renderDAVError(w, r, ErrNoEvent)
+		return
+	}
+
+	if events[0].UID != "" && events[0].UID != id {
+		h.renderDAVError(w, r, fmt.Errorf("%s: %w", events[0].UID, ErrUIDMismatch))
+		return
+	}
+
+	existing, err := h.app.GetEvent(ctx, id)
+
+	switch {
+	case errors.Is(err, app.ErrEventNotFound):
+		existing = app.Event{UserID: app.ActorFromContext(ctx)}
+	case err != nil:
+		h.renderDAVError(w, r, err)
+		return
+	case existing.CalendarID != calendarID:
+		h.renderDAVError(w, r, fmt.Errorf("%s: %w", id, ErrUIDConflict))
+		return
+	}
+
+	if !checkPreconditions(r, existing) {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	created, err := h.app.PutEvent(ctx, fromICalEvent(events[0], existing, id, calendarID))
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	if stored, err := h.app.GetEvent(ctx, id); err == nil {
+		w.Header().Set("ETag", eventETag(stored))
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) DeleteICS(w http.ResponseWriter, r *http.Request) {
+	event, err := h.resourceEvent(r)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	if !checkPreconditions(r, event) {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	if err = h.app.DeleteEvent(r.Context(), event.ID); err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) PropfindICS(w http.ResponseWriter, r *http.Request) {
+	requested, ok := h.readPropfind(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := h.resourceEvent(r)
+	if err != nil {
+		h.renderDAVError(w, r, err)
+		return
+	}
+
+	withData := containsName(requested, propCalendarData)
+
+	renderMultistatus(w, eventProps(event, withData).response(r.URL.Path, requested))
+}
+
+// resourceEvent returns the event addressed by the request path if it belongs to the calendar.
+func (h *Handler) resourceEvent(r *http.Request) (app.Event, error) {
+	return h.eventByHref(r, mux.Vars(r)["calendar"], r.URL.EscapedPath())
+}
+
+func (h *Handler) eventByHref(r *http.Request, calendarID, href string) (app.Event, error) {
+	id, ok := hrefEventID(href)
+	if !ok {
+		return app.Event{}, app.ErrEventNotFound
+	}
+
+	event, err := h.app.GetEvent(r.Context(), id)
+	if err != nil {
+		return app.Event{}, err
+	}
+
+	if event.CalendarID != calendarID {
+		return app.Event{}, app.ErrEventNotFound
+	}
+
+	return event, nil
+}
+
+func (h *Handler) calendarProps(r *http.Request, calendar app.Calendar) (davProps, error) {
+	events, err := h.app.ListCalendarEvents(r.Context(), calendar.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctag := sha256.New()
+	for _, event := range events {
+		ctag.Write([]byte(eventETag(event)))
+	}
+
+	privilege := xml.Name{Space: davNamespace, Local: "privilege"}
+
+	privileges := xmlElement(privilege, emptyElement(davNamespace, "read"))
+	if calendar.Permission == app.PermissionWrite || calendar.Permission == app.PermissionOwner {
+		privileges += xmlElement(privilege, emptyElement(davNamespace, "write"))
+	}
+
+	props := davProps{
+		propResourceType:         emptyElement(davNamespace, "collection") + emptyElement(caldavNamespace, "calendar"),
+		propDisplayName:          xmlText(calendar.Name),
+		propCurrentUserPrincipal: hrefValue(principalHref(app.ActorFromContext(r.Context()))),
+		propPrivilegeSet:         privileges,
+		propSupportedComponents:  `<comp xmlns="` + caldavNamespace + `" name="VEVENT"/>`,
+		propGetCTag:              xmlText(hex.EncodeToString(ctag.Sum(nil))),
+	}
+
+	if calendar.Color != "" {
+		props[propCalendarColor] = xmlText(calendar.Color)
+	}
+
+	return props, nil
+}
+
+// readPropfind returns the requested properties, an empty body asks for all of them.
+func (h *Handler) readPropfind(w http.ResponseWriter, r *http.Request) ([]xml.Name, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), err.Error())
+		http.Error(w, err.Error(), readErrorStatus(err))
+
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, true
+	}
+
+	var req propfindRequest
+
+	if err = xml.Unmarshal(body, &req); err != nil {
+		h.logger.ErrorContext(r.Context(), err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return nil, false
+	}
+
+	if req.AllProp != nil {
+		return nil, true
+	}
+
+	return req.Prop.requested(), true
+}
+
+func (h *Handler) renderDAVError(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.ErrorContext(r.Context(), err.Error())
+
+	status := errorStatus(err)
+
+	switch {
+	case errors.Is(err, storage.ErrEventAlreadyExists), errors.Is(err, ErrUIDConflict):
+		status = http.StatusConflict
+	case errors.Is(err, ErrUnsupportedReport):
+		status = http.StatusForbidden
+	case errors.Is(err, ical.ErrNoCalendar), errors.Is(err, ical.ErrMalformedLine),
+		errors.Is(err, ical.ErrInvalidDate), errors.Is(err, ErrNoEvent), errors.Is(err, ErrUIDMismatch):
+		status = http.StatusBadRequest
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+func renderMultistatus(w http.ResponseWriter, responses ...davResponse) {
+	body, _ := xml.Marshal(multistatus{Responses: responses})
+
+	w.Header().Set("Content-Type", xmlType)
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+func eventProps(event app.Event, withData bool) davProps {
+	props := davProps{
+		propResourceType:   "",
+		propGetETag:        xmlText(eventETag(event)),
+		propGetContentType: xmlText(ical.ContentType),
+	}
+
+	if withData {
+		if body, err := encodeEvents(event); err == nil {
+			props[propCalendarData] = xmlText(string(body))
+		}
+	}
+
+	return props
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the event, a zero ID means
+// the event does not exist yet.
+func checkPreconditions(r *http.Request, event app.Event) bool {
+	exists := event.ID != ""
+
+	if match := r.Header.Get("If-Match"); match != "" {
+		return exists && (match == "*" || strings.Contains(match, eventETag(event)))
+	}
+
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		return !exists || (noneMatch != "*" && !strings.Contains(noneMatch, eventETag(event)))
+	}
+
+	return true
+}
+
+// eventETag hashes the field values of the event, so it depends neither on where Location and
+// Point are allocated nor on how they print: Location.String rounds the coordinates.
+func eventETag(event app.Event) string {
+	fields := []string{
+		event.ID, event.Title, event.StartDate, event.EndDate, event.Description, event.UserID,
+		strconv.Itoa(int(event.NotifyDays)), event.CalendarID, event.Category, event.Color,
+		fmt.Sprintf("%q", event.Tags), event.MeetingURL, event.DeletedAt,
+	}
+
+	if location := event.Location; location != nil {
+		fields = append(fields, location.Address)
+
+		if point := location.Point; point != nil {
+			fields = append(fields,
+				strconv.FormatFloat(point.Latitude, 'g', -1, 64),
+				strconv.FormatFloat(point.Longitude, 'g', -1, 64),
+			)
+		}
+	}
+
+	hash := sha256.New()
+	for _, field := range fields {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+func encodeEvents(events ...app.Event) ([]byte, error) {
+	icalEvents := make([]ical.Event, 0, len(events))
+
+	for _, event := range events {
+		start, err := time.Parse(eventLayout, event.StartDate)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := time.Parse(eventLayout, event.EndDate)
+		if err != nil {
+			return nil, err
+		}
+
+		icalEvents = append(icalEvents, ical.Event{
+			UID:         event.ID,
+			Summary:     event.Title,
+			Description: event.Description,
+			Start:       start,
+			End:         end,
+			AlarmDays:   event.NotifyDays,
+		})
+	}
+
+	var buf bytes.Buffer
+
+	if err := ical.Encode(&buf, icalEvents, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fromICalEvent applies the iCalendar event over the stored one. The fields iCalendar objects
+// do not carry here, tags, category, color, location and meeting URL, are kept.
+func fromICalEvent(event ical.Event, existing app.Event, id, calendarID string) app.Event {
+	existing.ID = id
+	existing.Title = event.Summary
+	existing.StartDate = event.Start.UTC().Format(eventLayout)
+	existing.EndDate = event.End.UTC().Format(eventLayout)
+	existing.Description = event.Description
+	existing.NotifyDays = event.AlarmDays
+	existing.CalendarID = calendarID
+
+	return existing
+}
+
+func parseTimeRange(tr *timeRange) (from, to time.Time, err error) {
+	if tr == nil {
+		return from, to, nil
+	}
+
+	if tr.Start != "" {
+		if from, err = time.Parse(timeRangeUTC, tr.Start); err != nil {
+			return from, to, fmt.Errorf("%s: %w", tr.Start, ical.ErrInvalidDate)
+		}
+	}
+
+	if tr.End != "" {
+		if to, err = time.Parse(timeRangeUTC, tr.End); err != nil {
+			return from, to, fmt.Errorf("%s: %w", tr.End, ical.ErrInvalidDate)
+		}
+	}
+
+	return from, to, nil
+}
+
+// overlaps reports whether the event intersects [from, to), zero bounds are open.
+func overlaps(event app.Event, from, to time.Time) bool {
+	start, err := time.Parse(eventLayout, event.StartDate)
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse(eventLayout, event.EndDate)
+	if err != nil || end.Before(start) {
+		end = start
+	}
+
+	if !to.IsZero() && !start.Before(to) {
+		return false
+	}
+
+	return from.IsZero() || end.After(from) || (end.Equal(start) && !start.Before(from))
+}
+
+// davDepth returns the Depth header, infinity is served as 1.
+func davDepth(r *http.Request) int {
+	if r.Header.Get("Depth") == "0" {
+		return 0
+	}
+
+	return 1
+}
+
+func containsName(names []xml.Name, name xml.Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func principalHref(actor string) string {
+	return davPrincipals + url.PathEscape(actor) + "/"
+}
+
+func calendarHref(calendarID string) string {
+	return davCalendars + url.PathEscape(calendarID) + "/"
+}
+
+// hrefEventID returns the event ID of an .ics resource. Request paths and the hrefs of REPORT
+// bodies are both escaped the way eventHref escapes them, this is the only place they are decoded.
+func hrefEventID(href string) (string, bool) {
+	name, ok := strings.CutSuffix(path.Base(href), icsExtension)
+	if !ok {
+		return "", false
+	}
+
+	id, err := url.PathUnescape(name)
+	if err != nil {
+		return "", false
+	}
+
+	return id, true
+}
+
+func eventHref(event app.Event) string {
+	return calendarHref(event.CalendarID) + url.PathEscape(event.ID) + icsExtension
+}
@@ -0,0 +1,255 @@
+package internalhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	memorystorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+const testEvent = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:e1\r\nSUMMARY:Standup\r\n" +
+	"DTSTART;TZID=Europe/Moscow:20250201T100000\r\nDTEND;TZID=Europe/Moscow:20250201T101500\r\n" +
+	"END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+type davClient struct {
+	t   *testing.T
+	url string
+	app *app.App
+}
+
+func (c davClient) do(method, path, user, body string, headers ...string) (*http.Response, string) {
+	c.t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, c.url+path, strings.NewReader(body))
+	require.NoError(c.t, err)
+
+	if user != "" {
+		req.SetBasicAuth(user, "secret")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(c.t, err)
+
+	return resp, string(respBody)
+}
+
+func newDAVClient(t *testing.T) davClient {
+	t.Helper()
+
+	logg := logger.New(config.LoggerConf{Level: "error", Format: "text"})
+	application := app.New(logg, memorystorage.New())
+
+	owner := app.WithActor(context.Background(), "alice")
+	require.NoError(t, application.CreateCalendar(owner, app.Calendar{
+		ID: "work", Name: "Work", TimeZone: "Europe/Moscow", Color: "#00FF00",
+	}))
+	require.NoError(t, application.ShareCalendar(owner, "work", "bob", app.PermissionRead))
+
+	r := mux.NewRouter()
+	handler := &Handler{router: r, app: application, logger: logg}
+	handler.registerCalDAVRoutes(r)
+	r.Use((&Server{}).actorMiddleware)
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return davClient{t: t, url: srv.URL, app: application}
+}
+
+func TestCalDAV(t *testing.T) {
+	c := newDAVClient(t)
+
+	resp, _ := c.do(methodPropfind, "/dav/", "", "")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+
+	resp, body := c.do(methodPropfind, "/dav/", "alice", `<propfind xmlns="DAV:"
+		xmlns:C="urn:ietf:params:xml:ns:caldav"><prop><current-user-principal/><C:calendar-home-set/>
+		<getctag/></prop></propfind>`)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.Contains(t, body, "/dav/principals/alice/")
+	require.Contains(t, body, "/dav/calendars/")
+	require.Contains(t, body, "404 Not Found")
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "alice", testEvent, "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "alice", testEvent, "If-None-Match", "*")
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e2.ics", "alice", testEvent)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "bob", testEvent)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, body = c.do(methodPropfind, "/dav/calendars/", "bob", "", "Depth", "1")
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.Contains(t, body, "/dav/calendars/work/")
+	require.Contains(t, body, "#00FF00")
+
+	resp, body = c.do(methodPropfind, "/dav/calendars/work/", "bob", "", "Depth", "1")
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.Contains(t, body, "/dav/calendars/work/e1.ics")
+	require.Contains(t, body, strings.Trim(etag, `"`))
+
+	resp, body = c.do(http.MethodGet, "/dav/calendars/work/e1.ics", "bob", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, etag, resp.Header.Get("ETag"))
+	require.Contains(t, body, "SUMMARY:Standup")
+	require.Contains(t, body, "DTSTART:20250201T070000Z")
+
+	query := `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+		<D:prop><D:getetag/><C:calendar-data/></D:prop>
+		<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">
+		<C:time-range start="%s" end="%s"/></C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`
+
+	resp, body = c.do(methodReport, "/dav/calendars/work/", "bob",
+		fmt.Sprintf(query, "20250201T000000Z", "20250202T000000Z"))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.Contains(t, body, "SUMMARY:Standup")
+
+	resp, body = c.do(methodReport, "/dav/calendars/work/", "bob",
+		fmt.Sprintf(query, "20250202T000000Z", "20250301T000000Z"))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.NotContains(t, body, "e1.ics")
+
+	resp, body = c.do(methodReport, "/dav/calendars/work/", "bob", `<C:calendar-multiget xmlns:D="DAV:"
+		xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop>
+		<D:href>/dav/calendars/work/e1.ics</D:href><D:href>/dav/calendars/work/missing.ics</D:href>
+		</C:calendar-multiget>`)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.Contains(t, body, strings.Trim(etag, `"`))
+	require.Contains(t, body, "missing.ics</href><status>HTTP/1.1 404 Not Found")
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "alice",
+		strings.Replace(testEvent, "Standup", "Retro", 1), "If-Match", `"stale"`)
+	require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "alice",
+		strings.Replace(testEvent, "Standup", "Retro", 1), "If-Match", etag)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	resp, _ = c.do(http.MethodDelete, "/dav/calendars/work/e1.ics", "alice", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, _ = c.do(http.MethodGet, "/dav/calendars/work/e1.ics", "alice", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCalDAVETagStable(t *testing.T) {
+	c := newDAVClient(t)
+
+	owner := app.WithActor(context.Background(), "alice")
+	_, err := c.app.PutEvent(owner, app.Event{
+		ID:         "e1",
+		Title:      "Standup",
+		StartDate:  "2025-02-01 07:00",
+		EndDate:    "2025-02-01 07:15",
+		CalendarID: "work",
+		Location:   &app.Location{Address: "Office", Point: &app.Point{Latitude: 55.75, Longitude: 37.62}},
+	})
+	require.NoError(t, err)
+
+	resp, _ := c.do(http.MethodGet, "/dav/calendars/work/e1.ics", "alice", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+
+	resp, _ = c.do(http.MethodGet, "/dav/calendars/work/e1.ics", "alice", "")
+	require.Equal(t, etag, resp.Header.Get("ETag"))
+
+	resp, _ = c.do(http.MethodDelete, "/dav/calendars/work/e1.ics", "alice", "", "If-Match", etag)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// The ETag depends on the location values, not on where they are allocated or how they print.
+	first := app.Event{ID: "e1", Location: &app.Location{Address: "Office", Point: &app.Point{Latitude: 55.75}}}
+	second := first
+	second.Location = &app.Location{Address: "Office", Point: &app.Point{Latitude: 55.75}}
+	require.Equal(t, eventETag(first), eventETag(second))
+
+	second.Location.Point.Latitude = 55.7500001
+	require.NotEqual(t, eventETag(first), eventETag(second))
+}
+
+func TestCalDAVPutKeepsUnmappedFields(t *testing.T) {
+	c := newDAVClient(t)
+
+	owner := app.WithActor(context.Background(), "alice")
+	_, err := c.app.PutEvent(owner, app.Event{
+		ID:         "e1",
+		Title:      "Standup",
+		StartDate:  "2025-02-01 07:00",
+		EndDate:    "2025-02-01 07:15",
+		CalendarID: "work",
+		Category:   "meetings",
+		Color:      "#FF0000",
+		Tags:       []string{"daily", "team"},
+		Location:   &app.Location{Address: "Office", Point: &app.Point{Latitude: 55.75, Longitude: 37.62}},
+		MeetingURL: "https://meet.example.com/standup",
+	})
+	require.NoError(t, err)
+
+	resp, body := c.do(http.MethodGet, "/dav/calendars/work/e1.ics", "alice", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = c.do(http.MethodPut, "/dav/calendars/work/e1.ics", "alice",
+		strings.Replace(body, "Standup", "Retro", 1), "If-Match", resp.Header.Get("ETag"))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	event, err := c.app.GetEvent(owner, "e1")
+	require.NoError(t, err)
+	require.Equal(t, "Retro", event.Title)
+	require.Equal(t, "meetings", event.Category)
+	require.Equal(t, "#FF0000", event.Color)
+	require.Equal(t, []string{"daily", "team"}, event.Tags)
+	require.Equal(t, &app.Location{Address: "Office", Point: &app.Point{Latitude: 55.75, Longitude: 37.62}},
+		event.Location)
+	require.Equal(t, "https://meet.example.com/standup", event.MeetingURL)
+}
+
+func TestCalDAVEscapedResource(t *testing.T) {
+	c := newDAVClient(t)
+
+	// The ID itself contains an escape sequence, it must not be decoded twice.
+	body := strings.Replace(testEvent, "UID:e1", "UID:a%41", 1)
+
+	resp, _ := c.do(http.MethodPut, "/dav/calendars/work/a%2541.ics", "alice", body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	_, err := c.app.GetEvent(app.WithActor(context.Background(), "alice"), "a%41")
+	require.NoError(t, err)
+
+	resp, got := c.do(http.MethodGet, "/dav/calendars/work/a%2541.ics", "alice", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, got, "UID:a%41")
+
+	resp, got = c.do(methodReport, "/dav/calendars/work/", "alice", `<C:calendar-multiget xmlns:D="DAV:"
+		xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop>
+		<D:href>/dav/calendars/work/a%2541.ics</D:href></C:calendar-multiget>`)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	require.NotContains(t, got, "404 Not Found")
+
+	resp, _ = c.do(http.MethodDelete, "/dav/calendars/work/a%2541.ics", "alice", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
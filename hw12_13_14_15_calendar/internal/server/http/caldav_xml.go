@@ -0,0 +1,187 @@
+package internalhttp
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	davNamespace    = "DAV:"
+	caldavNamespace = "urn:ietf:params:xml:ns:caldav"
+	appleNamespace  = "http://apple.com/ns/ical/"
+	csNamespace     = "http://calendarserver.org/ns/"
+)
+
+var (
+	propResourceType         = xml.Name{Space: davNamespace, Local: "resourcetype"}
+	propDisplayName          = xml.Name{Space: davNamespace, Local: "displayname"}
+	propCurrentUserPrincipal = xml.Name{Space: davNamespace, Local: "current-user-principal"}
+	propPrincipalURL         = xml.Name{Space: davNamespace, Local: "principal-URL"}
+	propPrivilegeSet         = xml.Name{Space: davNamespace, Local: "current-user-privilege-set"}
+	propGetETag              = xml.Name{Space: davNamespace, Local: "getetag"}
+	propGetContentType       = xml.Name{Space: davNamespace, Local: "getcontenttype"}
+	propCalendarHomeSet      = xml.Name{Space: caldavNamespace, Local: "calendar-home-set"}
+	propCalendarData         = xml.Name{Space: caldavNamespace, Local: "calendar-data"}
+	propSupportedComponents  = xml.Name{Space: caldavNamespace, Local: "supported-calendar-component-set"}
+	propCalendarColor        = xml.Name{Space: appleNamespace, Local: "calendar-color"}
+	propGetCTag              = xml.Name{Space: csNamespace, Local: "getctag"}
+
+	reportCalendarQuery    = xml.Name{Space: caldavNamespace, Local: "calendar-query"}
+	reportCalendarMultiget = xml.Name{Space: caldavNamespace, Local: "calendar-multiget"}
+)
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+type propNames struct {
+	Names []anyElement `xml:",any"`
+}
+
+type propfindRequest struct {
+	XMLName xml.Name   `xml:"DAV: propfind"`
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *propNames `xml:"DAV: prop"`
+}
+
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *propNames `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+	Filter  *struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type compFilter struct {
+	Name      string       `xml:"name,attr"`
+	TimeRange *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Filters   []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// eventTimeRange returns the time-range of the VEVENT filter inside the VCALENDAR one if any.
+func (r reportRequest) eventTimeRange() *timeRange {
+	if r.Filter == nil {
+		return nil
+	}
+
+	for _, filter := range r.Filter.CompFilter.Filters {
+		if strings.EqualFold(filter.Name, "VEVENT") {
+			return filter.TimeRange
+		}
+	}
+
+	return nil
+}
+
+// requested returns names of the requested properties, nil means all of them.
+func (p *propNames) requested() []xml.Name {
+	if p == nil {
+		return nil
+	}
+
+	names := make([]xml.Name, 0, len(p.Names))
+	for _, name := range p.Names {
+		names = append(names, name.XMLName)
+	}
+
+	return names
+}
+
+type multistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"href"`
+	Propstats []davPropstat `xml:"propstat,omitempty"`
+	Status    string        `xml:"status,omitempty"`
+}
+
+type davPropstat struct {
+	Prop struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+// davProps maps property names of a resource to their already encoded XML content.
+type davProps map[xml.Name]string
+
+// response builds the multistatus response of the resource with the requested properties,
+// the missing ones are reported as not found. All properties are returned if requested is nil.
+func (p davProps) response(href string, requested []xml.Name) davResponse {
+	if requested == nil {
+		for name := range p {
+			requested = append(requested, name)
+		}
+
+		sort.Slice(requested, func(i, j int) bool {
+			return requested[i].Space+requested[i].Local < requested[j].Space+requested[j].Local
+		})
+	}
+
+	var found, missing strings.Builder
+
+	for _, name := range requested {
+		if value, ok := p[name]; ok {
+			found.WriteString(xmlElement(name, value))
+		} else {
+			missing.WriteString(xmlElement(name, ""))
+		}
+	}
+
+	resp := davResponse{Href: href}
+
+	if found.Len() > 0 {
+		resp.Propstats = append(resp.Propstats, propstat(found.String(), http.StatusOK))
+	}
+
+	if missing.Len() > 0 {
+		resp.Propstats = append(resp.Propstats, propstat(missing.String(), http.StatusNotFound))
+	}
+
+	return resp
+}
+
+func propstat(innerXML string, code int) davPropstat {
+	var stat davPropstat
+
+	stat.Prop.InnerXML = innerXML
+	stat.Status = statusLine(code)
+
+	return stat
+}
+
+func statusLine(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+func xmlElement(name xml.Name, innerXML string) string {
+	return "<" + name.Local + ` xmlns="` + name.Space + `">` + innerXML + "</" + name.Local + ">"
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
+
+func hrefValue(href string) string {
+	return xmlElement(xml.Name{Space: davNamespace, Local: "href"}, xmlText(href))
+}
+
+func emptyElement(space, local string) string {
+	return "<" + local + ` xmlns="` + space + `"/>`
+}
@@ -0,0 +1,223 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) CreateCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := h.readCalendarRequest(r)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+
+	ctx = logger.ContextWith(ctx, "calendar_id", req.ID)
+
+	err = h.app.CreateCalendar(ctx, app.Calendar{
+		ID:       req.ID,
+		Name:     req.Name,
+		Color:    req.Color,
+		TimeZone: req.TimeZone,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	calendar, err := h.app.GetCalendar(ctx, calendarID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, CalendarResponse{Calendar: toCalendar(calendar)})
+}
+
+func (h *Handler) UpdateCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	req, err := h.readCalendarRequest(r)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+
+	err = h.app.UpdateCalendar(ctx, calendarID, app.Calendar{
+		Name:     req.Name,
+		Color:    req.Color,
+		TimeZone: req.TimeZone,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) DeleteCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	err := h.app.DeleteCalendar(ctx, calendarID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendars, err := h.app.ListCalendars(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := CalendarsResponse{
+		Calendars: make([]Calendar, 0, len(calendars)),
+	}
+
+	for _, calendar := range calendars {
+		resp.Calendars = append(resp.Calendars, toCalendar(calendar))
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+func (h *Handler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	calendarID := vars["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+	defer r.Body.Close()
+
+	var req ShareRequest
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.app.ShareCalendar(ctx, calendarID, vars["user_id"], req.Permission)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) UnshareCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	calendarID := vars["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	err := h.app.UnshareCalendar(ctx, calendarID, vars["user_id"])
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) ListShares(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	calendarID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "calendar_id", calendarID)
+
+	shares, err := h.app.ListShares(ctx, calendarID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := SharesResponse{
+		Shares: make([]Share, 0, len(shares)),
+	}
+
+	for _, share := range shares {
+		resp.Shares = append(resp.Shares, Share{
+			CalendarID: share.CalendarID,
+			UserID:     share.UserID,
+			Permission: share.Permission,
+		})
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+func (h *Handler) readCalendarRequest(r *http.Request) (CalendarRequest, error) {
+	defer r.Body.Close()
+
+	var req CalendarRequest
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, err
+	}
+
+	err = json.Unmarshal(body, &req)
+
+	return req, err
+}
+
+func toCalendar(calendar app.Calendar) Calendar {
+	return Calendar{
+		ID:         calendar.ID,
+		OwnerID:    calendar.OwnerID,
+		Name:       calendar.Name,
+		Color:      calendar.Color,
+		TimeZone:   calendar.TimeZone,
+		Permission: calendar.Permission,
+	}
+}
@@ -1,15 +1,23 @@
 package internalhttp
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
 	"github.com/gorilla/mux"
 )
 
+const heartbeatInterval = 15 * time.Second
+
 type Handler struct {
 	router *mux.Router
 	app    Application
@@ -21,10 +29,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusBadRequest, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
 		return
 	}
 	defer r.Body.Close()
@@ -33,31 +43,51 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		renderErrorResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	ctx := context.Background()
+	ctx = logger.ContextWith(ctx, "event_id", req.ID)
 
-	err = h.app.CreateEvent(ctx, req.ID, req.Title)
+	err = h.app.CreateEvent(ctx, req.ID, req.Title, req.CalendarID)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusInternalServerError, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
 		return
 	}
 
 	renderSuccessResponse(w, Response{})
 }
 
+func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	eventID := vars["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	event, err := h.app.GetEvent(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, EventResponse{Event: toEvent(event)})
+}
+
 func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	vars := mux.Vars(r)
 	id := vars["id"]
+	ctx = logger.ContextWith(ctx, "event_id", id)
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusBadRequest, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
 		return
 	}
 	defer r.Body.Close()
@@ -66,13 +96,11 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		h.logger.Error(err.Error())
+		h.logger.ErrorContext(ctx, err.Error())
 		renderErrorResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	ctx := context.Background()
-
 	event := app.Event{
 		ID:          req.ID,
 		Title:       req.Title,
@@ -81,12 +109,18 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		UserID:      req.UserID,
 		NotifyDays:  req.NotifyDays,
+		CalendarID:  req.CalendarID,
+		Category:    req.Category,
+		Color:       req.Color,
+		Tags:        req.Tags,
+		Location:    fromLocation(req.Location),
+		MeetingURL:  req.MeetingURL,
 	}
 
 	err = h.app.UpdateEvent(ctx, id, event)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusInternalServerError, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
 		return
 	}
 
@@ -94,15 +128,54 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	vars := mux.Vars(r)
 	eventID := vars["id"]
-
-	ctx := context.Background()
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
 
 	err := h.app.DeleteEvent(ctx, eventID)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusInternalServerError, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	events, err := h.app.ListTrash(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := EventsResponse{
+		Events: make([]Event, 0, len(events)),
+	}
+
+	for _, event := range events {
+		resp.Events = append(resp.Events, toEvent(event))
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+func (h *Handler) RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	eventID := vars["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	err := h.app.RestoreEvent(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
 		return
 	}
 
@@ -110,16 +183,29 @@ func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	params := r.URL.Query()
 	searchDate := params.Get("date")
 	searchPeriod := params.Get("period")
+	near, err := parseArea(params)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
 
-	ctx := context.Background()
+	filter := app.ListFilter{
+		CalendarID: params.Get("calendar_id"),
+		Category:   params.Get("category"),
+		Tags:       params["tag"],
+		Near:       near,
+	}
 
-	events, err := h.app.ListEvents(ctx, searchDate, searchPeriod)
+	events, err := h.app.ListEvents(ctx, searchDate, searchPeriod, filter)
 	if err != nil {
-		h.logger.Error(err.Error())
-		renderErrorResponse(w, http.StatusInternalServerError, err)
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
 		return
 	}
 
@@ -128,20 +214,376 @@ func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, event := range events {
-		resp.Events = append(resp.Events, Event{
-			ID:          event.ID,
-			Title:       event.Title,
-			StartDate:   event.StartDate,
-			EndDate:     event.EndDate,
-			Description: event.Description,
-			UserID:      event.UserID,
-			NotifyDays:  event.NotifyDays,
-		})
+		resp.Events = append(resp.Events, toEvent(event))
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+// SuggestTags completes the prefix parameter with tags of the visible events.
+func (h *Handler) SuggestTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	params := r.URL.Query()
+
+	var limit int
+
+	if param := params.Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil {
+			h.logger.ErrorContext(ctx, err.Error())
+			renderErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		limit = parsed
+	}
+
+	tags, err := h.app.SuggestTags(ctx, params.Get("prefix"), limit)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := TagsResponse{
+		Tags: make([]Tag, 0, len(tags)),
+	}
+
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, Tag{Name: tag.Name, Count: tag.Count})
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+func (h *Handler) GetEventHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	eventID := vars["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	entries, err := h.app.GetEventHistory(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := HistoryResponse{
+		Entries: make([]AuditEntry, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		auditEntry := AuditEntry{
+			ID:        entry.ID,
+			EventID:   entry.EventID,
+			Actor:     entry.Actor,
+			Action:    entry.Action,
+			CreatedAt: entry.CreatedAt,
+		}
+
+		if entry.Before != nil {
+			before := toEvent(*entry.Before)
+			auditEntry.Before = &before
+		}
+
+		if entry.After != nil {
+			after := toEvent(*entry.After)
+			auditEntry.After = &after
+		}
+
+		resp.Entries = append(resp.Entries, auditEntry)
+	}
+
+	renderSuccessResponse(w, resp)
+}
+
+// BatchEvents applies several create, update and delete operations at once. An aborted
+// atomic batch is answered with 409 Conflict and the per-operation results.
+func (h *Handler) BatchEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+	defer r.Body.Close()
+
+	var req BatchRequest
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ops := make([]app.BatchOperation, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		ops = append(ops, app.BatchOperation{Type: op.Type, Event: fromEvent(op.Event)})
+	}
+
+	results, err := h.app.BatchEvents(ctx, ops, req.Atomic)
+
+	switch {
+	case errors.Is(err, app.ErrEmptyBatch), errors.Is(err, app.ErrBatchTooLarge):
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, err)
+		return
+	case err != nil && !errors.Is(err, app.ErrBatchAborted):
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	resp := BatchResponse{
+		Results: make([]OperationResult, 0, len(results)),
+	}
+
+	for _, result := range results {
+		opResult := OperationResult{ID: result.ID}
+		if result.Err != nil {
+			opResult.Error = true
+			opResult.Message = result.Err.Error()
+		}
+
+		resp.Results = append(resp.Results, opResult)
+	}
+
+	if err != nil {
+		h.logger.WarnContext(ctx, err.Error())
+		resp.Error = true
+		resp.Message = err.Error()
+
+		jsonResp, _ := json.Marshal(resp)
+
+		w.WriteHeader(http.StatusConflict)
+		w.Write(jsonResp)
+
+		return
 	}
 
 	renderSuccessResponse(w, resp)
 }
 
+// WatchEvents streams event changes as Server-Sent Events. Clients resume after
+// reconnect with the standard Last-Event-ID header or the epoch and from_seq parameters.
+// A cursor the server cannot resume from is answered with 410 Gone, the client has to
+// reload events and watch without a cursor.
+func (h *Handler) WatchEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	params := r.URL.Query()
+
+	epoch, lastSeq := params.Get("epoch"), params.Get("from_seq")
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		epoch, lastSeq = parseEventID(lastEventID)
+	}
+
+	var fromSeq uint64
+
+	if lastSeq != "" {
+		seq, err := strconv.ParseUint(lastSeq, 10, 64)
+		if err != nil {
+			h.logger.ErrorContext(ctx, err.Error())
+			renderErrorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		fromSeq = seq
+	}
+
+	changes, err := h.app.WatchEvents(ctx, app.WatchFilter{
+		UserID:   params.Get("user_id"),
+		FromDate: params.Get("from_date"),
+		ToDate:   params.Get("to_date"),
+		Epoch:    epoch,
+		FromSeq:  fromSeq,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+
+		status := http.StatusBadRequest
+		if errors.Is(err, app.ErrWatchResync) {
+			status = http.StatusGone
+		}
+
+		renderErrorResponse(w, status, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err = rc.Flush(); err != nil {
+			h.logger.ErrorContext(ctx, err.Error())
+			return
+		}
+
+		select {
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			data, _ := json.Marshal(EventChange{
+				Epoch: change.Epoch,
+				Seq:   change.Seq,
+				Type:  change.Type,
+				Event: toEvent(change.Event),
+			})
+
+			fmt.Fprintf(w, "id: %s-%d\nevent: %s\ndata: %s\n\n", change.Epoch, change.Seq, change.Type, data)
+		}
+	}
+}
+
+// parseEventID splits the SSE event ID into the epoch and the sequence number. An ID without
+// an epoch is left to be rejected as a stale cursor.
+func parseEventID(id string) (epoch, seq string) {
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+
+	return "", id
+}
+
+func toEvent(event app.Event) Event {
+	return Event{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartDate:   event.StartDate,
+		EndDate:     event.EndDate,
+		Description: event.Description,
+		UserID:      event.UserID,
+		NotifyDays:  event.NotifyDays,
+		CalendarID:  event.CalendarID,
+		Category:    event.Category,
+		Color:       event.Color,
+		Tags:        event.Tags,
+		Location:    toLocation(event.Location),
+		MeetingURL:  event.MeetingURL,
+		DeletedAt:   event.DeletedAt,
+	}
+}
+
+func fromEvent(event Event) app.Event {
+	return app.Event{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartDate:   event.StartDate,
+		EndDate:     event.EndDate,
+		Description: event.Description,
+		UserID:      event.UserID,
+		NotifyDays:  event.NotifyDays,
+		CalendarID:  event.CalendarID,
+		Category:    event.Category,
+		Color:       event.Color,
+		Tags:        event.Tags,
+		Location:    fromLocation(event.Location),
+		MeetingURL:  event.MeetingURL,
+	}
+}
+
+func toLocation(location *app.Location) *Location {
+	if location == nil {
+		return nil
+	}
+
+	converted := &Location{Address: location.Address}
+	if location.Point != nil {
+		converted.Point = &Point{Latitude: location.Point.Latitude, Longitude: location.Point.Longitude}
+	}
+
+	return converted
+}
+
+func fromLocation(location *Location) *app.Location {
+	if location == nil {
+		return nil
+	}
+
+	converted := &app.Location{Address: location.Address}
+	if location.Point != nil {
+		converted.Point = &app.Point{Latitude: location.Point.Latitude, Longitude: location.Point.Longitude}
+	}
+
+	return converted
+}
+
+// parseArea reads the lat, lon and radius parameters of a search for events near a point.
+// They are given all together or not at all.
+func parseArea(params url.Values) (*app.Area, error) {
+	if !params.Has("lat") && !params.Has("lon") && !params.Has("radius") {
+		return nil, nil
+	}
+
+	var values [3]float64
+
+	for i, name := range []string{"lat", "lon", "radius"} {
+		value, err := strconv.ParseFloat(params.Get(name), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, app.ErrInvalidArea)
+		}
+
+		values[i] = value
+	}
+
+	return &app.Area{
+		Center: app.Point{Latitude: values[0], Longitude: values[1]},
+		Radius: values[2],
+	}, nil
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrEventNotFound), errors.Is(err, app.ErrCalendarNotFound),
+		errors.Is(err, app.ErrShareNotFound), errors.Is(err, app.ErrAttachmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, app.ErrCalendarNotEmpty), errors.Is(err, app.ErrTooManyAttachments):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, app.ErrAttachmentsDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, app.ErrInvalidPermission), errors.Is(err, app.ErrInvalidColor),
+		errors.Is(err, app.ErrInvalidTimeZone), errors.Is(err, app.ErrEmptyCalendarName),
+		errors.Is(err, app.ErrEmptyCalendarID), errors.Is(err, app.ErrInvalidTag),
+		errors.Is(err, app.ErrTooManyTags), errors.Is(err, app.ErrInvalidCategory),
+		errors.Is(err, app.ErrInvalidLocation), errors.Is(err, app.ErrInvalidMeetingURL),
+		errors.Is(err, app.ErrInvalidArea), errors.Is(err, app.ErrInvalidAttachment),
+		errors.Is(err, app.ErrInvalidChannel), errors.Is(err, app.ErrInvalidAddress),
+		errors.Is(err, app.ErrInvalidQuietHours), errors.Is(err, app.ErrInvalidDelivery),
+		errors.Is(err, app.ErrInvalidDigestTime):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func readErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
 func renderSuccessResponse(w http.ResponseWriter, resp interface{}) {
 	jsonResp, _ := json.Marshal(resp)
 
@@ -1,11 +1,78 @@
 package internalhttp
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	userIDHeader       = "X-User-ID"
+	maxRequestIDLength = 128
 )
 
+var ErrTooManyRequests = errors.New("too many requests")
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/server/http")
+
+// requestIDMiddleware propagates X-Request-ID from the client or generates a new one
+// and puts it into the request context, so every log line of the request carries it.
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// sessionMiddleware scopes storage reads to the request, so a request reads its own writes
+// even when the storage serves lists from replicas.
+func (s *Server) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(storage.WithSession(r.Context())))
+	})
+}
+
+// actorMiddleware puts X-User-ID into the request context, the audit log attributes changes to it.
+// Clients which cannot set the header (e.g. CalDAV ones) may pass the user as the Basic auth name,
+// the credentials are expected to be verified by the proxy in front of the service.
+func (s *Server) actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(userIDHeader)
+		if actor == "" {
+			actor, _, _ = r.BasicAuth()
+		}
+
+		if actor != "" {
+			r = r.WithContext(app.WithActor(r.Context(), actor))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -28,10 +95,121 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 			r.UserAgent(),
 		)
 
-		s.logger.Info(msg)
+		s.logger.InfoContext(r.Context(), msg)
+	})
+}
+
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(lrw, r)
+
+		metrics.ObserveHTTPRequest(routeTemplate(r), r.Method, lrw.statusCode, time.Since(start))
+	})
+}
+
+// rateLimitMiddleware throttles clients by IP address. X-User-ID is not authenticated, a client
+// could get a fresh bucket with every request by changing it.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, retryAfter := s.limiter.Allow(clientKey(r))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			renderErrorResponse(w, http.StatusTooManyRequests, ErrTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyLimitMiddleware bounds request bodies by the configured size, attachment uploads are bounded
+// by the attachment size instead.
+func (s *Server) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && routeTemplate(r) == attachmentsRoute {
+			maxSize := s.cfg.Attachments.MaxSize
+			if maxSize <= 0 {
+				maxSize = app.DefaultMaxAttachmentSize
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+		} else if s.cfg.Limits.MaxBodySize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, s.cfg.Limits.MaxBodySize)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(lrw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(lrw.statusCode))
+		if lrw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(lrw.statusCode))
+		}
 	})
 }
 
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+
+	return "unknown"
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -48,3 +226,7 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
 	}
 	return lrw.ResponseWriter.Write(b)
 }
+
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
@@ -0,0 +1,35 @@
+package internalhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	server := &Server{limiter: ratelimit.New(1, 2)}
+	handler := server.rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(remoteAddr, userID string) int {
+		r := httptest.NewRequest(http.MethodGet, "/events", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(userIDHeader, userID)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("192.0.2.1:1000", "alice"))
+	require.Equal(t, http.StatusOK, do("192.0.2.1:1001", "bob"))
+
+	// A new X-User-ID does not give the client a new bucket.
+	require.Equal(t, http.StatusTooManyRequests, do("192.0.2.1:1002", "carol"))
+	require.Equal(t, http.StatusOK, do("192.0.2.2:1000", "carol"))
+}
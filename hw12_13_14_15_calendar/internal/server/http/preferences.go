@@ -0,0 +1,142 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) registerPreferencesRoutes(r *mux.Router) {
+	r.HandleFunc("/preferences", h.GetPreferences).Methods(http.MethodGet)
+	r.HandleFunc("/preferences", h.SetPreferences).Methods(http.MethodPut)
+	r.HandleFunc("/preferences", h.DeletePreferences).Methods(http.MethodDelete)
+	r.HandleFunc("/preferences/opt-outs", h.ListOptOuts).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id}/opt-out", h.OptOut).Methods(http.MethodPut)
+	r.HandleFunc("/events/{id}/opt-out", h.OptIn).Methods(http.MethodDelete)
+}
+
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	preferences, err := h.app.GetPreferences(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, PreferencesResponse{Preferences: toPreferences(preferences)})
+}
+
+// SetPreferences replaces all the preferences, omitted fields take the defaults.
+func (h *Handler) SetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, readErrorStatus(err), err)
+		return
+	}
+	defer r.Body.Close()
+
+	var req PreferencesRequest
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	preferences, err := h.app.SetPreferences(ctx, app.Preferences{
+		Channel:    req.Channel,
+		Address:    req.Address,
+		QuietStart: req.QuietStart,
+		QuietEnd:   req.QuietEnd,
+		TimeZone:   req.TimeZone,
+		Delivery:   req.Delivery,
+		DigestTime: req.DigestTime,
+	})
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, PreferencesResponse{Preferences: toPreferences(preferences)})
+}
+
+func (h *Handler) DeletePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := h.app.DeletePreferences(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) ListOptOuts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	eventIDs, err := h.app.ListOptOuts(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, OptOutsResponse{EventIDs: eventIDs})
+}
+
+func (h *Handler) OptOut(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	eventID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	err := h.app.OptOut(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func (h *Handler) OptIn(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	eventID := mux.Vars(r)["id"]
+	ctx = logger.ContextWith(ctx, "event_id", eventID)
+
+	err := h.app.OptIn(ctx, eventID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, err.Error())
+		renderErrorResponse(w, errorStatus(err), err)
+		return
+	}
+
+	renderSuccessResponse(w, Response{})
+}
+
+func toPreferences(preferences app.Preferences) Preferences {
+	return Preferences{
+		Channel:    preferences.Channel,
+		Address:    preferences.Address,
+		QuietStart: preferences.QuietStart,
+		QuietEnd:   preferences.QuietEnd,
+		TimeZone:   preferences.TimeZone,
+		Delivery:   preferences.Delivery,
+		DigestTime: preferences.DigestTime,
+	}
+}
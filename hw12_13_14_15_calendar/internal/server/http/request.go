@@ -1,17 +1,55 @@
 package internalhttp
 
 type CreateRequest struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	CalendarID string `json:"calendar_id"`
 }
 type UpdateEventRequest struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
-	Description string `json:"description"`
-	UserID      string `json:"user_id"`
-	NotifyDays  int32  `json:"notify_days"`
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	Description string    `json:"description"`
+	UserID      string    `json:"user_id"`
+	NotifyDays  int32     `json:"notify_days"`
+	CalendarID  string    `json:"calendar_id"`
+	Category    string    `json:"category"`
+	Color       string    `json:"color"`
+	Tags        []string  `json:"tags"`
+	Location    *Location `json:"location"`
+	MeetingURL  string    `json:"meeting_url"`
+}
+
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchOperation struct {
+	Type  string `json:"type"`
+	Event Event  `json:"event"`
+}
+
+type CalendarRequest struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	TimeZone string `json:"time_zone"`
+}
+
+type ShareRequest struct {
+	Permission string `json:"permission"`
+}
+
+type PreferencesRequest struct {
+	Channel    string `json:"channel"`
+	Address    string `json:"address"`
+	QuietStart string `json:"quiet_start"`
+	QuietEnd   string `json:"quiet_end"`
+	TimeZone   string `json:"time_zone"`
+	Delivery   string `json:"delivery"`
+	DigestTime string `json:"digest_time"`
 }
 
 type ListEventsRequest struct {
@@ -5,14 +5,148 @@ type EventsResponse struct {
 	Events []Event `json:"events"`
 }
 
+type BatchResponse struct {
+	Response
+	Results []OperationResult `json:"results"`
+}
+
+type OperationResult struct {
+	ID      string `json:"id"`
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+}
+
+type EventResponse struct {
+	Response
+	Event Event `json:"event"`
+}
+
+type HistoryResponse struct {
+	Response
+	Entries []AuditEntry `json:"entries"`
+}
+
+type AuditEntry struct {
+	ID        int64  `json:"id"`
+	EventID   string `json:"event_id"`
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	Before    *Event `json:"before,omitempty"`
+	After     *Event `json:"after,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
 type Event struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	Description string    `json:"description"`
+	UserID      string    `json:"user_id"`
+	NotifyDays  int32     `json:"notify_days"`
+	CalendarID  string    `json:"calendar_id,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Color       string    `json:"color,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Location    *Location `json:"location,omitempty"`
+	MeetingURL  string    `json:"meeting_url,omitempty"`
+	DeletedAt   string    `json:"deleted_at,omitempty"`
+}
+
+type Location struct {
+	Address string `json:"address,omitempty"`
+	Point   *Point `json:"point,omitempty"`
+}
+
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type AttachmentResponse struct {
+	Response
+	Attachment Attachment `json:"attachment"`
+}
+
+type AttachmentsResponse struct {
+	Response
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Attachment struct {
 	ID          string `json:"id"`
-	Title       string `json:"title"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
-	Description string `json:"description"`
-	UserID      string `json:"user_id"`
-	NotifyDays  int32  `json:"notify_days"`
+	EventID     string `json:"event_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type PreferencesResponse struct {
+	Response
+	Preferences Preferences `json:"preferences"`
+}
+
+type Preferences struct {
+	Channel    string `json:"channel"`
+	Address    string `json:"address,omitempty"`
+	QuietStart string `json:"quiet_start,omitempty"`
+	QuietEnd   string `json:"quiet_end,omitempty"`
+	TimeZone   string `json:"time_zone"`
+	Delivery   string `json:"delivery"`
+	DigestTime string `json:"digest_time"`
+}
+
+type OptOutsResponse struct {
+	Response
+	EventIDs []string `json:"event_ids"`
+}
+
+type TagsResponse struct {
+	Response
+	Tags []Tag `json:"tags"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type EventChange struct {
+	Epoch string `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+	Type  string `json:"type"`
+	Event Event  `json:"event"`
+}
+
+type CalendarResponse struct {
+	Response
+	Calendar Calendar `json:"calendar"`
+}
+
+type CalendarsResponse struct {
+	Response
+	Calendars []Calendar `json:"calendars"`
+}
+
+type Calendar struct {
+	ID         string `json:"id"`
+	OwnerID    string `json:"owner_id"`
+	Name       string `json:"name"`
+	Color      string `json:"color,omitempty"`
+	TimeZone   string `json:"time_zone"`
+	Permission string `json:"permission"`
+}
+
+type SharesResponse struct {
+	Response
+	Shares []Share `json:"shares"`
+}
+
+type Share struct {
+	CalendarID string `json:"calendar_id"`
+	UserID     string `json:"user_id"`
+	Permission string `json:"permission"`
 }
 
 type Response struct {
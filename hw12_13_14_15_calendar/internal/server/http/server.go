@@ -4,20 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/ratelimit"
 	"github.com/gorilla/mux"
 )
 
 type Server struct {
-	logger Logger
-	app    Application
-	srv    *http.Server
-	cfg    *config.Config
+	logger  Logger
+	app     Application
+	health  *health.Health
+	limiter *ratelimit.Limiter
+	srv     *http.Server
+	cfg     *config.Config
 }
 
 type Logger interface {
@@ -25,20 +30,58 @@ type Logger interface {
 	Error(msg string)
 	Warn(msg string)
 	Debug(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+	WarnContext(ctx context.Context, msg string)
+	DebugContext(ctx context.Context, msg string)
 }
 
 type Application interface {
-	CreateEvent(ctx context.Context, id string, title string) error
+	CreateEvent(ctx context.Context, id, title, calendarID string) error
+	GetEvent(ctx context.Context, id string) (app.Event, error)
 	UpdateEvent(ctx context.Context, id string, event app.Event) error
+	PutEvent(ctx context.Context, event app.Event) (bool, error)
 	DeleteEvent(ctx context.Context, id string) error
-	ListEvents(ctx context.Context, date, period string) ([]app.Event, error)
+	ListEvents(ctx context.Context, date, period string, filter app.ListFilter) ([]app.Event, error)
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]app.Tag, error)
+	WatchEvents(ctx context.Context, filter app.WatchFilter) (<-chan app.Change, error)
+	BatchEvents(ctx context.Context, ops []app.BatchOperation, atomic bool) ([]app.BatchResult, error)
+	ListTrash(ctx context.Context) ([]app.Event, error)
+	RestoreEvent(ctx context.Context, id string) error
+	GetEventHistory(ctx context.Context, id string) ([]app.AuditEntry, error)
+	AddAttachment(ctx context.Context, eventID string, upload app.AttachmentUpload) (app.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]app.Attachment, error)
+	OpenAttachment(ctx context.Context, eventID, id string) (app.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, eventID, id string) error
+	GetPreferences(ctx context.Context) (app.Preferences, error)
+	SetPreferences(ctx context.Context, preferences app.Preferences) (app.Preferences, error)
+	DeletePreferences(ctx context.Context) error
+	OptOut(ctx context.Context, eventID string) error
+	OptIn(ctx context.Context, eventID string) error
+	ListOptOuts(ctx context.Context) ([]string, error)
+	CreateCalendar(ctx context.Context, calendar app.Calendar) error
+	GetCalendar(ctx context.Context, id string) (app.Calendar, error)
+	UpdateCalendar(ctx context.Context, id string, calendar app.Calendar) error
+	DeleteCalendar(ctx context.Context, id string) error
+	ListCalendars(ctx context.Context) ([]app.Calendar, error)
+	ListCalendarEvents(ctx context.Context, calendarID string) ([]app.Event, error)
+	ShareCalendar(ctx context.Context, calendarID, userID, permission string) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	ListShares(ctx context.Context, calendarID string) ([]app.Share, error)
 }
 
-func NewServer(cfg config.Config, logger Logger, app Application) *Server {
+func NewServer(cfg config.Config, logger Logger, app Application, health *health.Health) *Server {
+	var limiter *ratelimit.Limiter
+	if cfg.Limits.RPS > 0 {
+		limiter = ratelimit.New(cfg.Limits.RPS, cfg.Limits.Burst)
+	}
+
 	return &Server{
-		logger: logger,
-		app:    app,
-		cfg:    &cfg,
+		logger:  logger,
+		app:     app,
+		health:  health,
+		limiter: limiter,
+		cfg:     &cfg,
 	}
 }
 
@@ -53,16 +96,49 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 
 	r.HandleFunc("/events", handler.CreateEvent).Methods(http.MethodPost)
+	r.HandleFunc("/events:batch", handler.BatchEvents).Methods(http.MethodPost)
+	r.HandleFunc("/events/stream", handler.WatchEvents).Methods(http.MethodGet)
+	r.HandleFunc("/events/trash", handler.ListTrash).Methods(http.MethodGet)
+	r.HandleFunc("/events/tags", handler.SuggestTags).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id}/restore", handler.RestoreEvent).Methods(http.MethodPost)
+	r.HandleFunc("/events/{id}/history", handler.GetEventHistory).Methods(http.MethodGet)
+	handler.registerAttachmentRoutes(r)
+	handler.registerPreferencesRoutes(r)
+	r.HandleFunc("/events/{id}", handler.GetEvent).Methods(http.MethodGet)
 	r.HandleFunc("/events/{id}", handler.UpdateEvent).Methods(http.MethodPut)
 	r.HandleFunc("/events/{id}", handler.DeleteEvent).Methods(http.MethodDelete)
 	r.HandleFunc("/events", handler.ListEvents).Methods(http.MethodGet)
+	r.HandleFunc("/calendars", handler.CreateCalendar).Methods(http.MethodPost)
+	r.HandleFunc("/calendars", handler.ListCalendars).Methods(http.MethodGet)
+	r.HandleFunc("/calendars/{id}", handler.GetCalendar).Methods(http.MethodGet)
+	r.HandleFunc("/calendars/{id}", handler.UpdateCalendar).Methods(http.MethodPut)
+	r.HandleFunc("/calendars/{id}", handler.DeleteCalendar).Methods(http.MethodDelete)
+	r.HandleFunc("/calendars/{id}/shares", handler.ListShares).Methods(http.MethodGet)
+	r.HandleFunc("/calendars/{id}/shares/{user_id}", handler.ShareCalendar).Methods(http.MethodPut)
+	r.HandleFunc("/calendars/{id}/shares/{user_id}", handler.UnshareCalendar).Methods(http.MethodDelete)
+	handler.registerCalDAVRoutes(r)
+	r.HandleFunc("/healthz", s.health.Liveness).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", s.health.Readiness).Methods(http.MethodGet)
 
-	r.Use(s.loggingMiddleware)
+	r.Use(
+		s.requestIDMiddleware,
+		s.sessionMiddleware,
+		s.actorMiddleware,
+		s.loggingMiddleware,
+		s.metricsMiddleware,
+		s.rateLimitMiddleware,
+		s.bodyLimitMiddleware,
+		s.tracingMiddleware,
+	)
 
 	s.srv = &http.Server{
 		Addr:              addr,
 		Handler:           handler,
 		ReadHeaderTimeout: 1 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			// Long-lived streams are finished when the server is stopping.
+			return ctx
+		},
 	}
 
 	s.logger.Info(fmt.Sprintf("http server starting at %s", addr))
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/health"
 	internalgrpc "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/server/http"
 )
@@ -28,23 +30,54 @@ type Logger interface {
 	Error(msg string)
 	Warn(msg string)
 	Debug(msg string)
+	InfoContext(ctx context.Context, msg string)
+	ErrorContext(ctx context.Context, msg string)
+	WarnContext(ctx context.Context, msg string)
+	DebugContext(ctx context.Context, msg string)
 }
 
 type Application interface {
-	CreateEvent(ctx context.Context, id string, title string) error
+	CreateEvent(ctx context.Context, id, title, calendarID string) error
+	GetEvent(ctx context.Context, id string) (app.Event, error)
 	UpdateEvent(ctx context.Context, id string, event app.Event) error
+	PutEvent(ctx context.Context, event app.Event) (bool, error)
 	DeleteEvent(ctx context.Context, id string) error
-	ListEvents(ctx context.Context, date, period string) ([]app.Event, error)
+	ListEvents(ctx context.Context, date, period string, filter app.ListFilter) ([]app.Event, error)
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]app.Tag, error)
+	WatchEvents(ctx context.Context, filter app.WatchFilter) (<-chan app.Change, error)
+	BatchEvents(ctx context.Context, ops []app.BatchOperation, atomic bool) ([]app.BatchResult, error)
+	ListTrash(ctx context.Context) ([]app.Event, error)
+	RestoreEvent(ctx context.Context, id string) error
+	GetEventHistory(ctx context.Context, id string) ([]app.AuditEntry, error)
+	AddAttachment(ctx context.Context, eventID string, upload app.AttachmentUpload) (app.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]app.Attachment, error)
+	OpenAttachment(ctx context.Context, eventID, id string) (app.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, eventID, id string) error
+	GetPreferences(ctx context.Context) (app.Preferences, error)
+	SetPreferences(ctx context.Context, preferences app.Preferences) (app.Preferences, error)
+	DeletePreferences(ctx context.Context) error
+	OptOut(ctx context.Context, eventID string) error
+	OptIn(ctx context.Context, eventID string) error
+	ListOptOuts(ctx context.Context) ([]string, error)
+	CreateCalendar(ctx context.Context, calendar app.Calendar) error
+	GetCalendar(ctx context.Context, id string) (app.Calendar, error)
+	UpdateCalendar(ctx context.Context, id string, calendar app.Calendar) error
+	DeleteCalendar(ctx context.Context, id string) error
+	ListCalendars(ctx context.Context) ([]app.Calendar, error)
+	ListCalendarEvents(ctx context.Context, calendarID string) ([]app.Event, error)
+	ShareCalendar(ctx context.Context, calendarID, userID, permission string) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	ListShares(ctx context.Context, calendarID string) ([]app.Share, error)
 }
 
-func New(cfg config.Config, logger Logger, app Application) Server {
+func New(cfg config.Config, logger Logger, app Application, health *health.Health) Server {
 	var server Server
 
 	switch cfg.App.Server {
 	case httpServer:
-		server = internalhttp.NewServer(cfg, logger, app)
+		server = internalhttp.NewServer(cfg, logger, app, health)
 	case grpcServer:
-		server = internalgrpc.NewServer(cfg, logger, app)
+		server = internalgrpc.NewServer(cfg, logger, app, health)
 	default:
 		panic(fmt.Sprintf("%s: %v: %s", cfg.App.Storage, ErrServerNotExist, cfg.App.Server))
 	}
@@ -0,0 +1,14 @@
+package storage
+
+import "time"
+
+// Attachment describes a file attached to an event, the content is kept in a blob store. The
+// record outlives a purged event until the content is removed, see ListOrphanedAttachments.
+type Attachment struct {
+	ID          string    `db:"uuid"`
+	EventID     string    `db:"event_uuid"`
+	Name        string    `db:"name"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	CreatedAt   time.Time `db:"created_at"`
+}
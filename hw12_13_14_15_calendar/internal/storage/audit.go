@@ -0,0 +1,15 @@
+package storage
+
+import "time"
+
+// AuditEntry is an append-only record of a single event change. Before and After hold
+// JSON snapshots of the event and are empty for creation and deletion respectively.
+type AuditEntry struct {
+	ID        int64     `db:"id"`
+	EventID   string    `db:"event_uuid"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	Before    []byte    `db:"snapshot_before"`
+	After     []byte    `db:"snapshot_after"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -0,0 +1,15 @@
+package storage
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Operation is a single item of a batch. Update and delete use Event.ID to find the event.
+// Audit, when set, is recorded together with the operation and only if it is applied.
+type Operation struct {
+	Type  string
+	Event Event
+	Audit *AuditEntry
+}
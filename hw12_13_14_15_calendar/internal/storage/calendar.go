@@ -0,0 +1,18 @@
+package storage
+
+import "database/sql"
+
+type Calendar struct {
+	ID       string         `db:"uuid"`
+	OwnerID  string         `db:"owner_id"`
+	Name     string         `db:"name"`
+	Color    sql.NullString `db:"color"`
+	TimeZone string         `db:"time_zone"`
+}
+
+// Share grants a user other than the owner access to a calendar.
+type Share struct {
+	CalendarID string `db:"calendar_uuid"`
+	UserID     string `db:"user_id"`
+	Permission string `db:"permission"`
+}
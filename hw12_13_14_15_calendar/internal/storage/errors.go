@@ -3,8 +3,17 @@ package storage
 import "errors"
 
 var (
-	ErrStorageNotExist    = errors.New("storage not exist")
-	ErrDateBusy           = errors.New("date is busy by another event")
-	ErrEventAlreadyExists = errors.New("event already exists")
-	ErrEventNotExists     = errors.New("event not exist")
+	ErrStorageNotExist      = errors.New("storage not exist")
+	ErrDateBusy             = errors.New("date is busy by another event")
+	ErrEventAlreadyExists   = errors.New("event already exists")
+	ErrEventNotExists       = errors.New("event not exist")
+	ErrUnknownOperation     = errors.New("unknown operation")
+	ErrBatchAborted         = errors.New("batch aborted")
+	ErrCalendarNotExists    = errors.New("calendar not exist")
+	ErrCalendarExists       = errors.New("calendar already exists")
+	ErrCalendarNotEmpty     = errors.New("calendar has events")
+	ErrShareNotExists       = errors.New("share not exist")
+	ErrAttachmentNotExists  = errors.New("attachment not exist")
+	ErrPreferencesNotExists = errors.New("preferences not exist")
+	ErrOptOutNotExists      = errors.New("opt-out not exist")
 )
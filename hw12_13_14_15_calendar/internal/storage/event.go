@@ -2,15 +2,78 @@ package storage
 
 import (
 	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Event struct {
-	ID          string         `db:"uuid"`
-	Title       string         `db:"title"`
-	StartDate   time.Time      `db:"start_date"`
-	EndDate     time.Time      `db:"end_date"`
-	Description sql.NullString `db:"description"`
-	UserID      sql.NullString `db:"user_id"`
-	NotifyDays  sql.NullInt32  `db:"notify_days"`
+	ID          string          `db:"uuid"`
+	Title       string          `db:"title"`
+	StartDate   time.Time       `db:"start_date"`
+	EndDate     time.Time       `db:"end_date"`
+	Description sql.NullString  `db:"description"`
+	UserID      sql.NullString  `db:"user_id"`
+	NotifyDays  sql.NullInt32   `db:"notify_days"`
+	CalendarID  sql.NullString  `db:"calendar_uuid"`
+	Category    sql.NullString  `db:"category"`
+	Color       sql.NullString  `db:"color"`
+	Tags        Tags            `db:"tags"`
+	Address     sql.NullString  `db:"address"`
+	Latitude    sql.NullFloat64 `db:"latitude"`
+	Longitude   sql.NullFloat64 `db:"longitude"`
+	MeetingURL  sql.NullString  `db:"meeting_url"`
+	DeletedAt   sql.NullTime    `db:"deleted_at"`
+}
+
+// TagSeparator joins the tags of an event when the SQL storages aggregate them into one column,
+// so tags must not contain it.
+const TagSeparator = ","
+
+// Tags are the sorted tags of an event. They are stored in a separate table and read back
+// aggregated into a single column.
+type Tags []string
+
+// Scan implements sql.Scanner for the aggregated column, NULL means no tags.
+func (t *Tags) Scan(src any) error {
+	var joined string
+
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	default:
+		return fmt.Errorf("unsupported tags type %T", src)
+	}
+
+	if joined == "" {
+		*t = nil
+		return nil
+	}
+
+	tags := Tags(strings.Split(joined, TagSeparator))
+	sort.Strings(tags)
+	*t = tags
+
+	return nil
+}
+
+// TagUsage is the number of live events of a calendar having the tag.
+type TagUsage struct {
+	Tag        string         `db:"tag"`
+	CalendarID sql.NullString `db:"calendar_uuid"`
+	Count      int            `db:"count"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// LikePrefix returns the LIKE pattern matching strings starting with prefix, the queries
+// declare backslash as the escape character.
+func LikePrefix(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
 }
@@ -0,0 +1,60 @@
+package storage
+
+import "math"
+
+// EarthRadius is the mean radius of the Earth in meters, the SQL storages use the same value.
+const EarthRadius = 6371008.8
+
+// Point is a place on the Earth in degrees.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Point returns the coordinates of the event if it has them.
+func (e Event) Point() (Point, bool) {
+	if !e.Latitude.Valid || !e.Longitude.Valid {
+		return Point{}, false
+	}
+
+	return Point{Latitude: e.Latitude.Float64, Longitude: e.Longitude.Float64}, true
+}
+
+// Distance returns the great-circle distance between the points in meters by the haversine formula.
+func Distance(a, b Point) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * EarthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// BoundingBox returns the ranges of latitude and longitude containing every point within radius
+// meters of center, so the SQL storages can narrow the rows down before computing distances.
+// Near the poles and across the antimeridian the longitudes span the whole range.
+func BoundingBox(center Point, radius float64) (minLat, maxLat, minLon, maxLon float64) {
+	delta := radius / EarthRadius * 180 / math.Pi
+
+	minLat = center.Latitude - delta
+	maxLat = center.Latitude + delta
+
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180
+	}
+
+	// Meridians converge towards the poles, the box is widest at the latitude closest to one.
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180
+	deltaLon := delta / math.Cos(widest)
+
+	minLon = center.Longitude - deltaLon
+	maxLon = center.Longitude + deltaLon
+
+	if minLon < -180 || maxLon > 180 {
+		return minLat, maxLat, -180, 180
+	}
+
+	return minLat, maxLat, minLon, maxLon
+}
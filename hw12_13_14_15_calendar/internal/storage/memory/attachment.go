@@ -0,0 +1,87 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// CreateAttachment adds the attachment to a live event.
+func (s *Storage) CreateAttachment(_ context.Context, attachment storage.Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event, ok := s.m[attachment.EventID]; !ok || event.DeletedAt.Valid {
+		return storage.ErrEventNotExists
+	}
+
+	return s.commit(change{Kind: putAttachment, Attachment: &attachment})
+}
+
+func (s *Storage) GetAttachment(_ context.Context, id string) (*storage.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachment, ok := s.attachments[id]
+	if !ok {
+		return nil, storage.ErrAttachmentNotExists
+	}
+
+	return &attachment, nil
+}
+
+// ListAttachments returns the attachments of the event in the order they were added.
+func (s *Storage) ListAttachments(_ context.Context, eventID string) ([]*storage.Attachment, error) {
+	var attachments []*storage.Attachment
+
+	s.mu.RLock()
+	for _, attachment := range s.attachments {
+		if attachment.EventID == eventID {
+			attachments = append(attachments, &attachment)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortByCreation(attachments)
+
+	return attachments, nil
+}
+
+// ListOrphanedAttachments returns the attachments of events which no longer exist.
+func (s *Storage) ListOrphanedAttachments(_ context.Context) ([]*storage.Attachment, error) {
+	var attachments []*storage.Attachment
+
+	s.mu.RLock()
+	for _, attachment := range s.attachments {
+		if _, ok := s.m[attachment.EventID]; !ok {
+			attachments = append(attachments, &attachment)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortByCreation(attachments)
+
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attachments[id]; !ok {
+		return storage.ErrAttachmentNotExists
+	}
+
+	return s.commit(change{Kind: removeAttachment, ID: id})
+}
+
+func sortByCreation(attachments []*storage.Attachment) {
+	sort.Slice(attachments, func(i, j int) bool {
+		if !attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+		}
+
+		return attachments[i].ID < attachments[j].ID
+	})
+}
@@ -0,0 +1,161 @@
+package memorystorage
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) CreateCalendar(_ context.Context, calendar storage.Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[calendar.ID]; ok {
+		return storage.ErrCalendarExists
+	}
+
+	return s.commit(change{Kind: putCalendar, Calendar: &calendar})
+}
+
+// calendarExists reports whether an event may reference the calendar, it must be called with the lock held.
+func (s *Storage) calendarExists(id sql.NullString) bool {
+	if !id.Valid {
+		return true
+	}
+
+	_, ok := s.calendars[id.String]
+
+	return ok
+}
+
+func (s *Storage) UpdateCalendar(_ context.Context, calendar storage.Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.calendars[calendar.ID]
+	if !ok {
+		return storage.ErrCalendarNotExists
+	}
+
+	calendar.OwnerID = existing.OwnerID
+
+	return s.commit(change{Kind: putCalendar, Calendar: &calendar})
+}
+
+// DeleteCalendar refuses to delete a calendar with live events. Events in the trash lose the
+// reference and shares are removed together with the calendar.
+func (s *Storage) DeleteCalendar(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[id]; !ok {
+		return storage.ErrCalendarNotExists
+	}
+
+	for _, event := range s.m {
+		if event.CalendarID.String == id && !event.DeletedAt.Valid {
+			return storage.ErrCalendarNotEmpty
+		}
+	}
+
+	var changes []change
+
+	for _, event := range s.m {
+		if event.CalendarID.String == id {
+			event.CalendarID = sql.NullString{}
+			changes = append(changes, change{Kind: putEvent, Event: &event})
+		}
+	}
+
+	changes = append(changes, change{Kind: removeCalendar, ID: id})
+
+	return s.commit(changes...)
+}
+
+func (s *Storage) GetCalendar(_ context.Context, id string) (*storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendar, ok := s.calendars[id]
+	if !ok {
+		return nil, storage.ErrCalendarNotExists
+	}
+
+	return &calendar, nil
+}
+
+// ListCalendars returns calendars owned by the user or shared with them.
+func (s *Storage) ListCalendars(_ context.Context, userID string) ([]*storage.Calendar, error) {
+	var calendars []*storage.Calendar
+
+	s.mu.RLock()
+	for id, calendar := range s.calendars {
+		if _, shared := s.shares[id][userID]; calendar.OwnerID == userID || shared {
+			calendars = append(calendars, &calendar)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].Name < calendars[j].Name
+	})
+
+	return calendars, nil
+}
+
+// ListCalendarEvents returns live events of the calendar ordered by start date.
+func (s *Storage) ListCalendarEvents(_ context.Context, calendarID string) ([]*storage.Event, error) {
+	var events []*storage.Event
+
+	s.mu.RLock()
+	for _, event := range s.m {
+		if event.CalendarID.String == calendarID && event.CalendarID.Valid && !event.DeletedAt.Valid {
+			events = append(events, &event)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortByStart(events)
+
+	return events, nil
+}
+
+// SetShare creates the share or replaces the permission of the existing one.
+func (s *Storage) SetShare(_ context.Context, share storage.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.calendars[share.CalendarID]; !ok {
+		return storage.ErrCalendarNotExists
+	}
+
+	return s.commit(change{Kind: putShare, Share: &share})
+}
+
+func (s *Storage) DeleteShare(_ context.Context, calendarID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shares[calendarID][userID]; !ok {
+		return storage.ErrShareNotExists
+	}
+
+	return s.commit(change{Kind: removeShare, Share: &storage.Share{CalendarID: calendarID, UserID: userID}})
+}
+
+func (s *Storage) ListShares(_ context.Context, calendarID string) ([]*storage.Share, error) {
+	s.mu.RLock()
+	shares := make([]*storage.Share, 0, len(s.shares[calendarID]))
+	for userID, permission := range s.shares[calendarID] {
+		shares = append(shares, &storage.Share{CalendarID: calendarID, UserID: userID, Permission: permission})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].UserID < shares[j].UserID
+	})
+
+	return shares, nil
+}
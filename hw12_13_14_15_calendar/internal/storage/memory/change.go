@@ -0,0 +1,127 @@
+package memorystorage
+
+import (
+	"slices"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	putEvent          = "put_event"
+	removeEvent       = "remove_event"
+	putCalendar       = "put_calendar"
+	removeCalendar    = "remove_calendar"
+	putShare          = "put_share"
+	removeShare       = "remove_share"
+	addAudit          = "add_audit"
+	putAttachment     = "put_attachment"
+	removeAttachment  = "remove_attachment"
+	putPreferences    = "put_preferences"
+	removePreferences = "remove_preferences"
+	putOptOut         = "put_opt_out"
+	removeOptOut      = "remove_opt_out"
+)
+
+// change is a single state transition. Mutations are validated first and then expressed as
+// changes holding the resulting values, so replaying the log does not depend on the clock.
+type change struct {
+	Kind        string               `json:"kind"`
+	ID          string               `json:"id,omitempty"`
+	Event       *storage.Event       `json:"event,omitempty"`
+	Calendar    *storage.Calendar    `json:"calendar,omitempty"`
+	Share       *storage.Share       `json:"share,omitempty"`
+	Audit       *storage.AuditEntry  `json:"audit,omitempty"`
+	Attachment  *storage.Attachment  `json:"attachment,omitempty"`
+	Preferences *storage.Preferences `json:"preferences,omitempty"`
+	OptOut      *storage.OptOut      `json:"opt_out,omitempty"`
+}
+
+// commit logs the changes as one entry and applies them, it must be called with the write lock held.
+func (s *Storage) commit(changes ...change) error {
+	if err := s.log(changes); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		s.redo(c)
+	}
+
+	return nil
+}
+
+// log appends already validated changes to the write-ahead log of a persistent storage.
+func (s *Storage) log(changes []change) error {
+	if s.persistence == nil || len(changes) == 0 {
+		return nil
+	}
+
+	return s.persistence.wal.append(changes)
+}
+
+// redo applies a change without any checks, it is used both for new changes and for replay.
+func (s *Storage) redo(c change) {
+	switch c.Kind {
+	case putEvent:
+		if prev, ok := s.m[c.Event.ID]; ok {
+			s.removeFromIndexes(prev)
+		}
+
+		event := *c.Event
+		event.Tags = ownTags(event.Tags)
+
+		s.m[event.ID] = event
+		s.addToIndexes(event)
+	case removeEvent:
+		if prev, ok := s.m[c.ID]; ok {
+			s.removeFromIndexes(prev)
+			delete(s.m, c.ID)
+		}
+
+		delete(s.optOuts, c.ID)
+	case putCalendar:
+		s.calendars[c.Calendar.ID] = *c.Calendar
+	case removeCalendar:
+		delete(s.calendars, c.ID)
+		delete(s.shares, c.ID)
+	case putShare:
+		if s.shares[c.Share.CalendarID] == nil {
+			s.shares[c.Share.CalendarID] = make(map[string]string)
+		}
+
+		s.shares[c.Share.CalendarID][c.Share.UserID] = c.Share.Permission
+	case removeShare:
+		delete(s.shares[c.Share.CalendarID], c.Share.UserID)
+	case addAudit:
+		s.audit[c.Audit.EventID] = append(s.audit[c.Audit.EventID], *c.Audit)
+		s.auditSeq = max(s.auditSeq, c.Audit.ID)
+	case putAttachment:
+		s.attachments[c.Attachment.ID] = *c.Attachment
+	case removeAttachment:
+		delete(s.attachments, c.ID)
+	case putPreferences:
+		s.preferences[c.Preferences.UserID] = *c.Preferences
+	case removePreferences:
+		delete(s.preferences, c.ID)
+	case putOptOut:
+		if s.optOuts[c.OptOut.EventID] == nil {
+			s.optOuts[c.OptOut.EventID] = make(map[string]struct{})
+		}
+
+		s.optOuts[c.OptOut.EventID][c.OptOut.UserID] = struct{}{}
+	case removeOptOut:
+		delete(s.optOuts[c.OptOut.EventID], c.OptOut.UserID)
+	}
+}
+
+// ownTags copies the tags, so the caller cannot change the stored event, and orders them like
+// the SQL storages return them.
+func ownTags(tags storage.Tags) storage.Tags {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	owned := slices.Clone(tags)
+	slices.Sort(owned)
+
+	return owned
+}
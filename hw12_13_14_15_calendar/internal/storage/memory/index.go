@@ -0,0 +1,224 @@
+package memorystorage
+
+import (
+	"hash/maphash"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+var prioritySeed = maphash.MakeSeed()
+
+// index orders event IDs by a time key. It is a treap: a search tree by key that is also a heap
+// by a random priority, which keeps it balanced in expectation, so updates take O(log n) and a
+// range of k events is visited in O(log n + k).
+type index struct {
+	root *node
+	size int
+}
+
+type indexKey struct {
+	at time.Time
+	id string
+}
+
+type node struct {
+	key         indexKey
+	priority    uint64
+	left, right *node
+}
+
+func (k indexKey) less(other indexKey) bool {
+	if c := k.at.Compare(other.at); c != 0 {
+		return c < 0
+	}
+
+	return k.id < other.id
+}
+
+// insert expects the key to be absent.
+func (x *index) insert(at time.Time, id string) {
+	key := indexKey{at: at, id: id}
+	x.root = insertNode(x.root, &node{key: key, priority: maphash.String(prioritySeed, id)})
+	x.size++
+}
+
+func (x *index) remove(at time.Time, id string) {
+	var removed bool
+
+	x.root, removed = removeNode(x.root, indexKey{at: at, id: id})
+	if removed {
+		x.size--
+	}
+}
+
+// ascend calls fn for the IDs with keys in [from, to) in the order of keys and then IDs.
+func (x *index) ascend(from, to time.Time, fn func(id string)) {
+	ascendNode(x.root, from, to, fn)
+}
+
+func insertNode(n, nn *node) *node {
+	if n == nil {
+		return nn
+	}
+
+	if nn.priority > n.priority {
+		nn.left, nn.right = split(n, nn.key)
+		return nn
+	}
+
+	if nn.key.less(n.key) {
+		n.left = insertNode(n.left, nn)
+	} else {
+		n.right = insertNode(n.right, nn)
+	}
+
+	return n
+}
+
+func removeNode(n *node, key indexKey) (*node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	var removed bool
+
+	switch {
+	case key.less(n.key):
+		n.left, removed = removeNode(n.left, key)
+	case n.key.less(key):
+		n.right, removed = removeNode(n.right, key)
+	default:
+		return merge(n.left, n.right), true
+	}
+
+	return n, removed
+}
+
+// split divides the tree into keys less than key and the rest.
+func split(n *node, key indexKey) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+
+	if n.key.less(key) {
+		left, right := split(n.right, key)
+		n.right = left
+
+		return n, right
+	}
+
+	left, right := split(n.left, key)
+	n.left = right
+
+	return left, n
+}
+
+// merge joins two trees where all keys of a are less than the keys of b.
+func merge(a, b *node) *node {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.priority > b.priority:
+		a.right = merge(a.right, b)
+		return a
+	default:
+		b.left = merge(a, b.left)
+		return b
+	}
+}
+
+func ascendNode(n *node, from, to time.Time, fn func(id string)) {
+	if n == nil {
+		return
+	}
+
+	afterFrom := !n.key.at.Before(from)
+	beforeTo := n.key.at.Before(to)
+
+	if afterFrom {
+		ascendNode(n.left, from, to, fn)
+	}
+
+	if afterFrom && beforeTo {
+		fn(n.key.id)
+	}
+
+	if beforeTo {
+		ascendNode(n.right, from, to, fn)
+	}
+}
+
+// notifyDay is the UTC day on which a notification about the event is due.
+func notifyDay(event storage.Event) time.Time {
+	start := event.StartDate.UTC()
+
+	return time.Date(start.Year(), start.Month(), start.Day()-int(event.NotifyDays.Int32), 0, 0, 0, 0, time.UTC)
+}
+
+// addToIndexes must be called with the write lock held. Events in the trash are not indexed.
+func (s *Storage) addToIndexes(event storage.Event) {
+	if event.DeletedAt.Valid {
+		return
+	}
+
+	s.byStart.insert(event.StartDate, event.ID)
+
+	if event.NotifyDays.Int32 > 0 {
+		s.byNotify.insert(notifyDay(event), event.ID)
+	}
+
+	if event.UserID.Valid {
+		byUser := s.byUser[event.UserID.String]
+		if byUser == nil {
+			byUser = &index{}
+			s.byUser[event.UserID.String] = byUser
+		}
+
+		byUser.insert(event.StartDate, event.ID)
+	}
+}
+
+// removeFromIndexes must be called with the write lock held.
+func (s *Storage) removeFromIndexes(event storage.Event) {
+	if event.DeletedAt.Valid {
+		return
+	}
+
+	s.byStart.remove(event.StartDate, event.ID)
+
+	if event.NotifyDays.Int32 > 0 {
+		s.byNotify.remove(notifyDay(event), event.ID)
+	}
+
+	if byUser := s.byUser[event.UserID.String]; event.UserID.Valid && byUser != nil {
+		byUser.remove(event.StartDate, event.ID)
+
+		if byUser.size == 0 {
+			delete(s.byUser, event.UserID.String)
+		}
+	}
+}
+
+// collect returns copies of the events found in the index in one allocation, it must be called
+// with the lock held.
+func (s *Storage) collect(x *index, from, to time.Time) []*storage.Event {
+	var events []storage.Event
+
+	x.ascend(from, to, func(id string) {
+		events = append(events, s.m[id])
+	})
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	result := make([]*storage.Event, len(events))
+	for i := range events {
+		result[i] = &events[i]
+	}
+
+	return result
+}
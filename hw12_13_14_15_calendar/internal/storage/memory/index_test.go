@@ -0,0 +1,296 @@
+package memorystorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	internalstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rnd := rand.New(rand.NewPCG(1, 2))
+
+	var (
+		x    index
+		keys []indexKey
+	)
+
+	for i := range 5000 {
+		if len(keys) > 0 && rnd.IntN(3) == 0 {
+			j := rnd.IntN(len(keys))
+			x.remove(keys[j].at, keys[j].id)
+			keys = slices.Delete(keys, j, j+1)
+
+			continue
+		}
+
+		// Few distinct times, so that the order by ID is exercised too.
+		key := indexKey{at: base.Add(time.Duration(rnd.IntN(100)) * time.Hour), id: fmt.Sprintf("%05d", i)}
+		x.insert(key.at, key.id)
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b indexKey) int {
+		if a.less(b) {
+			return -1
+		}
+
+		return 1
+	})
+
+	require.Equal(t, len(keys), x.size)
+
+	for range 100 {
+		from := base.Add(time.Duration(rnd.IntN(110)-5) * time.Hour)
+		to := from.Add(time.Duration(rnd.IntN(30)) * time.Hour)
+
+		var want, got []string
+
+		for _, key := range keys {
+			if !key.at.Before(from) && key.at.Before(to) {
+				want = append(want, key.id)
+			}
+		}
+
+		x.ascend(from, to, func(id string) { got = append(got, id) })
+
+		require.Equal(t, want, got, "%s - %s", from, to)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2020, 1, 10, 10, 0, 0, 0, time.UTC)
+	user := sql.NullString{String: "u1", Valid: true}
+
+	s := New()
+	require.NoError(t, s.CreateEvent(ctx, internalstorage.Event{ID: "1", StartDate: date, UserID: user}, nil))
+	require.NoError(t, s.CreateEvent(ctx, internalstorage.Event{
+		ID: "2", StartDate: date.Add(time.Hour), UserID: user, NotifyDays: sql.NullInt32{Int32: 2, Valid: true},
+	}, nil))
+
+	events, err := s.ListUserEvents(ctx, "u1", date, date.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	// Moving the event to another user and day updates all indexes.
+	require.NoError(t, s.UpdateEvent(ctx, "2", internalstorage.Event{
+		StartDate:  date.AddDate(0, 0, 1),
+		UserID:     sql.NullString{String: "u2", Valid: true},
+		NotifyDays: sql.NullInt32{Int32: 1, Valid: true},
+	}, nil))
+
+	events, err = s.ListUserEvents(ctx, "u1", date, date.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "1", events[0].ID)
+
+	events, err = s.ListEventsForNotify(ctx, date.AddDate(0, 0, -2))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = s.ListEventsForNotify(ctx, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "2", events[0].ID)
+
+	require.NoError(t, s.DeleteEvent(ctx, internalstorage.Event{ID: "1"}, nil))
+
+	events, err = s.ListUserEvents(ctx, "u1", date, date.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Empty(t, events)
+	require.NotContains(t, s.byUser, "u1")
+
+	require.NoError(t, s.RestoreEvent(ctx, "1", nil))
+
+	events, err = s.ListEventsForDay(ctx, date)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "1", events[0].ID)
+}
+
+const (
+	benchEvents = 1_000_000
+	benchUsers  = 1000
+	benchYears  = 5
+)
+
+var (
+	benchOnce    sync.Once
+	benchStorage *Storage
+	benchStart   = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// loadBenchStorage builds the storage once per run: 1M events over five years with a thousand
+// users and a random notification setting.
+func loadBenchStorage(b *testing.B) *Storage {
+	b.Helper()
+
+	benchOnce.Do(func() {
+		rnd := rand.New(rand.NewPCG(1, 2))
+		s := New()
+
+		for i := range benchEvents {
+			start := benchStart.Add(time.Duration(rnd.Int64N(int64(benchYears * 365 * 24 * time.Hour))))
+			event := internalstorage.Event{
+				ID:         fmt.Sprintf("%08d", i),
+				StartDate:  start,
+				EndDate:    start.Add(time.Hour),
+				UserID:     sql.NullString{String: fmt.Sprintf("user%d", rnd.IntN(benchUsers)), Valid: true},
+				NotifyDays: sql.NullInt32{Int32: rnd.Int32N(4), Valid: true},
+			}
+			s.redo(change{Kind: putEvent, Event: &event})
+		}
+
+		benchStorage = s
+	})
+
+	return benchStorage
+}
+
+// scanStartingBetween is the full map scan used before the index, kept as the baseline.
+func scanStartingBetween(s *Storage, userID string, from, to time.Time) []*internalstorage.Event {
+	var events []*internalstorage.Event
+
+	s.mu.RLock()
+	for _, event := range s.m {
+		if event.DeletedAt.Valid || event.StartDate.Before(from) || !event.StartDate.Before(to) {
+			continue
+		}
+
+		if userID != "" && event.UserID.String != userID {
+			continue
+		}
+
+		events = append(events, &event)
+	}
+	s.mu.RUnlock()
+
+	sortByStart(events)
+
+	return events
+}
+
+func scanForNotify(s *Storage, date time.Time) []*internalstorage.Event {
+	var events []*internalstorage.Event
+
+	s.mu.RLock()
+	for _, event := range s.m {
+		if event.NotifyDays.Int32 <= 0 || event.DeletedAt.Valid {
+			continue
+		}
+
+		needNotifyDate := date.UTC().AddDate(0, 0, int(event.NotifyDays.Int32)).Format("2006-01-02")
+
+		if needNotifyDate == event.StartDate.UTC().Format("2006-01-02") {
+			events = append(events, &event)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortByStart(events)
+
+	return events
+}
+
+func BenchmarkListEventsForDay(b *testing.B) {
+	s := loadBenchStorage(b)
+	ctx := context.Background()
+	date := benchStart.AddDate(2, 3, 4)
+
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			_, _ = s.ListEventsForDay(ctx, date)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		from, to := internalstorage.DayRange(date)
+
+		for range b.N {
+			_ = scanStartingBetween(s, "", from, to)
+		}
+	})
+}
+
+func BenchmarkListEventsForMonth(b *testing.B) {
+	s := loadBenchStorage(b)
+	ctx := context.Background()
+	date := benchStart.AddDate(2, 3, 4)
+
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			_, _ = s.ListEventsForMonth(ctx, date)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		from, to := internalstorage.MonthRange(date)
+
+		for range b.N {
+			_ = scanStartingBetween(s, "", from, to)
+		}
+	})
+}
+
+func BenchmarkListEventsForNotify(b *testing.B) {
+	s := loadBenchStorage(b)
+	ctx := context.Background()
+	date := benchStart.AddDate(2, 3, 4)
+
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			_, _ = s.ListEventsForNotify(ctx, date)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for range b.N {
+			_ = scanForNotify(s, date)
+		}
+	})
+}
+
+func BenchmarkListUserEvents(b *testing.B) {
+	s := loadBenchStorage(b)
+	ctx := context.Background()
+	from, to := internalstorage.MonthRange(benchStart.AddDate(2, 3, 4))
+
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			_, _ = s.ListUserEvents(ctx, "user42", from, to)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for range b.N {
+			_ = scanStartingBetween(s, "user42", from, to)
+		}
+	})
+}
+
+func BenchmarkCreateEvent(b *testing.B) {
+	s := New()
+	ctx := context.Background()
+	rnd := rand.New(rand.NewPCG(1, 2))
+
+	b.ResetTimer()
+
+	for i := range b.N {
+		start := benchStart.Add(time.Duration(rnd.Int64N(int64(benchYears * 365 * 24 * time.Hour))))
+		_ = s.CreateEvent(ctx, internalstorage.Event{
+			ID:         fmt.Sprintf("%08d", i),
+			StartDate:  start,
+			UserID:     sql.NullString{String: fmt.Sprintf("user%d", rnd.IntN(benchUsers)), Valid: true},
+			NotifyDays: sql.NullInt32{Int32: rnd.Int32N(4), Valid: true},
+		}, nil)
+	}
+}
@@ -0,0 +1,323 @@
+package memorystorage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	FsyncAlways   = "always"
+	FsyncInterval = "interval"
+	FsyncNever    = "never"
+
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+)
+
+var ErrUnknownFsyncPolicy = errors.New("unknown fsync policy")
+
+// snapshot is the compacted state, Seq is the last log entry it contains.
+type snapshot struct {
+	Seq         uint64                `json:"seq"`
+	Events      []storage.Event       `json:"events"`
+	Calendars   []storage.Calendar    `json:"calendars"`
+	Shares      []storage.Share       `json:"shares"`
+	Audit       []storage.AuditEntry  `json:"audit"`
+	Attachments []storage.Attachment  `json:"attachments"`
+	Preferences []storage.Preferences `json:"preferences"`
+	OptOuts     []storage.OptOut      `json:"opt_outs"`
+}
+
+type persistence struct {
+	dir         string
+	wal         *wal
+	snapshotSeq uint64
+
+	stop chan struct{}
+	done sync.WaitGroup
+
+	errMu sync.Mutex
+	err   error
+}
+
+// Open returns a storage kept in the directory of cfg: the last snapshot is loaded and the
+// write-ahead log is replayed over it. Without a directory the storage lives in memory only.
+func Open(cfg config.MemoryConf) (*Storage, error) {
+	s := New()
+
+	if cfg.Dir == "" {
+		return s, nil
+	}
+
+	var syncEach bool
+
+	switch cfg.Fsync {
+	case FsyncAlways, "":
+		syncEach = true
+	case FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFsyncPolicy, cfg.Fsync)
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	snap, err := readSnapshot(filepath.Join(cfg.Dir, snapshotFile))
+	if err != nil {
+		return nil, err
+	}
+
+	s.load(snap)
+
+	w, entries, err := openWAL(filepath.Join(cfg.Dir, walFile), syncEach)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		// Entries up to the snapshot are left over from a crash before the log was reset.
+		if e.Seq <= snap.Seq {
+			continue
+		}
+
+		for _, c := range e.Changes {
+			s.redo(c)
+		}
+	}
+
+	w.seq = max(w.seq, snap.Seq)
+
+	p := &persistence{
+		dir:         cfg.Dir,
+		wal:         w,
+		snapshotSeq: snap.Seq,
+		stop:        make(chan struct{}),
+	}
+	s.persistence = p
+
+	// Compacting right away means the same log is never replayed twice.
+	if err = p.snapshot(s); err != nil {
+		_ = w.close()
+		return nil, err
+	}
+
+	var fsyncEvery time.Duration
+	if cfg.Fsync == FsyncInterval {
+		fsyncEvery = cfg.FsyncInterval
+	}
+
+	p.done.Add(1)
+	go p.run(s, cfg.SnapshotInterval, fsyncEvery)
+
+	return s, nil
+}
+
+// run takes periodic snapshots and syncs the log for the interval policy. Zero intervals turn
+// the corresponding job off, the error of the last run is reported by Ping.
+func (p *persistence) run(s *Storage, snapshotEvery, fsyncEvery time.Duration) {
+	defer p.done.Done()
+
+	var snapshots, syncs <-chan time.Time
+
+	if snapshotEvery > 0 {
+		ticker := time.NewTicker(snapshotEvery)
+		defer ticker.Stop()
+
+		snapshots = ticker.C
+	}
+
+	if fsyncEvery > 0 {
+		ticker := time.NewTicker(fsyncEvery)
+		defer ticker.Stop()
+
+		syncs = ticker.C
+	}
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-snapshots:
+			p.setErr(p.snapshot(s))
+		case <-syncs:
+			p.setErr(p.wal.sync())
+		}
+	}
+}
+
+// snapshot writes the state if it changed since the last snapshot and resets the log. The read
+// lock keeps writers, and so the log, still while the state is written.
+func (p *persistence) snapshot(s *Storage) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p.wal.seq == p.snapshotSeq {
+		return nil
+	}
+
+	data, err := json.Marshal(s.dump(p.wal.seq))
+	if err != nil {
+		return err
+	}
+
+	if err = writeFileAtomic(filepath.Join(p.dir, snapshotFile), data); err != nil {
+		return err
+	}
+
+	p.snapshotSeq = p.wal.seq
+
+	return p.wal.reset()
+}
+
+func (p *persistence) close(s *Storage) error {
+	close(p.stop)
+	p.done.Wait()
+
+	return errors.Join(p.snapshot(s), p.wal.close())
+}
+
+func (p *persistence) setErr(err error) {
+	p.errMu.Lock()
+	p.err = err
+	p.errMu.Unlock()
+}
+
+func (p *persistence) lastErr() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	return p.err
+}
+
+// dump must be called with the lock held.
+func (s *Storage) dump(seq uint64) snapshot {
+	snap := snapshot{Seq: seq}
+
+	for _, event := range s.m {
+		snap.Events = append(snap.Events, event)
+	}
+
+	for _, calendar := range s.calendars {
+		snap.Calendars = append(snap.Calendars, calendar)
+	}
+
+	for calendarID, users := range s.shares {
+		for userID, permission := range users {
+			snap.Shares = append(snap.Shares, storage.Share{
+				CalendarID: calendarID,
+				UserID:     userID,
+				Permission: permission,
+			})
+		}
+	}
+
+	for _, entries := range s.audit {
+		snap.Audit = append(snap.Audit, entries...)
+	}
+
+	for _, attachment := range s.attachments {
+		snap.Attachments = append(snap.Attachments, attachment)
+	}
+
+	for _, preferences := range s.preferences {
+		snap.Preferences = append(snap.Preferences, preferences)
+	}
+
+	for eventID, users := range s.optOuts {
+		for userID := range users {
+			snap.OptOuts = append(snap.OptOuts, storage.OptOut{EventID: eventID, UserID: userID})
+		}
+	}
+
+	return snap
+}
+
+func (s *Storage) load(snap snapshot) {
+	for _, event := range snap.Events {
+		s.redo(change{Kind: putEvent, Event: &event})
+	}
+
+	for _, calendar := range snap.Calendars {
+		s.redo(change{Kind: putCalendar, Calendar: &calendar})
+	}
+
+	for _, share := range snap.Shares {
+		s.redo(change{Kind: putShare, Share: &share})
+	}
+
+	for _, entry := range snap.Audit {
+		s.redo(change{Kind: addAudit, Audit: &entry})
+	}
+
+	for _, attachment := range snap.Attachments {
+		s.redo(change{Kind: putAttachment, Attachment: &attachment})
+	}
+
+	for _, preferences := range snap.Preferences {
+		s.redo(change{Kind: putPreferences, Preferences: &preferences})
+	}
+
+	for _, optOut := range snap.OptOuts {
+		s.redo(change{Kind: putOptOut, OptOut: &optOut})
+	}
+}
+
+// readSnapshot returns an empty snapshot if there is none yet.
+func readSnapshot(path string) (snapshot, error) {
+	var snap snapshot
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, nil
+	}
+
+	if err != nil {
+		return snap, err
+	}
+
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return snap, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+
+	return snap, nil
+}
+
+// writeFileAtomic replaces the file through a synced temporary one, so a crash leaves either
+// the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	file, err := os.OpenFile(filepath.Clean(tmp), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if err = errors.Join(err, file.Close()); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(dir.Sync(), dir.Close())
+}
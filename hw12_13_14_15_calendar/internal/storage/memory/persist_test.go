@@ -0,0 +1,201 @@
+package memorystorage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	internalstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentStorageContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) app.Storage {
+		t.Helper()
+
+		s, err := Open(config.MemoryConf{Dir: t.TempDir()})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Close(context.Background()) })
+
+		return s
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	date := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	calendarID := sql.NullString{String: "c1", Valid: true}
+
+	fill := func(t *testing.T, s *Storage) {
+		t.Helper()
+
+		ctx := context.Background()
+
+		require.NoError(t, s.CreateCalendar(ctx, internalstorage.Calendar{ID: "c1", OwnerID: "u1", Name: "work"}))
+		require.NoError(t, s.SetShare(ctx, internalstorage.Share{CalendarID: "c1", UserID: "u2", Permission: "read"}))
+		require.NoError(t, s.CreateEvent(ctx, internalstorage.Event{ID: "1", StartDate: date, CalendarID: calendarID},
+			&internalstorage.AuditEntry{EventID: "1", Action: "create"}))
+		require.NoError(t, s.CreateEvent(ctx, internalstorage.Event{ID: "2", StartDate: date}, nil))
+		require.NoError(t, s.DeleteEvent(ctx, internalstorage.Event{ID: "2"}, nil))
+		require.NoError(t, s.CreateAttachment(ctx, internalstorage.Attachment{ID: "a1", EventID: "1", Size: 5}))
+		require.NoError(t, s.SetPreferences(ctx, internalstorage.Preferences{UserID: "alice", Channel: "email"}))
+		require.NoError(t, s.SetOptOut(ctx, internalstorage.OptOut{EventID: "1", UserID: "alice"}))
+
+		_, err := s.ApplyBatch(ctx, []internalstorage.Operation{
+			{Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "3", StartDate: date}},
+			{Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "1"}},
+		}, true)
+		require.ErrorIs(t, err, internalstorage.ErrBatchAborted)
+	}
+
+	check := func(t *testing.T, s *Storage) {
+		t.Helper()
+
+		ctx := context.Background()
+
+		events, err := s.ListEventsForDay(ctx, date)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "1", events[0].ID)
+		require.Equal(t, calendarID, events[0].CalendarID)
+
+		trash, err := s.ListDeletedEvents(ctx)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		require.Equal(t, "2", trash[0].ID)
+
+		shares, err := s.ListShares(ctx, "c1")
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+		require.Equal(t, "read", shares[0].Permission)
+
+		attachments, err := s.ListAttachments(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, attachments, 1)
+		require.Equal(t, int64(5), attachments[0].Size)
+
+		preferences, err := s.GetPreferences(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, "email", preferences.Channel)
+
+		optOuts, err := s.ListOptOuts(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, optOuts, 1)
+
+		require.NoError(t, s.UpdateEvent(ctx, "1", internalstorage.Event{StartDate: date, CalendarID: calendarID},
+			&internalstorage.AuditEntry{EventID: "1", Action: "update"}))
+
+		entries, err := s.ListAuditEntries(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, []int64{1, 2}, []int64{entries[0].ID, entries[1].ID})
+	}
+
+	t.Run("snapshot on close", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, err := Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		fill(t, s)
+		require.NoError(t, s.Close(context.Background()))
+		require.Zero(t, fileSize(t, filepath.Join(dir, walFile)))
+
+		s, err = Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		defer s.Close(context.Background())
+
+		check(t, s)
+	})
+
+	t.Run("log replay after crash", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, err := Open(config.MemoryConf{Dir: dir, Fsync: FsyncNever})
+		require.NoError(t, err)
+		fill(t, s)
+		crash(s)
+
+		// A torn last line is dropped.
+		appendFile(t, filepath.Join(dir, walFile), `{"seq":100,"chan`)
+
+		s, err = Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		defer s.Close(context.Background())
+
+		require.Zero(t, fileSize(t, filepath.Join(dir, walFile)))
+		check(t, s)
+	})
+
+	t.Run("log left after snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, err := Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		fill(t, s)
+
+		log, err := os.ReadFile(filepath.Join(dir, walFile))
+		require.NoError(t, err)
+
+		// The crash happens after the snapshot is written but before the log is reset.
+		require.NoError(t, s.persistence.snapshot(s))
+		crash(s)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, walFile), log, 0o600))
+
+		s, err = Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		defer s.Close(context.Background())
+
+		check(t, s)
+	})
+
+	t.Run("corrupted log", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s, err := Open(config.MemoryConf{Dir: dir})
+		require.NoError(t, err)
+		fill(t, s)
+		crash(s)
+
+		appendFile(t, filepath.Join(dir, walFile), "garbage\n{}\n")
+
+		_, err = Open(config.MemoryConf{Dir: dir})
+		require.ErrorIs(t, err, ErrCorruptedLog)
+	})
+
+	t.Run("unknown fsync policy", func(t *testing.T) {
+		_, err := Open(config.MemoryConf{Dir: t.TempDir(), Fsync: "sometimes"})
+		require.ErrorIs(t, err, ErrUnknownFsyncPolicy)
+	})
+}
+
+// crash stops the storage without the final snapshot.
+func crash(s *Storage) {
+	close(s.persistence.stop)
+	s.persistence.done.Wait()
+	_ = s.persistence.wal.close()
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	return info.Size()
+}
@@ -0,0 +1,100 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) GetPreferences(_ context.Context, userID string) (*storage.Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	preferences, ok := s.preferences[userID]
+	if !ok {
+		return nil, storage.ErrPreferencesNotExists
+	}
+
+	return &preferences, nil
+}
+
+// SetPreferences creates or replaces the preferences of the user.
+func (s *Storage) SetPreferences(_ context.Context, preferences storage.Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commit(change{Kind: putPreferences, Preferences: &preferences})
+}
+
+// ListPreferences returns the preferences of the users who chose the delivery, ordered by user.
+func (s *Storage) ListPreferences(_ context.Context, delivery string) ([]*storage.Preferences, error) {
+	var preferences []*storage.Preferences
+
+	s.mu.RLock()
+	for _, p := range s.preferences {
+		if p.Delivery == delivery {
+			preferences = append(preferences, &p)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(preferences, func(i, j int) bool { return preferences[i].UserID < preferences[j].UserID })
+
+	return preferences, nil
+}
+
+func (s *Storage) DeletePreferences(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.preferences[userID]; !ok {
+		return storage.ErrPreferencesNotExists
+	}
+
+	return s.commit(change{Kind: removePreferences, ID: userID})
+}
+
+// SetOptOut turns off the notifications about the event for the user, repeating it is a no-op.
+func (s *Storage) SetOptOut(_ context.Context, optOut storage.OptOut) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.m[optOut.EventID]; !ok {
+		return storage.ErrEventNotExists
+	}
+
+	if _, ok := s.optOuts[optOut.EventID][optOut.UserID]; ok {
+		return nil
+	}
+
+	return s.commit(change{Kind: putOptOut, OptOut: &optOut})
+}
+
+func (s *Storage) DeleteOptOut(_ context.Context, eventID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.optOuts[eventID][userID]; !ok {
+		return storage.ErrOptOutNotExists
+	}
+
+	return s.commit(change{Kind: removeOptOut, OptOut: &storage.OptOut{EventID: eventID, UserID: userID}})
+}
+
+// ListOptOuts returns the opt-outs of the user ordered by event.
+func (s *Storage) ListOptOuts(_ context.Context, userID string) ([]*storage.OptOut, error) {
+	var optOuts []*storage.OptOut
+
+	s.mu.RLock()
+	for eventID, users := range s.optOuts {
+		if _, ok := users[userID]; ok {
+			optOuts = append(optOuts, &storage.OptOut{EventID: eventID, UserID: userID})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(optOuts, func(i, j int) bool { return optOuts[i].EventID < optOuts[j].EventID })
+
+	return optOuts, nil
+}
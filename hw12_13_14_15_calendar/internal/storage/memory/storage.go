@@ -2,24 +2,64 @@ package memorystorage
 
 import (
 	"context"
+	"database/sql"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
 )
 
+// Storage keeps live events indexed by start date, by notification day and by user, the
+// indexes are maintained by redo together with the map.
 type Storage struct {
-	m  map[string]storage.Event
-	mu sync.RWMutex
+	m         map[string]storage.Event
+	byStart   index
+	byNotify  index
+	byUser    map[string]*index
+	audit     map[string][]storage.AuditEntry
+	auditSeq  int64
+	calendars map[string]storage.Calendar
+	shares    map[string]map[string]string
+	// attachments are kept after their event is removed, see ListOrphanedAttachments.
+	attachments map[string]storage.Attachment
+	preferences map[string]storage.Preferences
+	// optOuts holds the users who opted out by event, they are removed with the event.
+	optOuts map[string]map[string]struct{}
+	mu      sync.RWMutex
+
+	// persistence is nil unless the storage was opened with a data directory.
+	persistence *persistence
 }
 
 func New() *Storage {
 	return &Storage{
-		m: make(map[string]storage.Event),
+		m:           make(map[string]storage.Event),
+		byUser:      make(map[string]*index),
+		audit:       make(map[string][]storage.AuditEntry),
+		calendars:   make(map[string]storage.Calendar),
+		shares:      make(map[string]map[string]string),
+		attachments: make(map[string]storage.Attachment),
+		preferences: make(map[string]storage.Preferences),
+		optOuts:     make(map[string]map[string]struct{}),
 	}
 }
 
-func (s *Storage) CreateEvent(_ context.Context, event storage.Event) error {
+// GetEvent returns the event even if it is in the trash, callers check DeletedAt.
+func (s *Storage) GetEvent(_ context.Context, id string) (*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.m[id]
+	if !ok {
+		return nil, storage.ErrEventNotExists
+	}
+
+	return &event, nil
+}
+
+func (s *Storage) CreateEvent(_ context.Context, event storage.Event, audit *storage.AuditEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -27,117 +67,387 @@ func (s *Storage) CreateEvent(_ context.Context, event storage.Event) error {
 		return storage.ErrEventAlreadyExists
 	}
 
-	s.m[event.ID] = event
-	return nil
+	if !s.calendarExists(event.CalendarID) {
+		return storage.ErrCalendarNotExists
+	}
+
+	return s.commit(s.withAudit(change{Kind: putEvent, Event: &event}, audit)...)
 }
 
-func (s *Storage) UpdateEvent(_ context.Context, id string, event storage.Event) error {
+func (s *Storage) UpdateEvent(_ context.Context, id string, event storage.Event, audit *storage.AuditEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.m[id]; !ok {
+	if existing, ok := s.m[id]; !ok || existing.DeletedAt.Valid {
 		return storage.ErrEventNotExists
 	}
 
-	s.m[id] = event
+	if !s.calendarExists(event.CalendarID) {
+		return storage.ErrCalendarNotExists
+	}
+
+	event.ID = id
 
-	return nil
+	return s.commit(s.withAudit(change{Kind: putEvent, Event: &event}, audit)...)
 }
 
-func (s *Storage) DeleteEvent(_ context.Context, event storage.Event) error {
+// DeleteEvent moves the event to the trash, it stays there until restored or purged.
+func (s *Storage) DeleteEvent(_ context.Context, event storage.Event, audit *storage.AuditEntry) error {
 	s.mu.Lock()
-	delete(s.m, event.ID)
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.m[event.ID]
+	if !ok || existing.DeletedAt.Valid {
+		return storage.ErrEventNotExists
+	}
+
+	existing.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
 
-	return nil
+	return s.commit(s.withAudit(change{Kind: putEvent, Event: &existing}, audit)...)
 }
 
-func (s *Storage) ListEventsForDay(_ context.Context, date time.Time) ([]*storage.Event, error) {
+func (s *Storage) ListDeletedEvents(_ context.Context) ([]*storage.Event, error) {
 	var events []*storage.Event
 
-	dateStr := date.Format("2006-01-02")
-
 	s.mu.RLock()
 	for _, event := range s.m {
-		eventDate := event.StartDate.Format("2006-01-02")
-
-		if eventDate == dateStr {
+		if event.DeletedAt.Valid {
 			events = append(events, &event)
 		}
 	}
 	s.mu.RUnlock()
 
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].DeletedAt.Time.After(events[j].DeletedAt.Time)
+	})
+
 	return events, nil
 }
 
-func (s *Storage) ListEventsForWeek(_ context.Context, date time.Time) ([]*storage.Event, error) {
-	var events []*storage.Event
+func (s *Storage) RestoreEvent(_ context.Context, id string, audit *storage.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.m[id]
+	if !ok || !event.DeletedAt.Valid {
+		return storage.ErrEventNotExists
+	}
 
-	startOfWeek := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endOfWeek := startOfWeek.AddDate(0, 0, 6)
+	event.DeletedAt = sql.NullTime{}
 
-	s.mu.RLock()
-	for _, event := range s.m {
-		if event.StartDate.After(startOfWeek.Add(-1*time.Second)) && event.StartDate.Before(endOfWeek.Add(24*time.Hour)) {
-			events = append(events, &event)
+	return s.commit(s.withAudit(change{Kind: putEvent, Event: &event}, audit)...)
+}
+
+func (s *Storage) PurgeDeletedEvents(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []change
+
+	for id, event := range s.m {
+		if event.DeletedAt.Valid && event.DeletedAt.Time.Before(before) {
+			changes = append(changes, change{Kind: removeEvent, ID: id})
 		}
 	}
-	s.mu.RUnlock()
 
-	return events, nil
+	return s.commit(changes...)
+}
+
+// ApplyBatch applies all operations under a single lock. In atomic mode the first failed
+// operation reverts the ones applied before it and the rest are reported as aborted. Audit
+// entries are recorded for the applied operations only.
+func (s *Storage) ApplyBatch(_ context.Context, ops []storage.Operation, atomic bool) ([]error, error) {
+	results := make([]error, len(ops))
+	undo := make([]func(), 0, len(ops))
+	changes := make([]change, 0, 2*len(ops))
+	audits := make([]*storage.AuditEntry, 0, len(ops))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revertAll := func() {
+		for j := len(undo) - 1; j >= 0; j-- {
+			undo[j]()
+		}
+	}
+
+	for i, op := range ops {
+		applied, revert, err := s.apply(op)
+		if err == nil {
+			undo = append(undo, revert)
+			changes = append(changes, applied)
+			audits = append(audits, op.Audit)
+			continue
+		}
+
+		if !atomic {
+			results[i] = err
+			continue
+		}
+
+		revertAll()
+
+		for j := range results {
+			results[j] = storage.ErrBatchAborted
+		}
+		results[i] = err
+
+		return results, storage.ErrBatchAborted
+	}
+
+	auditChanges := s.auditChanges(audits...)
+	changes = append(changes, auditChanges...)
+
+	// The batch is logged as a whole, so after a crash it is replayed completely or not at all.
+	if err := s.log(changes); err != nil {
+		revertAll()
+		return nil, err
+	}
+
+	for _, c := range auditChanges {
+		s.redo(c)
+	}
+
+	return results, nil
+}
+
+// apply must be called with the write lock held. It returns the applied change for the log.
+func (s *Storage) apply(op storage.Operation) (change, func(), error) {
+	id := op.Event.ID
+	prev, existed := s.m[id]
+
+	restore := func() {
+		if existed {
+			s.redo(change{Kind: putEvent, Event: &prev})
+			return
+		}
+		s.redo(change{Kind: removeEvent, ID: id})
+	}
+
+	event := op.Event
+
+	switch op.Type {
+	case storage.OpCreate:
+		if existed {
+			return change{}, nil, storage.ErrEventAlreadyExists
+		}
+		if !s.calendarExists(event.CalendarID) {
+			return change{}, nil, storage.ErrCalendarNotExists
+		}
+	case storage.OpUpdate:
+		if !existed || prev.DeletedAt.Valid {
+			return change{}, nil, storage.ErrEventNotExists
+		}
+		if !s.calendarExists(event.CalendarID) {
+			return change{}, nil, storage.ErrCalendarNotExists
+		}
+	case storage.OpDelete:
+		if !existed || prev.DeletedAt.Valid {
+			return change{}, nil, storage.ErrEventNotExists
+		}
+		event = prev
+		event.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	default:
+		return change{}, nil, storage.ErrUnknownOperation
+	}
+
+	applied := change{Kind: putEvent, Event: &event}
+	s.redo(applied)
+
+	return applied, restore, nil
+}
+
+func (s *Storage) ListEventsForDay(_ context.Context, date time.Time) ([]*storage.Event, error) {
+	return s.listStartingBetween(storage.DayRange(date)), nil
+}
+
+func (s *Storage) ListEventsForWeek(_ context.Context, date time.Time) ([]*storage.Event, error) {
+	return s.listStartingBetween(storage.WeekRange(date)), nil
 }
 
 func (s *Storage) ListEventsForMonth(_ context.Context, date time.Time) ([]*storage.Event, error) {
-	var events []*storage.Event
+	return s.listStartingBetween(storage.MonthRange(date)), nil
+}
 
-	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
-	endOfMonth := startOfMonth.AddDate(0, 1, -1)
+// listStartingBetween returns live events starting in [from, to) ordered by start date.
+func (s *Storage) listStartingBetween(from, to time.Time) []*storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.collect(&s.byStart, from, to)
+}
 
+// ListUserEvents returns live events of the user starting in [from, to) ordered by start date.
+func (s *Storage) ListUserEvents(_ context.Context, userID string, from, to time.Time) ([]*storage.Event, error) {
 	s.mu.RLock()
-	for _, event := range s.m {
-		if event.StartDate.After(startOfMonth.Add(-1*time.Second)) && event.StartDate.Before(endOfMonth.Add(24*time.Hour)) {
-			events = append(events, &event)
+	defer s.mu.RUnlock()
+
+	byUser, ok := s.byUser[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return s.collect(byUser, from, to), nil
+}
+
+// ListEventsNear returns live events with coordinates within radius meters of center starting
+// in [from, to), ordered by start date.
+func (s *Storage) ListEventsNear(
+	_ context.Context, center storage.Point, radius float64, from, to time.Time,
+) ([]*storage.Event, error) {
+	events := s.listStartingBetween(from, to)
+	near := events[:0]
+
+	for _, event := range events {
+		if point, ok := event.Point(); ok && storage.Distance(center, point) <= radius {
+			near = append(near, event)
 		}
 	}
-	s.mu.RUnlock()
 
-	return events, nil
+	return near, nil
 }
 
+// ListEventsForNotify returns live events starting notify_days after date, the days are compared in UTC.
 func (s *Storage) ListEventsForNotify(_ context.Context, date time.Time) ([]*storage.Event, error) {
-	var events []*storage.Event
+	from, to := storage.DayRange(date.UTC())
 
 	s.mu.RLock()
-	for _, event := range s.m {
-		if event.NotifyDays.Int32 == 0 {
+	events := s.collect(&s.byNotify, from, to)
+	s.mu.RUnlock()
+
+	sortByStart(events)
+
+	return events, nil
+}
+
+func (s *Storage) DeleteOldEvents(_ context.Context, date time.Time) error {
+	oldDate := storage.OldEventsThreshold(date)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []change
+
+	for id, event := range s.m {
+		if event.EndDate.Before(oldDate) {
+			changes = append(changes, change{Kind: removeEvent, ID: id})
+		}
+	}
+
+	return s.commit(changes...)
+}
+
+// sortByStart orders events by start date and then by ID like the SQL storage does.
+func sortByStart(events []*storage.Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].StartDate.Equal(events[j].StartDate) {
+			return events[i].StartDate.Before(events[j].StartDate)
+		}
+
+		return events[i].ID < events[j].ID
+	})
+}
+
+// withAudit returns the change followed by the change recording its audit entry, if any.
+// It must be called with the write lock held.
+func (s *Storage) withAudit(c change, audit *storage.AuditEntry) []change {
+	return append([]change{c}, s.auditChanges(audit)...)
+}
+
+// auditChanges turns audit entries into changes, skipping nil ones. Entries of one commit get
+// consecutive IDs. It must be called with the write lock held.
+func (s *Storage) auditChanges(entries ...*storage.AuditEntry) []change {
+	var changes []change
+
+	id := s.auditSeq
+
+	for _, entry := range entries {
+		if entry == nil {
 			continue
 		}
 
-		needNotifyDate := date.Add(time.Duration(event.NotifyDays.Int32) * 24 * time.Hour).Format("2006-01-02")
+		id++
 
-		if needNotifyDate == event.StartDate.Format("2006-01-02") {
-			events = append(events, &event)
+		audit := *entry
+		audit.ID = id
+
+		if audit.CreatedAt.IsZero() {
+			audit.CreatedAt = time.Now().UTC()
 		}
+
+		changes = append(changes, change{Kind: addAudit, Audit: &audit})
 	}
-	s.mu.RUnlock()
 
-	return events, nil
+	return changes
 }
 
-func (s *Storage) DeleteOldEvents(_ context.Context, date time.Time) error {
-	oldDate := date.Add(-(365 * 24 * time.Hour))
+func (s *Storage) ListAuditEntries(_ context.Context, eventID string) ([]*storage.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	s.mu.Lock()
+	entries := make([]*storage.AuditEntry, 0, len(s.audit[eventID]))
+	for _, entry := range s.audit[eventID] {
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// ListTags returns how many live events of every calendar have each tag starting with prefix,
+// ordered by tag.
+func (s *Storage) ListTags(_ context.Context, prefix string) ([]*storage.TagUsage, error) {
+	type key struct{ tag, calendarID string }
+
+	counts := make(map[key]int)
+
+	s.mu.RLock()
 	for _, event := range s.m {
-		if event.EndDate.Before(oldDate) {
-			delete(s.m, event.ID)
+		if event.DeletedAt.Valid {
+			continue
 		}
+
+		for _, tag := range event.Tags {
+			if strings.HasPrefix(tag, prefix) {
+				counts[key{tag: tag, calendarID: event.CalendarID.String}]++
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	usages := make([]*storage.TagUsage, 0, len(counts))
+	for k, count := range counts {
+		usages = append(usages, &storage.TagUsage{
+			Tag:        k.tag,
+			CalendarID: sql.NullString{String: k.calendarID, Valid: k.calendarID != ""},
+			Count:      count,
+		})
 	}
-	s.mu.Unlock()
 
-	return nil
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Tag != usages[j].Tag {
+			return usages[i].Tag < usages[j].Tag
+		}
+
+		return usages[i].CalendarID.String < usages[j].CalendarID.String
+	})
+
+	return usages, nil
 }
 
+// Ping reports the error of the last background snapshot or log sync of a persistent storage.
+func (s *Storage) Ping(_ context.Context) error {
+	if s.persistence == nil {
+		return nil
+	}
+
+	return s.persistence.lastErr()
+}
+
+// Close writes a final snapshot of a persistent storage and closes its log.
 func (s *Storage) Close(_ context.Context) error {
-	return nil
+	if s.persistence == nil {
+		return nil
+	}
+
+	return s.persistence.close(s)
 }
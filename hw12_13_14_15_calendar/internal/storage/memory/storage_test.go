@@ -6,10 +6,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
 	internalstorage "github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
 	"github.com/stretchr/testify/require"
 )
 
+func TestStorageContract(t *testing.T) {
+	storagetest.Run(t, func(_ *testing.T) app.Storage {
+		return New()
+	})
+}
+
 func TestStorage(t *testing.T) {
 	t.Run("basic", func(t *testing.T) {
 		ctx := context.Background()
@@ -17,31 +25,76 @@ func TestStorage(t *testing.T) {
 		storage := New()
 		event := internalstorage.Event{ID: "1"}
 
-		err := storage.CreateEvent(ctx, event)
+		err := storage.CreateEvent(ctx, event, nil)
 		require.NoError(t, err)
 
-		err = storage.CreateEvent(ctx, event)
+		err = storage.CreateEvent(ctx, event, nil)
 		require.Error(t, err)
 		require.ErrorIs(t, err, internalstorage.ErrEventAlreadyExists)
 
 		event.Title = "test"
-		err = storage.UpdateEvent(ctx, "1", event)
+		err = storage.UpdateEvent(ctx, "1", event, nil)
 		require.NoError(t, err)
 
 		event.Title = "test"
-		err = storage.UpdateEvent(ctx, "2", event)
+		err = storage.UpdateEvent(ctx, "2", event, nil)
 		require.Error(t, err)
 		require.ErrorIs(t, err, internalstorage.ErrEventNotExists)
 
 		event2 := internalstorage.Event{ID: "2"}
-		err = storage.CreateEvent(ctx, event2)
+		err = storage.CreateEvent(ctx, event2, nil)
 		require.NoError(t, err)
 
-		err = storage.DeleteEvent(ctx, event)
+		err = storage.DeleteEvent(ctx, event, nil)
 		require.NoError(t, err)
 
-		err = storage.DeleteEvent(ctx, event)
+		err = storage.DeleteEvent(ctx, event, nil)
+		require.ErrorIs(t, err, internalstorage.ErrEventNotExists)
+
+		err = storage.UpdateEvent(ctx, "1", event, nil)
+		require.ErrorIs(t, err, internalstorage.ErrEventNotExists)
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		ctx := context.Background()
+		date := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		storage := New()
+		require.NoError(t, storage.CreateEvent(ctx, internalstorage.Event{ID: "1", StartDate: date}, nil))
+		require.NoError(t, storage.CreateEvent(ctx, internalstorage.Event{ID: "2", StartDate: date}, nil))
+
+		require.NoError(t, storage.DeleteEvent(ctx, internalstorage.Event{ID: "1"}, nil))
+
+		got, err := storage.ListEventsForDay(ctx, date)
 		require.NoError(t, err)
+		require.Len(t, got, 1)
+		require.Equal(t, "2", got[0].ID)
+
+		trash, err := storage.ListDeletedEvents(ctx)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		require.Equal(t, "1", trash[0].ID)
+		require.True(t, trash[0].DeletedAt.Valid)
+
+		err = storage.CreateEvent(ctx, internalstorage.Event{ID: "1"}, nil)
+		require.ErrorIs(t, err, internalstorage.ErrEventAlreadyExists)
+
+		require.NoError(t, storage.RestoreEvent(ctx, "1", nil))
+		require.ErrorIs(t, storage.RestoreEvent(ctx, "1", nil), internalstorage.ErrEventNotExists)
+		require.ErrorIs(t, storage.RestoreEvent(ctx, "3", nil), internalstorage.ErrEventNotExists)
+
+		got, err = storage.ListEventsForDay(ctx, date)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+
+		require.NoError(t, storage.DeleteEvent(ctx, internalstorage.Event{ID: "2"}, nil))
+
+		require.NoError(t, storage.PurgeDeletedEvents(ctx, time.Now().Add(-time.Hour)))
+		require.Len(t, storage.m, 2)
+
+		require.NoError(t, storage.PurgeDeletedEvents(ctx, time.Now().Add(time.Second)))
+		require.Len(t, storage.m, 1)
+		require.Contains(t, storage.m, "1")
 	})
 
 	t.Run("list events", func(t *testing.T) {
@@ -75,7 +128,7 @@ func TestStorage(t *testing.T) {
 		}
 
 		for _, event := range events {
-			err := storage.CreateEvent(ctx, event)
+			err := storage.CreateEvent(ctx, event, nil)
 			require.NoError(t, err)
 		}
 
@@ -95,4 +148,132 @@ func TestStorage(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, got, 2)
 	})
+	t.Run("atomic batch", func(t *testing.T) {
+		ctx := context.Background()
+
+		storage := New()
+		require.NoError(t, storage.CreateEvent(ctx, internalstorage.Event{ID: "1", Title: "old"}, nil))
+
+		results, err := storage.ApplyBatch(ctx, []internalstorage.Operation{
+			{Type: internalstorage.OpUpdate, Event: internalstorage.Event{ID: "1", Title: "new"}},
+			{Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "2"}},
+			{Type: internalstorage.OpUpdate, Event: internalstorage.Event{ID: "3"}},
+		}, true)
+		require.ErrorIs(t, err, internalstorage.ErrBatchAborted)
+		require.ErrorIs(t, results[0], internalstorage.ErrBatchAborted)
+		require.ErrorIs(t, results[1], internalstorage.ErrBatchAborted)
+		require.ErrorIs(t, results[2], internalstorage.ErrEventNotExists)
+
+		require.Len(t, storage.m, 1)
+		require.Equal(t, "old", storage.m["1"].Title)
+	})
+
+	t.Run("best-effort batch", func(t *testing.T) {
+		ctx := context.Background()
+
+		storage := New()
+		require.NoError(t, storage.CreateEvent(ctx, internalstorage.Event{ID: "1"}, nil))
+
+		results, err := storage.ApplyBatch(ctx, []internalstorage.Operation{
+			{Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "1"}},
+			{Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "2"}},
+			{Type: internalstorage.OpDelete, Event: internalstorage.Event{ID: "1"}},
+			{Type: "move", Event: internalstorage.Event{ID: "2"}},
+		}, false)
+		require.NoError(t, err)
+		require.ErrorIs(t, results[0], internalstorage.ErrEventAlreadyExists)
+		require.NoError(t, results[1])
+		require.NoError(t, results[2])
+		require.ErrorIs(t, results[3], internalstorage.ErrUnknownOperation)
+
+		require.True(t, storage.m["1"].DeletedAt.Valid)
+		require.Contains(t, storage.m, "2")
+	})
+	t.Run("audit", func(t *testing.T) {
+		ctx := context.Background()
+
+		storage := New()
+		require.NoError(t, storage.CreateEvent(ctx, internalstorage.Event{ID: "1"},
+			&internalstorage.AuditEntry{EventID: "1", Action: "create"}))
+
+		// Entries of one batch get IDs of their own.
+		_, err := storage.ApplyBatch(ctx, []internalstorage.Operation{
+			{
+				Type: internalstorage.OpCreate, Event: internalstorage.Event{ID: "2"},
+				Audit: &internalstorage.AuditEntry{EventID: "2", Action: "create"},
+			},
+			{
+				Type: internalstorage.OpUpdate, Event: internalstorage.Event{ID: "1"},
+				Audit: &internalstorage.AuditEntry{EventID: "1", Action: "update"},
+			},
+		}, true)
+		require.NoError(t, err)
+
+		entries, err := storage.ListAuditEntries(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, "create", entries[0].Action)
+		require.Equal(t, "update", entries[1].Action)
+		require.Equal(t, []int64{1, 3}, []int64{entries[0].ID, entries[1].ID})
+		require.False(t, entries[1].CreatedAt.IsZero())
+
+		entries, err = storage.ListAuditEntries(ctx, "3")
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+	t.Run("calendars", func(t *testing.T) {
+		ctx := context.Background()
+
+		storage := New()
+		calendar := internalstorage.Calendar{ID: "c1", OwnerID: "alice", Name: "work", TimeZone: "UTC"}
+		require.NoError(t, storage.CreateCalendar(ctx, calendar))
+		require.ErrorIs(t, storage.CreateCalendar(ctx, calendar), internalstorage.ErrCalendarExists)
+		err := storage.CreateCalendar(ctx, internalstorage.Calendar{ID: "c2", OwnerID: "bob", Name: "home"})
+		require.NoError(t, err)
+
+		share := internalstorage.Share{CalendarID: "c2", UserID: "alice", Permission: "read"}
+		require.NoError(t, storage.SetShare(ctx, share))
+
+		share.Permission = "write"
+		require.NoError(t, storage.SetShare(ctx, share))
+		require.ErrorIs(t, storage.SetShare(ctx, internalstorage.Share{CalendarID: "c3", UserID: "alice"}),
+			internalstorage.ErrCalendarNotExists)
+
+		shares, err := storage.ListShares(ctx, "c2")
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+		require.Equal(t, "write", shares[0].Permission)
+
+		calendars, err := storage.ListCalendars(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, calendars, 2)
+		require.Equal(t, "home", calendars[0].Name)
+
+		calendars, err = storage.ListCalendars(ctx, "bob")
+		require.NoError(t, err)
+		require.Len(t, calendars, 1)
+
+		calendar.OwnerID = "bob"
+		calendar.Name = "office"
+		require.NoError(t, storage.UpdateCalendar(ctx, calendar))
+
+		stored, err := storage.GetCalendar(ctx, "c1")
+		require.NoError(t, err)
+		require.Equal(t, "office", stored.Name)
+		require.Equal(t, "alice", stored.OwnerID)
+
+		event := internalstorage.Event{ID: "1", CalendarID: sql.NullString{String: "c1", Valid: true}}
+		require.NoError(t, storage.CreateEvent(ctx, event, nil))
+		require.ErrorIs(t, storage.DeleteCalendar(ctx, "c1"), internalstorage.ErrCalendarNotEmpty)
+
+		require.NoError(t, storage.DeleteEvent(ctx, event, nil))
+		require.NoError(t, storage.DeleteCalendar(ctx, "c1"))
+		require.False(t, storage.m["1"].CalendarID.Valid)
+
+		_, err = storage.GetCalendar(ctx, "c1")
+		require.ErrorIs(t, err, internalstorage.ErrCalendarNotExists)
+
+		require.NoError(t, storage.DeleteShare(ctx, "c2", "alice"))
+		require.ErrorIs(t, storage.DeleteShare(ctx, "c2", "alice"), internalstorage.ErrShareNotExists)
+	})
 }
@@ -0,0 +1,125 @@
+package memorystorage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrCorruptedLog = errors.New("write-ahead log is corrupted")
+
+// entry is a line of the log. Its changes are applied together, which keeps batches atomic.
+type entry struct {
+	Seq     uint64   `json:"seq"`
+	Changes []change `json:"changes"`
+}
+
+// wal is an append-only file of JSON entries, one per line. Entry numbers keep growing after the
+// file is reset, a snapshot remembers the last one it contains.
+type wal struct {
+	file     *os.File
+	size     int64
+	seq      uint64
+	syncEach bool
+}
+
+// openWAL opens the log and returns the entries it holds. A line without the trailing newline
+// is a write torn by a crash, it was never acknowledged and is cut off.
+func openWAL(path string, syncEach bool) (*wal, []entry, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, size, err := readEntries(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	w := &wal{file: file, size: size, syncEach: syncEach}
+	if len(entries) > 0 {
+		w.seq = entries[len(entries)-1].Seq
+	}
+
+	return w, entries, nil
+}
+
+func readEntries(r io.Reader) ([]entry, int64, error) {
+	var (
+		entries []entry
+		size    int64
+	)
+
+	reader := bufio.NewReader(r)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return entries, size, nil
+		}
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var e entry
+		if err = json.Unmarshal(line, &e); err != nil {
+			return nil, 0, fmt.Errorf("%w: offset %d: %w", ErrCorruptedLog, size, err)
+		}
+
+		entries = append(entries, e)
+		size += int64(len(line))
+	}
+}
+
+func (w *wal) append(changes []change) error {
+	line, err := json.Marshal(entry{Seq: w.seq + 1, Changes: changes})
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	_, err = w.file.Write(line)
+	if err == nil && w.syncEach {
+		err = w.file.Sync()
+	}
+
+	if err != nil {
+		// The change is not applied, so the entry must not be replayed either.
+		_ = w.file.Truncate(w.size)
+		return err
+	}
+
+	w.seq++
+	w.size += int64(len(line))
+
+	return nil
+}
+
+// reset drops the entries once a snapshot contains them.
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+
+	w.size = 0
+
+	return w.file.Sync()
+}
+
+func (w *wal) sync() error {
+	return w.file.Sync()
+}
+
+func (w *wal) close() error {
+	return errors.Join(w.file.Sync(), w.file.Close())
+}
@@ -0,0 +1,30 @@
+package storage
+
+import "time"
+
+// DayRange returns the half-open interval [from, to) of the day of date in its location.
+// Storages list events whose start date falls into the interval.
+func DayRange(date time.Time) (from, to time.Time) {
+	from = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	return from, from.AddDate(0, 0, 1)
+}
+
+// WeekRange returns seven days starting with the day of date, not a calendar week.
+func WeekRange(date time.Time) (from, to time.Time) {
+	from, _ = DayRange(date)
+
+	return from, from.AddDate(0, 0, 7)
+}
+
+// MonthRange returns the calendar month containing date.
+func MonthRange(date time.Time) (from, to time.Time) {
+	from = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+
+	return from, from.AddDate(0, 1, 0)
+}
+
+// OldEventsThreshold returns the moment before which finished events are considered old.
+func OldEventsThreshold(now time.Time) time.Time {
+	return now.AddDate(-1, 0, 0)
+}
@@ -0,0 +1,20 @@
+package storage
+
+// Preferences tell the scheduler how a user wants to be notified. QuietStart, QuietEnd and
+// DigestTime are "15:04" times in TimeZone, the quiet hours are empty when the user has none.
+type Preferences struct {
+	UserID     string `db:"user_id"`
+	Channel    string `db:"channel"`
+	Address    string `db:"address"`
+	QuietStart string `db:"quiet_start"`
+	QuietEnd   string `db:"quiet_end"`
+	TimeZone   string `db:"time_zone"`
+	Delivery   string `db:"delivery"`
+	DigestTime string `db:"digest_time"`
+}
+
+// OptOut turns off the notifications about an event for a user, it is removed with the event.
+type OptOut struct {
+	EventID string `db:"event_uuid"`
+	UserID  string `db:"user_id"`
+}
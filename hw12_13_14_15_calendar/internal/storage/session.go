@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type sessionKey struct{}
+
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession returns a copy of ctx scoping a single request. Storages with read replicas send
+// the reads of a request that has already written to the primary, so it reads its own writes.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// MarkWritten records a write in the session of ctx, if any.
+func MarkWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
+
+// WrittenInSession reports whether the request of ctx has written anything.
+func WrittenInSession(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+
+	return ok && s.wrote.Load()
+}
@@ -0,0 +1,178 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// preparedQueries are the hot reads, they are prepared once in Connect instead of on every call.
+var preparedQueries = []string{
+	getEventQuery,
+	listStartingBetweenQuery,
+	listForNotifyQuery,
+	listNearQuery,
+	listAuditEntriesQuery,
+	listTagsQuery,
+	getCalendarQuery,
+	listCalendarsQuery,
+	listCalendarEventsQuery,
+	listSharesQuery,
+}
+
+func (s *Storage) prepare(ctx context.Context) error {
+	s.stmts = make(map[string]*sqlx.Stmt, len(preparedQueries))
+
+	for _, query := range preparedQueries {
+		stmt, err := s.db.PreparexContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		s.stmts[query] = stmt
+	}
+
+	return nil
+}
+
+func (s *Storage) closeStatements() error {
+	var errs []error
+
+	for _, stmt := range s.stmts {
+		errs = append(errs, stmt.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// withTimeout bounds a single operation, zero timeout leaves the deadline to the caller.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (s *Storage) getContext(ctx context.Context, dest any, query string, args ...any) error {
+	return s.read(ctx, dest, s.readRetries, func(ctx context.Context) error {
+		if stmt, ok := s.stmts[query]; ok {
+			return stmt.GetContext(ctx, dest, args...)
+		}
+
+		return s.db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+func (s *Storage) selectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return s.read(ctx, dest, s.readRetries, func(ctx context.Context) error {
+		if stmt, ok := s.stmts[query]; ok {
+			return stmt.SelectContext(ctx, dest, args...)
+		}
+
+		return s.db.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+// read runs an idempotent query within the read timeout and retries it with exponential backoff
+// after transient connection errors. dest is reset before every attempt because sqlx appends
+// to slices.
+func (s *Storage) read(ctx context.Context, dest any, retries int, query func(ctx context.Context) error) error {
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	backoff := s.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		target := reflect.ValueOf(dest).Elem()
+		target.Set(reflect.Zero(target.Type()))
+
+		err := query(ctx)
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// exec and namedExec run writes within the write timeout on the primary.
+func (s *Storage) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	storage.MarkWritten(ctx)
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *Storage) namedExec(ctx context.Context, query string, arg any) (sql.Result, error) {
+	storage.MarkWritten(ctx)
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	return sqlx.NamedExecContext(ctx, s.db, query, arg)
+}
+
+// inWriteTx runs the writes of fn in a transaction on the primary within the write timeout.
+func (s *Storage) inWriteTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	storage.MarkWritten(ctx)
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isTransient reports errors after which the same query may succeed on another connection:
+// broken connections and the server shutting down. Timeouts and cancellations are final.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exception, 57P01-57P03 are shutdown and "cannot connect now".
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err)
+}
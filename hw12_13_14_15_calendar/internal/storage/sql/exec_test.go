@@ -0,0 +1,103 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "unexpected eof", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, want: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, want: true},
+		{name: "database dropped", err: &pgconn.PgError{Code: "57P04"}, want: false},
+		{name: "unique violation", err: &pgconn.PgError{Code: uniqueViolation}, want: false},
+		{name: "no rows", err: sql.ErrNoRows, want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: false},
+		{name: "canceled", err: fmt.Errorf("query: %w", context.Canceled), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	s := &Storage{readRetries: 2, retryBackoff: time.Millisecond}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		var (
+			calls int
+			dest  []int
+		)
+
+		err := s.read(context.Background(), &dest, s.readRetries, func(_ context.Context) error {
+			calls++
+			dest = append(dest, calls)
+
+			if calls < 3 {
+				return driver.ErrBadConn
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+		require.Equal(t, []int{3}, dest, "partial results of failed attempts are dropped")
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		var (
+			calls int
+			dest  []int
+		)
+
+		err := s.read(context.Background(), &dest, s.readRetries, func(_ context.Context) error {
+			calls++
+			return driver.ErrBadConn
+		})
+		require.ErrorIs(t, err, driver.ErrBadConn)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		var (
+			calls int
+			dest  int
+		)
+
+		err := s.read(context.Background(), &dest, s.readRetries, func(_ context.Context) error {
+			calls++
+			return sql.ErrNoRows
+		})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("read timeout", func(t *testing.T) {
+		s := &Storage{readTimeout: 10 * time.Millisecond}
+
+		var dest int
+
+		err := s.read(context.Background(), &dest, s.readRetries, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
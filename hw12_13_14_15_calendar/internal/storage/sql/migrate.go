@@ -0,0 +1,276 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/migrations"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrSchemaOutdated     = errors.New("database schema is outdated")
+	ErrNothingToRollback  = errors.New("no applied migrations")
+	ErrMigrationMalformed = errors.New("malformed migration")
+)
+
+// migrationLockID serializes migrations of several instances starting at once.
+const migrationLockID = 4_815_162_342
+
+// Migration is an embedded goose migration. Applied versions are tracked in the
+// goose_db_version table, so databases migrated by the goose tool keep working.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func loadMigrations() ([]Migration, error) {
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Migration, 0, len(files))
+
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(file, "_")
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrMigrationMalformed, file, err)
+		}
+
+		content, err := fs.ReadFile(migrations.FS, file)
+		if err != nil {
+			return nil, err
+		}
+
+		up, down, found := strings.Cut(string(content), "-- +goose Down")
+		if !found || !strings.Contains(up, "-- +goose Up") {
+			return nil, fmt.Errorf("%w: %s: no up and down sections", ErrMigrationMalformed, file)
+		}
+
+		result = append(result, Migration{Version: version, Name: path.Base(file), up: up, down: down})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// LatestVersion is the schema version the binary expects.
+func LatestVersion() (int64, error) {
+	all, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(all) == 0 {
+		return 0, nil
+	}
+
+	return all[len(all)-1].Version, nil
+}
+
+// MigrateUp applies the pending migrations, each in its own transaction, and returns them.
+func (s *Storage) MigrateUp(ctx context.Context) ([]Migration, error) {
+	all, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+
+	for _, migration := range all {
+		done, err := s.inMigrationTx(ctx, func(tx *sqlx.Tx, current int64) (bool, error) {
+			if migration.Version <= current {
+				return false, nil
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.up); err != nil {
+				return false, err
+			}
+
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, TRUE)`, migration.Version)
+
+			return err == nil, err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+
+		if done {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// MigrateDown rolls back the last applied migration and returns it.
+func (s *Storage) MigrateDown(ctx context.Context) (Migration, error) {
+	all, err := loadMigrations()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	if err = s.ensureVersionTable(ctx); err != nil {
+		return Migration{}, err
+	}
+
+	var reverted Migration
+
+	_, err = s.inMigrationTx(ctx, func(tx *sqlx.Tx, current int64) (bool, error) {
+		if current == 0 {
+			return false, ErrNothingToRollback
+		}
+
+		i := sort.Search(len(all), func(i int) bool { return all[i].Version >= current })
+		if i == len(all) || all[i].Version != current {
+			return false, fmt.Errorf("%w: version %d is not known to this binary", ErrMigrationMalformed, current)
+		}
+
+		reverted = all[i]
+
+		if _, err := tx.ExecContext(ctx, reverted.down); err != nil {
+			return false, fmt.Errorf("migration %s: %w", reverted.Name, err)
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM goose_db_version WHERE version_id = $1`, current)
+
+		return err == nil, err
+	})
+
+	return reverted, err
+}
+
+// MigrationStatus lists the embedded migrations with the time they were applied.
+func (s *Storage) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	all, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int64     `db:"version_id"`
+		AppliedAt time.Time `db:"tstamp"`
+	}
+
+	err = s.db.SelectContext(ctx, &rows,
+		`SELECT version_id, MAX(tstamp) AS tstamp FROM goose_db_version WHERE is_applied GROUP BY version_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	result := make([]MigrationStatus, 0, len(all))
+
+	for _, migration := range all {
+		at, applied := appliedAt[migration.Version]
+		result = append(result, MigrationStatus{Migration: migration, Applied: applied, AppliedAt: at})
+	}
+
+	return result, nil
+}
+
+// SchemaVersion returns the last applied migration, zero for an empty database.
+func (s *Storage) SchemaVersion(ctx context.Context) (int64, error) {
+	if err := s.ensureVersionTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version int64
+
+	err := s.db.GetContext(ctx, &version, `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version`)
+
+	return version, err
+}
+
+// checkSchema refuses a database older than the binary, a newer one is accepted so that an
+// older binary keeps running while a rollout migrates the schema.
+func (s *Storage) checkSchema(ctx context.Context) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("%w: version %d, expected %d, run `calendar migrate up`", ErrSchemaOutdated, current, latest)
+	}
+
+	return nil
+}
+
+// ensureVersionTable creates the table the way goose does, with the initial zero version.
+func (s *Storage) ensureVersionTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+				id SERIAL PRIMARY KEY,
+				version_id BIGINT NOT NULL,
+				is_applied BOOLEAN NOT NULL,
+				tstamp TIMESTAMP DEFAULT now()
+			);
+			INSERT INTO goose_db_version (version_id, is_applied)
+				SELECT 0, TRUE WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)`)
+
+	return err
+}
+
+// inMigrationTx runs fn in a transaction holding the migration lock, fn gets the schema version
+// read under the lock and reports whether it changed anything.
+func (s *Storage) inMigrationTx(ctx context.Context, fn func(tx *sqlx.Tx, current int64) (bool, error)) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, err
+	}
+
+	var current int64
+
+	if err = tx.GetContext(ctx, &current, `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version`); err != nil {
+		return false, err
+	}
+
+	changed, err := fn(tx, current)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
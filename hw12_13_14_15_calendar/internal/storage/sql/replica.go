@@ -0,0 +1,168 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+const defaultReplicaCheckInterval = 5 * time.Second
+
+// replica is a read-only copy of the primary. It is taken out of rotation when a ping or a
+// query fails with a connection error and put back by the next successful health check.
+type replica struct {
+	db      *sqlx.DB
+	stmts   atomic.Pointer[map[string]*sqlx.Stmt]
+	healthy atomic.Bool
+}
+
+// routedQueries are the list queries served by replicas.
+var routedQueries = []string{
+	listStartingBetweenQuery,
+	listForNotifyQuery,
+	listNearQuery,
+	listTagsQuery,
+}
+
+// startReplicaChecks checks the replicas right away, so healthy ones serve reads from the start,
+// and then periodically until Close.
+func (s *Storage) startReplicaChecks(ctx context.Context) {
+	if len(s.replicas) == 0 {
+		return
+	}
+
+	s.checkReplicas(ctx)
+
+	interval := s.replicaCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	s.stopChecks = make(chan struct{})
+	s.checks.Add(1)
+
+	go func() {
+		defer s.checks.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopChecks:
+				return
+			case <-ticker.C:
+				s.checkReplicas(context.Background())
+			}
+		}
+	}()
+}
+
+func (s *Storage) checkReplicas(ctx context.Context) {
+	for _, r := range s.replicas {
+		checkCtx, cancel := withTimeout(ctx, s.readTimeout)
+		err := r.db.PingContext(checkCtx)
+
+		if err == nil && r.stmts.Load() == nil {
+			err = r.prepare(checkCtx)
+		}
+
+		cancel()
+
+		r.healthy.Store(err == nil)
+	}
+}
+
+func (r *replica) prepare(ctx context.Context) error {
+	stmts := make(map[string]*sqlx.Stmt, len(routedQueries))
+
+	for _, query := range routedQueries {
+		stmt, err := r.db.PreparexContext(ctx, query)
+		if err != nil {
+			for _, prepared := range stmts {
+				_ = prepared.Close()
+			}
+
+			return err
+		}
+
+		stmts[query] = stmt
+	}
+
+	r.stmts.Store(&stmts)
+
+	return nil
+}
+
+// pickReplica returns the next healthy replica round-robin, or nil when the primary has to serve
+// the read: no replica is healthy or the request has written and must see its writes.
+func (s *Storage) pickReplica(ctx context.Context) *replica {
+	if len(s.replicas) == 0 || storage.WrittenInSession(ctx) {
+		return nil
+	}
+
+	s.replicaMu.Lock()
+	start := s.nextReplica
+	s.nextReplica = (s.nextReplica + 1) % len(s.replicas)
+	s.replicaMu.Unlock()
+
+	for i := range s.replicas {
+		r := s.replicas[(start+i)%len(s.replicas)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// selectRouted runs a list query on a replica and falls back to the primary if the replica fails
+// with a connection error. The replica gets a single attempt, the retries are left to the primary.
+func (s *Storage) selectRouted(ctx context.Context, dest any, query string, args ...any) error {
+	r := s.pickReplica(ctx)
+	if r == nil {
+		return s.selectContext(ctx, dest, query, args...)
+	}
+
+	err := s.read(ctx, dest, 0, func(ctx context.Context) error {
+		if stmts := r.stmts.Load(); stmts != nil {
+			if stmt, ok := (*stmts)[query]; ok {
+				return stmt.SelectContext(ctx, dest, args...)
+			}
+		}
+
+		return r.db.SelectContext(ctx, dest, query, args...)
+	})
+	if err == nil || !isTransient(err) {
+		return err
+	}
+
+	r.healthy.Store(false)
+
+	return s.selectContext(ctx, dest, query, args...)
+}
+
+func (s *Storage) closeReplicas() error {
+	if s.stopChecks != nil {
+		close(s.stopChecks)
+		s.checks.Wait()
+	}
+
+	var errs []error
+
+	for _, r := range s.replicas {
+		if stmts := r.stmts.Load(); stmts != nil {
+			for _, stmt := range *stmts {
+				errs = append(errs, stmt.Close())
+			}
+		}
+
+		errs = append(errs, r.db.Close())
+	}
+
+	return errors.Join(errs...)
+}
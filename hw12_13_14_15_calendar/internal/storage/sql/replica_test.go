@@ -0,0 +1,58 @@
+package sqlstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickReplica(t *testing.T) {
+	newStorage := func(healthy ...bool) *Storage {
+		s := &Storage{}
+
+		for _, h := range healthy {
+			r := &replica{}
+			r.healthy.Store(h)
+			s.replicas = append(s.replicas, r)
+		}
+
+		return s
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		s := newStorage(true, true, true)
+		ctx := context.Background()
+
+		picked := []*replica{s.pickReplica(ctx), s.pickReplica(ctx), s.pickReplica(ctx), s.pickReplica(ctx)}
+		require.Equal(t, []*replica{s.replicas[0], s.replicas[1], s.replicas[2], s.replicas[0]}, picked)
+	})
+
+	t.Run("skips unhealthy", func(t *testing.T) {
+		s := newStorage(false, true, false)
+		ctx := context.Background()
+
+		for range 3 {
+			require.Same(t, s.replicas[1], s.pickReplica(ctx))
+		}
+	})
+
+	t.Run("primary when replicas are down", func(t *testing.T) {
+		require.Nil(t, newStorage(false, false).pickReplica(context.Background()))
+		require.Nil(t, newStorage().pickReplica(context.Background()))
+	})
+
+	t.Run("primary after a write in the request", func(t *testing.T) {
+		s := newStorage(true)
+		ctx := storage.WithSession(context.Background())
+
+		require.NotNil(t, s.pickReplica(ctx))
+
+		storage.MarkWritten(ctx)
+		require.Nil(t, s.pickReplica(ctx))
+
+		// Other requests keep reading from replicas.
+		require.NotNil(t, s.pickReplica(storage.WithSession(context.Background())))
+	})
+}
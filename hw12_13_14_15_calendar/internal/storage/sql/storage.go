@@ -2,25 +2,68 @@ package sqlstorage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
 	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib" // for PostgreSQL driver
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sql")
+
 type Storage struct {
-	db *sqlx.DB
+	db          *sqlx.DB
+	autoMigrate bool
+	stmts       map[string]*sqlx.Stmt
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	readRetries  int
+	retryBackoff time.Duration
+
+	replicas             []*replica
+	replicaCheckInterval time.Duration
+	replicaMu            sync.Mutex
+	nextReplica          int
+	stopChecks           chan struct{}
+	checks               sync.WaitGroup
 }
 
 func New(cfg config.DBConf) *Storage {
+	s := &Storage{
+		db:                   open(cfg, cfg.Host, cfg.Port),
+		autoMigrate:          cfg.AutoMigrate,
+		readTimeout:          cfg.ReadTimeout,
+		writeTimeout:         cfg.WriteTimeout,
+		readRetries:          cfg.ReadRetries,
+		retryBackoff:         cfg.RetryBackoff,
+		replicaCheckInterval: cfg.ReplicaCheckInterval,
+	}
+
+	for _, r := range cfg.Replicas {
+		s.replicas = append(s.replicas, &replica{db: open(cfg, r.Host, r.Port)})
+	}
+
+	return s
+}
+
+// open creates a pool for the host, replicas share the credentials and limits with the primary.
+func open(cfg config.DBConf, host, port string) *sqlx.DB {
 	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable",
 		cfg.User,
 		cfg.Pass,
-		cfg.Host,
-		cfg.Port,
+		host,
+		port,
 		cfg.DBName,
 	)
 
@@ -29,54 +72,218 @@ func New(cfg config.DBConf) *Storage {
 		panic(fmt.Sprintf("database init error: %v", err))
 	}
 
-	return &Storage{
-		db: db,
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
 	}
+
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db
 }
 
+// Connect checks the connection and the schema version, migrating the schema first if configured,
+// prepares the hot queries and starts the health checks of the replicas. Replicas being down
+// does not fail Connect, the primary serves their reads.
 func (s *Storage) Connect(ctx context.Context) error {
 	err := s.db.PingContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect to db: %w", err)
 	}
 
+	if s.autoMigrate {
+		if _, err = s.MigrateUp(ctx); err != nil {
+			return fmt.Errorf("failed to migrate db: %w", err)
+		}
+	}
+
+	if err = s.checkSchema(ctx); err != nil {
+		return err
+	}
+
+	if err = s.prepare(ctx); err != nil {
+		return fmt.Errorf("failed to prepare statements: %w", err)
+	}
+
+	s.startReplicaChecks(ctx)
+
+	return nil
+}
+
+// Open connects to the database without the schema check, it is used to run the migrations.
+func (s *Storage) Open(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect to db: %w", err)
+	}
+
 	return nil
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *Storage) Close(_ context.Context) error {
-	return s.db.Close()
+	return errors.Join(s.closeReplicas(), s.closeStatements(), s.db.Close())
 }
 
-func (s *Storage) CreateEvent(ctx context.Context, event storage.Event) error {
-	query := `INSERT INTO events (uuid, title, start_date, end_date, description, user_id, notify_days)
-    			VALUES (:uuid, :title, :start_date, :end_date, :description, :user_id, :notify_days)`
+const (
+	createEventQuery = `INSERT INTO events (uuid, title, start_date, end_date, description, user_id, notify_days,
+				calendar_uuid, category, color, address, latitude, longitude, meeting_url)
+    			VALUES (:uuid, :title, :start_date, :end_date, :description, :user_id, :notify_days, :calendar_uuid,
+    			:category, :color, :address, :latitude, :longitude, :meeting_url)`
+
+	updateEventQuery = `UPDATE events SET title=:title, start_date=:start_date, end_date=:end_date, description=:description, 
+                  user_id=:user_id, notify_days=:notify_days, calendar_uuid=:calendar_uuid, category=:category,
+                  color=:color, address=:address, latitude=:latitude, longitude=:longitude, meeting_url=:meeting_url
+                  WHERE uuid = :uuid AND deleted_at IS NULL`
+
+	// Timestamps are stored as UTC wall clock, pgx discards the location of time.Time parameters.
+	deleteEventQuery = `UPDATE events SET deleted_at = now() AT TIME ZONE 'UTC' WHERE uuid = $1 AND deleted_at IS NULL`
+
+	// Tags are aggregated into one column, so events are read with a single query.
+	eventColumns = `uuid, title, start_date, end_date, description, user_id, notify_days, calendar_uuid, category,
+				color, address, latitude, longitude, meeting_url, deleted_at,
+				(SELECT string_agg(tag, ',' ORDER BY tag) FROM event_tags
+					WHERE event_tags.event_uuid = events.uuid) AS tags`
+
+	deleteEventTagsQuery = `DELETE FROM event_tags WHERE event_uuid = $1`
+
+	insertEventTagQuery = `INSERT INTO event_tags (event_uuid, tag) VALUES ($1, $2)`
+
+	getEventQuery = `SELECT ` + eventColumns + ` FROM events WHERE uuid = $1`
+
+	listStartingBetweenQuery = `SELECT ` + eventColumns + ` FROM events
+				WHERE start_date >= $1 AND start_date < $2 AND deleted_at IS NULL ORDER BY start_date, uuid`
+
+	listForNotifyQuery = `SELECT ` + eventColumns + ` FROM events
+				WHERE notify_days > 0 AND deleted_at IS NULL
+				AND start_date::DATE = ($1::TIMESTAMP + INTERVAL '1 day' * notify_days)::DATE
+				ORDER BY start_date, uuid`
+
+	// The bounding box in $3-$6 narrows the rows down before the haversine distance to the point
+	// in $7, $8 is compared with the radius in $9. 6371008.8 is storage.EarthRadius.
+	listNearQuery = `SELECT ` + eventColumns + ` FROM events
+				WHERE start_date >= $1 AND start_date < $2 AND deleted_at IS NULL
+				AND latitude BETWEEN $3 AND $4 AND longitude BETWEEN $5 AND $6
+				AND 2 * 6371008.8 * asin(least(1, sqrt(power(sin(radians(latitude - $7) / 2), 2)
+					+ cos(radians($7)) * cos(radians(latitude)) * power(sin(radians(longitude - $8) / 2), 2)))) <= $9
+				ORDER BY start_date, uuid`
+
+	addAuditEntryQuery = `INSERT INTO event_audit (event_uuid, actor, action, snapshot_before, snapshot_after)
+				VALUES (:event_uuid, :actor, :action, :snapshot_before, :snapshot_after)`
+
+	listAuditEntriesQuery = `SELECT id, event_uuid, actor, action, snapshot_before, snapshot_after, created_at
+				FROM event_audit WHERE event_uuid = $1 ORDER BY id`
+
+	listTagsQuery = `SELECT t.tag, e.calendar_uuid, count(*) AS count
+				FROM event_tags t JOIN events e ON e.uuid = t.event_uuid
+				WHERE t.tag LIKE $1 ESCAPE '\' AND e.deleted_at IS NULL
+				GROUP BY t.tag, e.calendar_uuid ORDER BY t.tag, e.calendar_uuid NULLS FIRST`
+
+	// PostgreSQL error codes mapped to storage errors.
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// GetEvent returns the event even if it is in the trash, callers check DeletedAt.
+func (s *Storage) GetEvent(ctx context.Context, id string) (_ *storage.Event, err error) {
+	ctx, span := startSpan(ctx, "GetEvent", getEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var event storage.Event
+
+	err = s.getContext(ctx, &event, getEventQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrEventNotExists
+	}
 
-	_, err := s.db.NamedExecContext(ctx, query, event)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &event, nil
 }
 
-func (s *Storage) UpdateEvent(ctx context.Context, id string, event storage.Event) error {
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) (err error) {
+	ctx, span := startSpan(ctx, "CreateEvent", createEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inWriteTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpCreate, Event: event, Audit: audit})
+	})
+}
+
+func (s *Storage) UpdateEvent(
+	ctx context.Context, id string, event storage.Event, audit *storage.AuditEntry,
+) (err error) {
 	event.ID = id
 
-	query := `UPDATE events SET title=:title, start_date=:start_date, end_date=:end_date, description=:description, 
-                  user_id=:user_id, notify_days=:notify_days WHERE uuid = :uuid`
+	ctx, span := startSpan(ctx, "UpdateEvent", updateEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inWriteTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpUpdate, Event: event, Audit: audit})
+	})
+}
 
-	_, err := s.db.NamedExecContext(ctx, query, event)
+// DeleteEvent moves the event to the trash, it stays there until restored or purged.
+func (s *Storage) DeleteEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) (err error) {
+	ctx, span := startSpan(ctx, "DeleteEvent", deleteEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inWriteTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpDelete, Event: event, Audit: audit})
+	})
+}
+
+func (s *Storage) ListDeletedEvents(ctx context.Context) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, uuid`
+
+	ctx, span := startSpan(ctx, "ListDeletedEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var events []*storage.Event
+
+	err = s.selectContext(ctx, &events, query)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return events, nil
 }
 
-func (s *Storage) DeleteEvent(ctx context.Context, event storage.Event) error {
-	query := `DELETE FROM events WHERE uuid = $1`
+func (s *Storage) RestoreEvent(ctx context.Context, id string, audit *storage.AuditEntry) (err error) {
+	query := `UPDATE events SET deleted_at = NULL WHERE uuid = $1 AND deleted_at IS NOT NULL`
+
+	ctx, span := startSpan(ctx, "RestoreEvent", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inWriteTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		if err = checkAffected(res); err != nil {
+			return err
+		}
+
+		return addAuditEntry(ctx, tx, audit)
+	})
+}
 
-	_, err := s.db.ExecContext(ctx, query, event.ID)
+func (s *Storage) PurgeDeletedEvents(ctx context.Context, before time.Time) (err error) {
+	query := `DELETE FROM events WHERE deleted_at < $1`
+
+	ctx, span := startSpan(ctx, "PurgeDeletedEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = s.exec(ctx, query, before.UTC())
 	if err != nil {
 		return err
 	}
@@ -84,65 +291,190 @@ func (s *Storage) DeleteEvent(ctx context.Context, event storage.Event) error {
 	return nil
 }
 
-func (s *Storage) ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error) {
-	query := `SELECT uuid, title, start_date, end_date, description, user_id, notify_days
-				FROM events WHERE start_date::DATE = $1`
+// ApplyBatch runs all operations in one transaction. In best-effort mode every operation is
+// wrapped in a savepoint, so a failed item is rolled back alone and does not abort the rest.
+func (s *Storage) ApplyBatch(ctx context.Context, ops []storage.Operation, atomic bool) (_ []error, err error) {
+	ctx, span := tracer.Start(ctx, "sqlstorage.ApplyBatch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName("ApplyBatch")),
+	)
+	defer func() { tracing.Finish(span, err) }()
+
+	storage.MarkWritten(ctx)
 
-	stmt, err := s.db.Preparex(query)
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
-
-	var events []*storage.Event
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]error, len(ops))
+
+	for i, op := range ops {
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, "SAVEPOINT batch_op"); err != nil {
+				return nil, err
+			}
+		}
+
+		opErr := applyOperation(ctx, tx, op)
+
+		switch {
+		case opErr == nil && !atomic:
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_op")
+		case opErr != nil && !atomic:
+			results[i] = opErr
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_op")
+		case opErr != nil:
+			for j := range results {
+				results[j] = storage.ErrBatchAborted
+			}
+			results[i] = opErr
+
+			return results, storage.ErrBatchAborted
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	err = stmt.SelectContext(ctx, &events, date)
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return events, nil
+	return results, nil
 }
 
-func (s *Storage) ListEventsForWeek(ctx context.Context, date time.Time) ([]*storage.Event, error) {
-	startOfWeek := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	endOfWeek := startOfWeek.AddDate(0, 0, 6)
+// applyOperation applies op and records its audit entry within tx.
+func applyOperation(ctx context.Context, tx *sqlx.Tx, op storage.Operation) error {
+	var err error
+
+	switch op.Type {
+	case storage.OpCreate:
+		err = createEvent(ctx, tx, op.Event)
+	case storage.OpUpdate:
+		err = updateEvent(ctx, tx, op.Event)
+	case storage.OpDelete:
+		err = deleteEvent(ctx, tx, op.Event.ID)
+	default:
+		err = storage.ErrUnknownOperation
+	}
 
-	query := `SELECT uuid, title, start_date, end_date, description, user_id, notify_days 
-				FROM events WHERE start_date::DATE between $1 and $2`
+	if err != nil {
+		return err
+	}
+
+	return addAuditEntry(ctx, tx, op.Audit)
+}
 
-	stmt, err := s.db.Preparex(query)
+func deleteEvent(ctx context.Context, tx *sqlx.Tx, id string) error {
+	res, err := tx.ExecContext(ctx, deleteEventQuery, id)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer stmt.Close()
 
-	var events []*storage.Event
+	return checkAffected(res)
+}
+
+func createEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	if _, err := tx.NamedExecContext(ctx, createEventQuery, event); err != nil {
+		return eventError(err)
+	}
+
+	return setTags(ctx, tx, event.ID, event.Tags)
+}
 
-	err = stmt.SelectContext(ctx, &events, startOfWeek, endOfWeek)
+func updateEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	res, err := tx.NamedExecContext(ctx, updateEventQuery, event)
 	if err != nil {
-		return nil, err
+		return eventError(err)
 	}
 
-	return events, nil
+	if err = checkAffected(res); err != nil {
+		return err
+	}
+
+	return setTags(ctx, tx, event.ID, event.Tags)
 }
 
-func (s *Storage) ListEventsForMonth(ctx context.Context, date time.Time) ([]*storage.Event, error) {
-	startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
-	endOfMonth := startOfMonth.AddDate(0, 1, -1)
+// setTags replaces the tags of the event.
+func setTags(ctx context.Context, tx *sqlx.Tx, eventID string, tags storage.Tags) error {
+	if _, err := tx.ExecContext(ctx, deleteEventTagsQuery, eventID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, insertEventTagQuery, eventID, tag); err != nil {
+			return err
+		}
+	}
 
-	query := `SELECT uuid, title, start_date, end_date, description, user_id, notify_days
-				FROM events WHERE start_date::DATE between $1 and $2`
+	return nil
+}
 
-	stmt, err := s.db.Preparex(query)
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrEventNotExists
 	}
-	defer stmt.Close()
+
+	return nil
+}
+
+// eventError translates constraint violations of event writes into storage errors.
+func eventError(err error) error {
+	switch {
+	case isPgError(err, uniqueViolation):
+		return storage.ErrEventAlreadyExists
+	case isPgError(err, foreignKeyViolation):
+		return storage.ErrCalendarNotExists
+	default:
+		return err
+	}
+}
+
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+func (s *Storage) ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.DayRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForDay", from, to)
+}
+
+func (s *Storage) ListEventsForWeek(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.WeekRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForWeek", from, to)
+}
+
+func (s *Storage) ListEventsForMonth(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.MonthRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForMonth", from, to)
+}
+
+// listStartingBetween returns live events starting in [from, to) ordered by start date.
+func (s *Storage) listStartingBetween(
+	ctx context.Context, operation string, from, to time.Time,
+) (_ []*storage.Event, err error) {
+	ctx, span := startSpan(ctx, operation, listStartingBetweenQuery)
+	defer func() { tracing.Finish(span, err) }()
 
 	var events []*storage.Event
 
-	err = stmt.SelectContext(ctx, &events, startOfMonth, endOfMonth)
+	err = s.selectRouted(ctx, &events, listStartingBetweenQuery, from.UTC(), to.UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -150,19 +482,35 @@ func (s *Storage) ListEventsForMonth(ctx context.Context, date time.Time) ([]*st
 	return events, nil
 }
 
-func (s *Storage) ListEventsForNotify(ctx context.Context, date time.Time) ([]*storage.Event, error) {
-	query := `SELECT uuid, title, start_date, user_id FROM events
-				WHERE start_date::DATE = ($1::TIMESTAMP + INTERVAL '1 day' * notify_days)::DATE`
+// ListEventsNear returns live events with coordinates within radius meters of center starting
+// in [from, to), ordered by start date.
+func (s *Storage) ListEventsNear(
+	ctx context.Context, center storage.Point, radius float64, from, to time.Time,
+) (_ []*storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListEventsNear", listNearQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	minLat, maxLat, minLon, maxLon := storage.BoundingBox(center, radius)
+
+	var events []*storage.Event
 
-	stmt, err := s.db.Preparex(query)
+	err = s.selectRouted(ctx, &events, listNearQuery, from.UTC(), to.UTC(), minLat, maxLat, minLon, maxLon,
+		center.Latitude, center.Longitude, radius)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+
+	return events, nil
+}
+
+// ListEventsForNotify returns live events starting notify_days after date, the days are compared in UTC.
+func (s *Storage) ListEventsForNotify(ctx context.Context, date time.Time) (_ []*storage.Event, err error) {
+	ctx, span := startSpan(ctx, "ListEventsForNotify", listForNotifyQuery)
+	defer func() { tracing.Finish(span, err) }()
 
 	var events []*storage.Event
 
-	err = stmt.SelectContext(ctx, &events, date)
+	err = s.selectRouted(ctx, &events, listForNotifyQuery, date.UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -170,15 +518,68 @@ func (s *Storage) ListEventsForNotify(ctx context.Context, date time.Time) ([]*s
 	return events, nil
 }
 
-func (s *Storage) DeleteOldEvents(ctx context.Context, date time.Time) error {
-	oneYearAgo := date.AddDate(-1, 0, 0)
-
+func (s *Storage) DeleteOldEvents(ctx context.Context, date time.Time) (err error) {
 	query := `DELETE FROM events WHERE end_date < $1`
 
-	_, err := s.db.ExecContext(ctx, query, oneYearAgo)
+	ctx, span := startSpan(ctx, "DeleteOldEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = s.exec(ctx, query, storage.OldEventsThreshold(date).UTC())
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// addAuditEntry records the entry, if any, within tx.
+func addAuditEntry(ctx context.Context, tx *sqlx.Tx, entry *storage.AuditEntry) error {
+	if entry == nil {
+		return nil
+	}
+
+	_, err := tx.NamedExecContext(ctx, addAuditEntryQuery, entry)
+
+	return err
+}
+
+func (s *Storage) ListAuditEntries(ctx context.Context, eventID string) (_ []*storage.AuditEntry, err error) {
+	ctx, span := startSpan(ctx, "ListAuditEntries", listAuditEntriesQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var entries []*storage.AuditEntry
+
+	err = s.selectContext(ctx, &entries, listAuditEntriesQuery, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ListTags returns how many live events of every calendar have each tag starting with prefix,
+// ordered by tag.
+func (s *Storage) ListTags(ctx context.Context, prefix string) (_ []*storage.TagUsage, err error) {
+	ctx, span := startSpan(ctx, "ListTags", listTagsQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var usages []*storage.TagUsage
+
+	err = s.selectRouted(ctx, &usages, listTagsQuery, storage.LikePrefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	return usages, nil
+}
+
+func startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sqlstorage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		),
+	)
+}
@@ -0,0 +1,118 @@
+package sqlstorage
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorageContract runs the suite against an ephemeral embedded PostgreSQL, or against the
+// database given by CALENDAR_TEST_DB_* variables, e.g. the one from deployments/docker-compose.yaml.
+// Every test of the suite truncates the calendar tables.
+func TestStorageContract(t *testing.T) {
+	cfg := testDBConf(t)
+
+	storagetest.Run(t, func(t *testing.T) app.Storage {
+		t.Helper()
+
+		ctx := context.Background()
+
+		s := New(cfg)
+		require.NoError(t, s.Connect(ctx))
+		t.Cleanup(func() { _ = s.Close(ctx) })
+
+		_, err := s.db.ExecContext(ctx, `TRUNCATE events, event_tags, event_attachments, event_audit, event_opt_outs,
+			calendar_shares, calendars, notification_preferences`)
+		require.NoError(t, err)
+
+		return s
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	all, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	for i, migration := range all {
+		require.Equal(t, int64(i+1), migration.Version, migration.Name)
+		require.Contains(t, migration.up, "-- +goose Up", migration.Name)
+		require.NotEmpty(t, strings.TrimSpace(migration.down), migration.Name)
+	}
+
+	latest, err := LatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, all[len(all)-1].Version, latest)
+}
+
+// testDBConf returns the database from CALENDAR_TEST_DB_* variables when CALENDAR_TEST_DB_HOST
+// is set and starts an embedded PostgreSQL for the test otherwise.
+func testDBConf(t *testing.T) config.DBConf {
+	t.Helper()
+
+	// The migrations are written to be re-runnable over a schema created by an older test run.
+	if host := os.Getenv("CALENDAR_TEST_DB_HOST"); host != "" {
+		return config.DBConf{
+			Host:        host,
+			Port:        envOr("CALENDAR_TEST_DB_PORT", "5432"),
+			DBName:      envOr("CALENDAR_TEST_DB_NAME", "calendar_test"),
+			User:        envOr("CALENDAR_TEST_DB_USER", "postgres"),
+			Pass:        os.Getenv("CALENDAR_TEST_DB_PASS"),
+			AutoMigrate: true,
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("PostgreSQL refuses to run as root, set CALENDAR_TEST_DB_HOST to test against a server")
+	}
+
+	port := freePort(t)
+	runtime := t.TempDir()
+
+	db := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("calendar_test").
+		RuntimePath(runtime).
+		DataPath(filepath.Join(runtime, "data")).
+		Logger(io.Discard))
+	require.NoError(t, db.Start())
+	t.Cleanup(func() { require.NoError(t, db.Stop()) })
+
+	return config.DBConf{
+		Host:        "localhost",
+		Port:        strconv.FormatUint(uint64(port), 10),
+		DBName:      "calendar_test",
+		User:        "postgres",
+		Pass:        "postgres",
+		AutoMigrate: true,
+	}
+}
+
+func freePort(t *testing.T) uint32 {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	return uint32(listener.Addr().(*net.TCPAddr).Port) //nolint:gosec
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
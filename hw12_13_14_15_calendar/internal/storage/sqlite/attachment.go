@@ -0,0 +1,106 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+)
+
+const (
+	attachmentColumns = `uuid, event_uuid, name, content_type, size, created_at`
+
+	getAttachmentQuery = `SELECT ` + attachmentColumns + ` FROM event_attachments WHERE uuid = $1`
+
+	listAttachmentsQuery = `SELECT ` + attachmentColumns + ` FROM event_attachments WHERE event_uuid = $1
+				ORDER BY created_at, uuid`
+
+	listOrphanedAttachmentsQuery = `SELECT ` + attachmentColumns + ` FROM event_attachments a
+				WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.uuid = a.event_uuid) ORDER BY created_at, uuid`
+)
+
+// CreateAttachment adds the attachment to a live event.
+func (s *Storage) CreateAttachment(ctx context.Context, attachment storage.Attachment) (err error) {
+	query := `INSERT INTO event_attachments (uuid, event_uuid, name, content_type, size, created_at)
+				SELECT $1, $2, $3, $4, $5, $6
+				WHERE EXISTS (SELECT 1 FROM events WHERE uuid = $2 AND deleted_at IS NULL)`
+
+	ctx, span := startSpan(ctx, "CreateAttachment", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, attachment.ID, attachment.EventID, attachment.Name, attachment.ContentType,
+		attachment.Size, attachment.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func (s *Storage) GetAttachment(ctx context.Context, id string) (_ *storage.Attachment, err error) {
+	ctx, span := startSpan(ctx, "GetAttachment", getAttachmentQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var attachment storage.Attachment
+
+	err = s.db.GetContext(ctx, &attachment, getAttachmentQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrAttachmentNotExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// ListAttachments returns the attachments of the event in the order they were added.
+func (s *Storage) ListAttachments(ctx context.Context, eventID string) (_ []*storage.Attachment, err error) {
+	ctx, span := startSpan(ctx, "ListAttachments", listAttachmentsQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var attachments []*storage.Attachment
+
+	err = s.db.SelectContext(ctx, &attachments, listAttachmentsQuery, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// ListOrphanedAttachments returns the attachments of events which no longer exist.
+func (s *Storage) ListOrphanedAttachments(ctx context.Context) (_ []*storage.Attachment, err error) {
+	ctx, span := startSpan(ctx, "ListOrphanedAttachments", listOrphanedAttachmentsQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var attachments []*storage.Attachment
+
+	err = s.db.SelectContext(ctx, &attachments, listOrphanedAttachmentsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id string) (err error) {
+	query := `DELETE FROM event_attachments WHERE uuid = $1`
+
+	ctx, span := startSpan(ctx, "DeleteAttachment", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); errors.Is(err, storage.ErrEventNotExists) {
+		return storage.ErrAttachmentNotExists
+	}
+
+	return err
+}
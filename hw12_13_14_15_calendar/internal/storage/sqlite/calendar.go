@@ -0,0 +1,184 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/jmoiron/sqlx"
+)
+
+func (s *Storage) CreateCalendar(ctx context.Context, calendar storage.Calendar) (err error) {
+	query := `INSERT INTO calendars (uuid, owner_id, name, color, time_zone)
+				VALUES (:uuid, :owner_id, :name, :color, :time_zone)`
+
+	ctx, span := startSpan(ctx, "CreateCalendar", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = sqlx.NamedExecContext(ctx, s.db, query, calendar)
+	if isUniqueViolation(err) {
+		return storage.ErrCalendarExists
+	}
+
+	return err
+}
+
+func (s *Storage) UpdateCalendar(ctx context.Context, calendar storage.Calendar) (err error) {
+	query := `UPDATE calendars SET name=:name, color=:color, time_zone=:time_zone WHERE uuid = :uuid`
+
+	ctx, span := startSpan(ctx, "UpdateCalendar", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := sqlx.NamedExecContext(ctx, s.db, query, calendar)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); errors.Is(err, storage.ErrEventNotExists) {
+		return storage.ErrCalendarNotExists
+	}
+
+	return err
+}
+
+// DeleteCalendar refuses to delete a calendar with live events. Events in the trash lose the
+// reference and shares are removed by the foreign keys.
+func (s *Storage) DeleteCalendar(ctx context.Context, id string) (err error) {
+	query := `DELETE FROM calendars WHERE uuid = $1
+				AND NOT EXISTS (SELECT 1 FROM events WHERE calendar_uuid = $1 AND deleted_at IS NULL)`
+
+	ctx, span := startSpan(ctx, "DeleteCalendar", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err = s.GetCalendar(ctx, id); err != nil {
+		return err
+	}
+
+	return storage.ErrCalendarNotEmpty
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, id string) (_ *storage.Calendar, err error) {
+	query := `SELECT uuid, owner_id, name, color, time_zone FROM calendars WHERE uuid = $1`
+
+	ctx, span := startSpan(ctx, "GetCalendar", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var calendar storage.Calendar
+
+	err = s.db.GetContext(ctx, &calendar, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrCalendarNotExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
+
+// ListCalendars returns calendars owned by the user or shared with them.
+func (s *Storage) ListCalendars(ctx context.Context, userID string) (_ []*storage.Calendar, err error) {
+	query := `SELECT uuid, owner_id, name, color, time_zone FROM calendars WHERE owner_id = $1
+				UNION
+				SELECT c.uuid, c.owner_id, c.name, c.color, c.time_zone FROM calendars c
+				JOIN calendar_shares s ON s.calendar_uuid = c.uuid WHERE s.user_id = $1
+				ORDER BY name`
+
+	ctx, span := startSpan(ctx, "ListCalendars", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var calendars []*storage.Calendar
+
+	err = s.db.SelectContext(ctx, &calendars, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return calendars, nil
+}
+
+// ListCalendarEvents returns live events of the calendar ordered by start date.
+func (s *Storage) ListCalendarEvents(ctx context.Context, calendarID string) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events
+				WHERE calendar_uuid = $1 AND deleted_at IS NULL ORDER BY start_date, uuid`
+
+	ctx, span := startSpan(ctx, "ListCalendarEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var events []*storage.Event
+
+	err = s.db.SelectContext(ctx, &events, query, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// SetShare creates the share or replaces the permission of the existing one.
+func (s *Storage) SetShare(ctx context.Context, share storage.Share) (err error) {
+	query := `INSERT INTO calendar_shares (calendar_uuid, user_id, permission)
+				VALUES (:calendar_uuid, :user_id, :permission)
+				ON CONFLICT (calendar_uuid, user_id) DO UPDATE SET permission = EXCLUDED.permission`
+
+	ctx, span := startSpan(ctx, "SetShare", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = sqlx.NamedExecContext(ctx, s.db, query, share)
+	if isForeignKeyViolation(err) {
+		return storage.ErrCalendarNotExists
+	}
+
+	return err
+}
+
+func (s *Storage) DeleteShare(ctx context.Context, calendarID, userID string) (err error) {
+	query := `DELETE FROM calendar_shares WHERE calendar_uuid = $1 AND user_id = $2`
+
+	ctx, span := startSpan(ctx, "DeleteShare", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, calendarID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); errors.Is(err, storage.ErrEventNotExists) {
+		return storage.ErrShareNotExists
+	}
+
+	return err
+}
+
+func (s *Storage) ListShares(ctx context.Context, calendarID string) (_ []*storage.Share, err error) {
+	query := `SELECT calendar_uuid, user_id, permission FROM calendar_shares WHERE calendar_uuid = $1 ORDER BY user_id`
+
+	ctx, span := startSpan(ctx, "ListShares", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var shares []*storage.Share
+
+	err = s.db.SelectContext(ctx, &shares, query, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
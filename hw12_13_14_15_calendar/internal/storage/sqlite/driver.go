@@ -0,0 +1,75 @@
+package sqlitestorage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The driver is confined to this file. modernc.org/sqlite is SQLite translated to Go, so the
+// binaries build with CGO_ENABLED=0.
+const driverName = "sqlite"
+
+func init() {
+	// SQLite has no trigonometric functions unless built with them, the distance between
+	// events and a point is computed in Go instead. Functions are registered for every
+	// connection the driver opens.
+	sqlite.MustRegisterDeterministicScalarFunction("distance", 4, distanceFunc)
+}
+
+func distanceFunc(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	coords := make([]float64, len(args))
+
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case nil:
+			return nil, nil
+		case float64:
+			coords[i] = value
+		case int64:
+			coords[i] = float64(value)
+		default:
+			return nil, fmt.Errorf("distance: unexpected argument %T", arg)
+		}
+	}
+
+	return distance(coords[0], coords[1], coords[2], coords[3]), nil
+}
+
+// distance returns the distance between two points in meters, see storage.Distance.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	return storage.Distance(
+		storage.Point{Latitude: lat1, Longitude: lon1},
+		storage.Point{Latitude: lat2, Longitude: lon2},
+	)
+}
+
+// dsn sets the busy timeout first so the other pragmas wait for a concurrent writer, turns on
+// foreign keys, which SQLite leaves off by default, and the WAL journal so readers do not block
+// the writer. Immediate transactions make a batch take the write lock up front. Dates are
+// written as "2006-01-02 15:04:05.999999999-07:00", the queries compare them as strings.
+func dsn(path string) string {
+	return "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)" +
+		"&_txlock=immediate&_time_format=sqlite"
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY ||
+		sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
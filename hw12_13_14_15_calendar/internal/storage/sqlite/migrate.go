@@ -0,0 +1,76 @@
+package sqlitestorage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies the up sections of the goose migrations newer than the schema version kept
+// in PRAGMA user_version, each one in its own transaction.
+func (s *Storage) migrate(ctx context.Context) error {
+	var current int
+
+	if err := s.db.GetContext(ctx, &current, "PRAGMA user_version"); err != nil {
+		return err
+	}
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(file.Name(), "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", file.Name(), err)
+		}
+
+		if version <= current {
+			continue
+		}
+
+		content, err := migrations.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			return err
+		}
+
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+
+		if err = s.applyMigration(ctx, version, up); err != nil {
+			return fmt.Errorf("migration %s: %w", file.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) applyMigration(ctx context.Context, version int, query string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// PRAGMA does not take parameters.
+	if _, err = tx.ExecContext(ctx, "PRAGMA user_version = "+strconv.Itoa(version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
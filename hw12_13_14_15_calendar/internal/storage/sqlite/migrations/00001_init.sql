@@ -0,0 +1,54 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS calendars (
+    uuid TEXT PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    color TEXT,
+    time_zone TEXT NOT NULL DEFAULT 'UTC'
+);
+
+CREATE INDEX IF NOT EXISTS calendars_owner_id_idx ON calendars (owner_id);
+
+CREATE TABLE IF NOT EXISTS calendar_shares (
+    calendar_uuid TEXT NOT NULL REFERENCES calendars (uuid) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (calendar_uuid, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_shares_user_id_idx ON calendar_shares (user_id);
+
+-- Dates are stored as UTC text in the driver format, so they compare correctly as strings.
+CREATE TABLE IF NOT EXISTS events (
+    uuid TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    start_date TIMESTAMP NOT NULL,
+    end_date TIMESTAMP NOT NULL,
+    description TEXT,
+    user_id TEXT,
+    notify_days INTEGER,
+    calendar_uuid TEXT REFERENCES calendars (uuid) ON DELETE SET NULL,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS events_start_date_idx ON events (start_date);
+CREATE INDEX IF NOT EXISTS events_calendar_uuid_idx ON events (calendar_uuid);
+CREATE INDEX IF NOT EXISTS events_deleted_at_idx ON events (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS event_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_uuid TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    snapshot_before BLOB,
+    snapshot_after BLOB,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS event_audit_event_uuid_idx ON event_audit (event_uuid, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_audit;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS calendar_shares;
+DROP TABLE IF EXISTS calendars;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN category TEXT;
+ALTER TABLE events ADD COLUMN color TEXT;
+CREATE INDEX IF NOT EXISTS events_category_idx ON events (category) WHERE category IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS event_tags (
+    event_uuid TEXT NOT NULL REFERENCES events (uuid) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (event_uuid, tag)
+);
+
+CREATE INDEX IF NOT EXISTS event_tags_tag_idx ON event_tags (tag);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_tags;
+DROP INDEX IF EXISTS events_category_idx;
+ALTER TABLE events DROP COLUMN color;
+ALTER TABLE events DROP COLUMN category;
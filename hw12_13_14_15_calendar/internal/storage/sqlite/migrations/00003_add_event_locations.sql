@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN address TEXT;
+ALTER TABLE events ADD COLUMN latitude REAL;
+ALTER TABLE events ADD COLUMN longitude REAL;
+ALTER TABLE events ADD COLUMN meeting_url TEXT;
+
+CREATE INDEX IF NOT EXISTS events_latitude_longitude_idx ON events (latitude, longitude) WHERE latitude IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS events_latitude_longitude_idx;
+ALTER TABLE events DROP COLUMN meeting_url;
+ALTER TABLE events DROP COLUMN longitude;
+ALTER TABLE events DROP COLUMN latitude;
+ALTER TABLE events DROP COLUMN address;
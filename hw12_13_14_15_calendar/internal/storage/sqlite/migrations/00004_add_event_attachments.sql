@@ -0,0 +1,16 @@
+-- +goose Up
+-- Attachments do not reference events: the rows of purged events are kept until the scheduler
+-- removes their contents from the blob store.
+CREATE TABLE IF NOT EXISTS event_attachments (
+    uuid TEXT PRIMARY KEY,
+    event_uuid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS event_attachments_event_uuid_idx ON event_attachments (event_uuid);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_attachments;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT PRIMARY KEY,
+    channel TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    quiet_start TEXT NOT NULL DEFAULT '',
+    quiet_end TEXT NOT NULL DEFAULT '',
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    delivery TEXT NOT NULL DEFAULT 'immediate'
+);
+
+CREATE TABLE IF NOT EXISTS event_opt_outs (
+    event_uuid TEXT NOT NULL REFERENCES events (uuid) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    PRIMARY KEY (event_uuid, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS event_opt_outs_user_id_idx ON event_opt_outs (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_opt_outs;
+DROP TABLE IF EXISTS notification_preferences;
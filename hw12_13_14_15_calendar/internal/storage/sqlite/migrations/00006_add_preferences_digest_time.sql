@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE notification_preferences ADD COLUMN digest_time TEXT NOT NULL DEFAULT '08:00';
+
+-- +goose Down
+ALTER TABLE notification_preferences DROP COLUMN digest_time;
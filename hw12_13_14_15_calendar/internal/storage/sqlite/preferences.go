@@ -0,0 +1,138 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	preferencesColumns = `user_id, channel, address, quiet_start, quiet_end, time_zone, delivery, digest_time`
+
+	getPreferencesQuery = `SELECT ` + preferencesColumns + ` FROM notification_preferences WHERE user_id = $1`
+
+	listPreferencesQuery = `SELECT ` + preferencesColumns + ` FROM notification_preferences
+				WHERE delivery = $1 ORDER BY user_id`
+
+	listOptOutsQuery = `SELECT event_uuid, user_id FROM event_opt_outs WHERE user_id = $1 ORDER BY event_uuid`
+)
+
+func (s *Storage) GetPreferences(ctx context.Context, userID string) (_ *storage.Preferences, err error) {
+	ctx, span := startSpan(ctx, "GetPreferences", getPreferencesQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var preferences storage.Preferences
+
+	err = s.db.GetContext(ctx, &preferences, getPreferencesQuery, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPreferencesNotExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &preferences, nil
+}
+
+// SetPreferences creates or replaces the preferences of the user.
+func (s *Storage) SetPreferences(ctx context.Context, preferences storage.Preferences) (err error) {
+	query := `INSERT INTO notification_preferences (` + preferencesColumns + `)
+				VALUES (:user_id, :channel, :address, :quiet_start, :quiet_end, :time_zone, :delivery, :digest_time)
+				ON CONFLICT (user_id) DO UPDATE SET channel = EXCLUDED.channel, address = EXCLUDED.address,
+				quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end,
+				time_zone = EXCLUDED.time_zone, delivery = EXCLUDED.delivery, digest_time = EXCLUDED.digest_time`
+
+	ctx, span := startSpan(ctx, "SetPreferences", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = sqlx.NamedExecContext(ctx, s.db, query, preferences)
+
+	return err
+}
+
+// ListPreferences returns the preferences of the users who chose the delivery, ordered by user.
+func (s *Storage) ListPreferences(ctx context.Context, delivery string) (_ []*storage.Preferences, err error) {
+	ctx, span := startSpan(ctx, "ListPreferences", listPreferencesQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var preferences []*storage.Preferences
+
+	err = s.db.SelectContext(ctx, &preferences, listPreferencesQuery, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (s *Storage) DeletePreferences(ctx context.Context, userID string) (err error) {
+	query := `DELETE FROM notification_preferences WHERE user_id = $1`
+
+	ctx, span := startSpan(ctx, "DeletePreferences", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); errors.Is(err, storage.ErrEventNotExists) {
+		return storage.ErrPreferencesNotExists
+	}
+
+	return err
+}
+
+// SetOptOut turns off the notifications about the event for the user, repeating it is a no-op.
+func (s *Storage) SetOptOut(ctx context.Context, optOut storage.OptOut) (err error) {
+	query := `INSERT INTO event_opt_outs (event_uuid, user_id) VALUES (:event_uuid, :user_id)
+				ON CONFLICT (event_uuid, user_id) DO NOTHING`
+
+	ctx, span := startSpan(ctx, "SetOptOut", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = sqlx.NamedExecContext(ctx, s.db, query, optOut)
+	if isForeignKeyViolation(err) {
+		return storage.ErrEventNotExists
+	}
+
+	return err
+}
+
+func (s *Storage) DeleteOptOut(ctx context.Context, eventID, userID string) (err error) {
+	query := `DELETE FROM event_opt_outs WHERE event_uuid = $1 AND user_id = $2`
+
+	ctx, span := startSpan(ctx, "DeleteOptOut", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, query, eventID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); errors.Is(err, storage.ErrEventNotExists) {
+		return storage.ErrOptOutNotExists
+	}
+
+	return err
+}
+
+// ListOptOuts returns the opt-outs of the user ordered by event.
+func (s *Storage) ListOptOuts(ctx context.Context, userID string) (_ []*storage.OptOut, err error) {
+	ctx, span := startSpan(ctx, "ListOptOuts", listOptOutsQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	var optOuts []*storage.OptOut
+
+	err = s.db.SelectContext(ctx, &optOuts, listOptOutsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return optOuts, nil
+}
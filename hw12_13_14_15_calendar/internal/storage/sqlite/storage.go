@@ -0,0 +1,525 @@
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/sqlite")
+
+// Storage keeps events in a single SQLite file for deployments without PostgreSQL. Dates are
+// written in UTC, so the queries behave like the ones of sqlstorage.
+type Storage struct {
+	db *sqlx.DB
+}
+
+func New(cfg config.SQLiteConf) *Storage {
+	db, err := sqlx.Open(driverName, dsn(cfg.Path))
+	if err != nil {
+		panic(fmt.Sprintf("database init error: %v", err))
+	}
+
+	return &Storage{
+		db: db,
+	}
+}
+
+// Connect opens the database file and brings its schema up to date.
+func (s *Storage) Connect(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		return fmt.Errorf("failed to migrate sqlite database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *Storage) Close(_ context.Context) error {
+	return s.db.Close()
+}
+
+const (
+	createEventQuery = `INSERT INTO events (uuid, title, start_date, end_date, description, user_id, notify_days,
+				calendar_uuid, category, color, address, latitude, longitude, meeting_url)
+				VALUES (:uuid, :title, :start_date, :end_date, :description, :user_id, :notify_days, :calendar_uuid,
+				:category, :color, :address, :latitude, :longitude, :meeting_url)`
+
+	updateEventQuery = `UPDATE events SET title=:title, start_date=:start_date, end_date=:end_date,
+				description=:description, user_id=:user_id, notify_days=:notify_days, calendar_uuid=:calendar_uuid,
+				category=:category, color=:color, address=:address, latitude=:latitude, longitude=:longitude,
+				meeting_url=:meeting_url
+				WHERE uuid = :uuid AND deleted_at IS NULL`
+
+	deleteEventQuery = `UPDATE events SET deleted_at = $1 WHERE uuid = $2 AND deleted_at IS NULL`
+
+	addAuditEntryQuery = `INSERT INTO event_audit
+				(event_uuid, actor, action, snapshot_before, snapshot_after, created_at)
+				VALUES (:event_uuid, :actor, :action, :snapshot_before, :snapshot_after, :created_at)`
+
+	// group_concat does not order the tags, storage.Tags sorts them when scanning.
+	eventColumns = `uuid, title, start_date, end_date, description, user_id, notify_days, calendar_uuid, category,
+				color, address, latitude, longitude, meeting_url, deleted_at,
+				(SELECT group_concat(tag, ',') FROM event_tags WHERE event_tags.event_uuid = events.uuid) AS tags`
+
+	deleteEventTagsQuery = `DELETE FROM event_tags WHERE event_uuid = $1`
+
+	insertEventTagQuery = `INSERT INTO event_tags (event_uuid, tag) VALUES ($1, $2)`
+)
+
+// inUTC converts the dates of the event before writing, SQLite compares them as strings.
+func inUTC(event storage.Event) storage.Event {
+	event.StartDate = event.StartDate.UTC()
+	event.EndDate = event.EndDate.UTC()
+
+	return event
+}
+
+// GetEvent returns the event even if it is in the trash, callers check DeletedAt.
+func (s *Storage) GetEvent(ctx context.Context, id string) (_ *storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE uuid = $1`
+
+	ctx, span := startSpan(ctx, "GetEvent", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var event storage.Event
+
+	err = s.db.GetContext(ctx, &event, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrEventNotExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) (err error) {
+	ctx, span := startSpan(ctx, "CreateEvent", createEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpCreate, Event: event, Audit: audit})
+	})
+}
+
+func (s *Storage) UpdateEvent(
+	ctx context.Context, id string, event storage.Event, audit *storage.AuditEntry,
+) (err error) {
+	event.ID = id
+
+	ctx, span := startSpan(ctx, "UpdateEvent", updateEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpUpdate, Event: event, Audit: audit})
+	})
+}
+
+// DeleteEvent moves the event to the trash, it stays there until restored or purged.
+func (s *Storage) DeleteEvent(ctx context.Context, event storage.Event, audit *storage.AuditEntry) (err error) {
+	ctx, span := startSpan(ctx, "DeleteEvent", deleteEventQuery)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return applyOperation(ctx, tx, storage.Operation{Type: storage.OpDelete, Event: event, Audit: audit})
+	})
+}
+
+func (s *Storage) ListDeletedEvents(ctx context.Context) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, uuid`
+
+	ctx, span := startSpan(ctx, "ListDeletedEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var events []*storage.Event
+
+	err = s.db.SelectContext(ctx, &events, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *Storage) RestoreEvent(ctx context.Context, id string, audit *storage.AuditEntry) (err error) {
+	query := `UPDATE events SET deleted_at = NULL WHERE uuid = $1 AND deleted_at IS NOT NULL`
+
+	ctx, span := startSpan(ctx, "RestoreEvent", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+
+		if err = checkAffected(res); err != nil {
+			return err
+		}
+
+		return addAuditEntry(ctx, tx, audit)
+	})
+}
+
+func (s *Storage) PurgeDeletedEvents(ctx context.Context, before time.Time) (err error) {
+	query := `DELETE FROM events WHERE deleted_at < $1`
+
+	ctx, span := startSpan(ctx, "PurgeDeletedEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ApplyBatch runs all operations in one transaction. In best-effort mode every operation is
+// wrapped in a savepoint, so a failed item is rolled back alone and does not abort the rest.
+func (s *Storage) ApplyBatch(ctx context.Context, ops []storage.Operation, atomic bool) (_ []error, err error) {
+	ctx, span := tracer.Start(ctx, "sqlitestorage.ApplyBatch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemSqlite, semconv.DBOperationName("ApplyBatch")),
+	)
+	defer func() { tracing.Finish(span, err) }()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]error, len(ops))
+
+	for i, op := range ops {
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, "SAVEPOINT batch_op"); err != nil {
+				return nil, err
+			}
+		}
+
+		opErr := applyOperation(ctx, tx, op)
+
+		switch {
+		case opErr == nil && !atomic:
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_op")
+		case opErr != nil && !atomic:
+			results[i] = opErr
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_op")
+			if err == nil {
+				// Unlike PostgreSQL, SQLite keeps the savepoint on the stack after rolling back to it.
+				_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_op")
+			}
+		case opErr != nil:
+			for j := range results {
+				results[j] = storage.ErrBatchAborted
+			}
+			results[i] = opErr
+
+			return results, storage.ErrBatchAborted
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// applyOperation applies op and records its audit entry within tx.
+func applyOperation(ctx context.Context, tx *sqlx.Tx, op storage.Operation) error {
+	var err error
+
+	switch op.Type {
+	case storage.OpCreate:
+		err = createEvent(ctx, tx, op.Event)
+	case storage.OpUpdate:
+		err = updateEvent(ctx, tx, op.Event)
+	case storage.OpDelete:
+		err = deleteEvent(ctx, tx, op.Event.ID)
+	default:
+		err = storage.ErrUnknownOperation
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return addAuditEntry(ctx, tx, op.Audit)
+}
+
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteEvent(ctx context.Context, tx *sqlx.Tx, id string) error {
+	res, err := tx.ExecContext(ctx, deleteEventQuery, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+func createEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	if _, err := tx.NamedExecContext(ctx, createEventQuery, inUTC(event)); err != nil {
+		return eventError(err)
+	}
+
+	return setTags(ctx, tx, event.ID, event.Tags)
+}
+
+func updateEvent(ctx context.Context, tx *sqlx.Tx, event storage.Event) error {
+	res, err := tx.NamedExecContext(ctx, updateEventQuery, inUTC(event))
+	if err != nil {
+		return eventError(err)
+	}
+
+	if err = checkAffected(res); err != nil {
+		return err
+	}
+
+	return setTags(ctx, tx, event.ID, event.Tags)
+}
+
+// setTags replaces the tags of the event.
+func setTags(ctx context.Context, tx *sqlx.Tx, eventID string, tags storage.Tags) error {
+	if _, err := tx.ExecContext(ctx, deleteEventTagsQuery, eventID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, insertEventTagQuery, eventID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrEventNotExists
+	}
+
+	return nil
+}
+
+// eventError translates constraint violations of event writes into storage errors.
+func eventError(err error) error {
+	switch {
+	case isUniqueViolation(err):
+		return storage.ErrEventAlreadyExists
+	case isForeignKeyViolation(err):
+		return storage.ErrCalendarNotExists
+	default:
+		return err
+	}
+}
+
+func (s *Storage) ListEventsForDay(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.DayRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForDay", from, to)
+}
+
+func (s *Storage) ListEventsForWeek(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.WeekRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForWeek", from, to)
+}
+
+func (s *Storage) ListEventsForMonth(ctx context.Context, date time.Time) ([]*storage.Event, error) {
+	from, to := storage.MonthRange(date)
+
+	return s.listStartingBetween(ctx, "ListEventsForMonth", from, to)
+}
+
+// listStartingBetween returns live events starting in [from, to) ordered by start date.
+func (s *Storage) listStartingBetween(
+	ctx context.Context, operation string, from, to time.Time,
+) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events
+				WHERE start_date >= $1 AND start_date < $2 AND deleted_at IS NULL ORDER BY start_date, uuid`
+
+	ctx, span := startSpan(ctx, operation, query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var events []*storage.Event
+
+	err = s.db.SelectContext(ctx, &events, query, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ListEventsNear returns live events with coordinates within radius meters of center starting
+// in [from, to), ordered by start date.
+func (s *Storage) ListEventsNear(
+	ctx context.Context, center storage.Point, radius float64, from, to time.Time,
+) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events
+				WHERE start_date >= $1 AND start_date < $2 AND deleted_at IS NULL
+				AND latitude BETWEEN $3 AND $4 AND longitude BETWEEN $5 AND $6
+				AND distance(latitude, longitude, $7, $8) <= $9
+				ORDER BY start_date, uuid`
+
+	ctx, span := startSpan(ctx, "ListEventsNear", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	minLat, maxLat, minLon, maxLon := storage.BoundingBox(center, radius)
+
+	var events []*storage.Event
+
+	err = s.db.SelectContext(ctx, &events, query, from.UTC(), to.UTC(), minLat, maxLat, minLon, maxLon,
+		center.Latitude, center.Longitude, radius)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ListEventsForNotify returns live events starting notify_days after date, the days are compared in UTC.
+func (s *Storage) ListEventsForNotify(ctx context.Context, date time.Time) (_ []*storage.Event, err error) {
+	query := `SELECT ` + eventColumns + ` FROM events
+				WHERE notify_days > 0 AND deleted_at IS NULL
+				AND date(start_date) = date($1, '+' || notify_days || ' days')
+				ORDER BY start_date, uuid`
+
+	ctx, span := startSpan(ctx, "ListEventsForNotify", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var events []*storage.Event
+
+	err = s.db.SelectContext(ctx, &events, query, date.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *Storage) DeleteOldEvents(ctx context.Context, date time.Time) (err error) {
+	query := `DELETE FROM events WHERE end_date < $1`
+
+	ctx, span := startSpan(ctx, "DeleteOldEvents", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, query, storage.OldEventsThreshold(date).UTC())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// addAuditEntry records the entry, if any, within tx.
+func addAuditEntry(ctx context.Context, tx *sqlx.Tx, entry *storage.AuditEntry) error {
+	if entry == nil {
+		return nil
+	}
+
+	audit := *entry
+	if audit.CreatedAt.IsZero() {
+		audit.CreatedAt = time.Now()
+	}
+
+	audit.CreatedAt = audit.CreatedAt.UTC()
+
+	_, err := tx.NamedExecContext(ctx, addAuditEntryQuery, audit)
+
+	return err
+}
+
+func (s *Storage) ListAuditEntries(ctx context.Context, eventID string) (_ []*storage.AuditEntry, err error) {
+	query := `SELECT id, event_uuid, actor, action, snapshot_before, snapshot_after, created_at
+				FROM event_audit WHERE event_uuid = $1 ORDER BY id`
+
+	ctx, span := startSpan(ctx, "ListAuditEntries", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var entries []*storage.AuditEntry
+
+	err = s.db.SelectContext(ctx, &entries, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ListTags returns how many live events of every calendar have each tag starting with prefix,
+// ordered by tag.
+func (s *Storage) ListTags(ctx context.Context, prefix string) (_ []*storage.TagUsage, err error) {
+	query := `SELECT t.tag, e.calendar_uuid, count(*) AS count
+				FROM event_tags t JOIN events e ON e.uuid = t.event_uuid
+				WHERE t.tag LIKE $1 ESCAPE '\' AND e.deleted_at IS NULL
+				GROUP BY t.tag, e.calendar_uuid ORDER BY t.tag, e.calendar_uuid NULLS FIRST`
+
+	ctx, span := startSpan(ctx, "ListTags", query)
+	defer func() { tracing.Finish(span, err) }()
+
+	var usages []*storage.TagUsage
+
+	err = s.db.SelectContext(ctx, &usages, query, storage.LikePrefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	return usages, nil
+}
+
+func startSpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sqlitestorage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemSqlite,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		),
+	)
+}
@@ -0,0 +1,45 @@
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) app.Storage {
+		t.Helper()
+
+		ctx := context.Background()
+
+		s := New(config.SQLiteConf{Path: filepath.Join(t.TempDir(), "calendar.db")})
+		require.NoError(t, s.Connect(ctx))
+		t.Cleanup(func() { _ = s.Close(ctx) })
+
+		return s
+	})
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calendar.db")
+
+	files, err := migrations.ReadDir("migrations")
+	require.NoError(t, err)
+
+	for range 2 {
+		s := New(config.SQLiteConf{Path: path})
+		require.NoError(t, s.Connect(ctx))
+
+		var version int
+		require.NoError(t, s.db.GetContext(ctx, &version, "PRAGMA user_version"))
+		require.Equal(t, len(files), version)
+
+		require.NoError(t, s.Close(ctx))
+	}
+}
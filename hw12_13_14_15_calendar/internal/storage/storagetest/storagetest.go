@@ -0,0 +1,845 @@
+// Package storagetest is a conformance suite every app.Storage implementation must pass, so
+// the application behaves the same on top of any of them.
+//
+// All IDs in the suite are UUIDs and all dates are UTC minutes, the narrowest common ground of
+// the backends.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/app"
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty storage, it is called for every test of the suite.
+type Factory func(t *testing.T) app.Storage
+
+const (
+	event1 = "00000000-0000-0000-0000-000000000001"
+	event2 = "00000000-0000-0000-0000-000000000002"
+	event3 = "00000000-0000-0000-0000-000000000003"
+	event4 = "00000000-0000-0000-0000-000000000004"
+	event5 = "00000000-0000-0000-0000-000000000005"
+	event6 = "00000000-0000-0000-0000-000000000006"
+	event7 = "00000000-0000-0000-0000-000000000007"
+	event8 = "00000000-0000-0000-0000-000000000008"
+
+	calendar1 = "10000000-0000-0000-0000-000000000001"
+	calendar2 = "10000000-0000-0000-0000-000000000002"
+	calendar3 = "10000000-0000-0000-0000-000000000003"
+	missing   = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+
+	attachment1 = "20000000-0000-0000-0000-000000000001"
+	attachment2 = "20000000-0000-0000-0000-000000000002"
+	attachment3 = "20000000-0000-0000-0000-000000000003"
+)
+
+// Run runs the whole suite against storages made by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, s app.Storage)
+	}{
+		{name: "events", test: testEvents},
+		{name: "trash", test: testTrash},
+		{name: "periods", test: testPeriods},
+		{name: "notify", test: testNotify},
+		{name: "old events", test: testDeleteOldEvents},
+		{name: "batch", test: testBatch},
+		{name: "audit", test: testAudit},
+		{name: "calendars", test: testCalendars},
+		{name: "shares", test: testShares},
+		{name: "calendar events", test: testCalendarEvents},
+		{name: "tags", test: testTags},
+		{name: "near", test: testNear},
+		{name: "attachments", test: testAttachments},
+		{name: "preferences", test: testPreferences},
+		{name: "opt-outs", test: testOptOuts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func date(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func newEvent(id string, start time.Time) storage.Event {
+	return storage.Event{ID: id, Title: "event " + id[len(id)-1:], StartDate: start, EndDate: start.Add(time.Hour)}
+}
+
+func mustCreateCalendar(t *testing.T, s app.Storage, id string) {
+	t.Helper()
+
+	calendar := storage.Calendar{ID: id, OwnerID: "alice", Name: "work", TimeZone: "UTC"}
+	require.NoError(t, s.CreateCalendar(context.Background(), calendar))
+}
+
+func mustCreate(t *testing.T, s app.Storage, events ...storage.Event) {
+	t.Helper()
+
+	for _, event := range events {
+		require.NoError(t, s.CreateEvent(context.Background(), event, nil))
+	}
+}
+
+// requireEvent compares events ignoring DeletedAt, which is set by the storage.
+func requireEvent(t *testing.T, expected storage.Event, actual *storage.Event) {
+	t.Helper()
+
+	require.NotNil(t, actual)
+
+	got := *actual
+	got.DeletedAt = sql.NullTime{}
+	got.StartDate = got.StartDate.UTC()
+	got.EndDate = got.EndDate.UTC()
+
+	require.Equal(t, expected, got)
+}
+
+func requireIDs(t *testing.T, expected []string, events []*storage.Event) {
+	t.Helper()
+
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	if len(expected) == 0 {
+		require.Empty(t, ids)
+		return
+	}
+
+	require.Equal(t, expected, ids)
+}
+
+func testEvents(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	event := newEvent(event1, date(time.February, 1, 10, 0))
+	event.Description = sql.NullString{String: "description", Valid: true}
+	event.UserID = sql.NullString{String: "alice", Valid: true}
+	event.NotifyDays = sql.NullInt32{Int32: 2, Valid: true}
+
+	mustCreate(t, s, event)
+	require.ErrorIs(t, s.CreateEvent(ctx, event, nil), storage.ErrEventAlreadyExists)
+
+	got, err := s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	requireEvent(t, event, got)
+	require.False(t, got.DeletedAt.Valid)
+
+	_, err = s.GetEvent(ctx, missing)
+	require.ErrorIs(t, err, storage.ErrEventNotExists)
+
+	event.Title = "updated"
+	event.Description = sql.NullString{}
+	event.StartDate = date(time.February, 2, 11, 30)
+	require.NoError(t, s.UpdateEvent(ctx, event1, event, nil))
+
+	got, err = s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	requireEvent(t, event, got)
+
+	require.ErrorIs(t, s.UpdateEvent(ctx, missing, newEvent(missing, event.StartDate), nil), storage.ErrEventNotExists)
+	require.ErrorIs(t, s.DeleteEvent(ctx, storage.Event{ID: missing}, nil), storage.ErrEventNotExists)
+
+	event.CalendarID = sql.NullString{String: missing, Valid: true}
+	require.ErrorIs(t, s.UpdateEvent(ctx, event1, event, nil), storage.ErrCalendarNotExists)
+	require.ErrorIs(t, s.CreateEvent(ctx, newEventIn(event2, missing), nil), storage.ErrCalendarNotExists)
+
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event1}, nil))
+	require.ErrorIs(t, s.DeleteEvent(ctx, storage.Event{ID: event1}, nil), storage.ErrEventNotExists)
+	require.ErrorIs(t, s.UpdateEvent(ctx, event1, newEvent(event1, event.StartDate), nil), storage.ErrEventNotExists)
+
+	got, err = s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	require.True(t, got.DeletedAt.Valid)
+}
+
+func newEventIn(id, calendarID string) storage.Event {
+	event := newEvent(id, date(time.February, 1, 9, 0))
+	event.CalendarID = sql.NullString{String: calendarID, Valid: true}
+
+	return event
+}
+
+func testTrash(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	day := date(time.February, 1, 0, 0)
+
+	mustCreate(t, s, newEvent(event1, day.Add(9*time.Hour)), newEvent(event2, day.Add(10*time.Hour)))
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event1}, nil))
+
+	events, err := s.ListEventsForDay(ctx, day)
+	require.NoError(t, err)
+	requireIDs(t, []string{event2}, events)
+
+	trash, err := s.ListDeletedEvents(ctx)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1}, trash)
+	require.True(t, trash[0].DeletedAt.Valid)
+	require.WithinDuration(t, time.Now(), trash[0].DeletedAt.Time, 24*time.Hour)
+
+	require.ErrorIs(t, s.CreateEvent(ctx, newEvent(event1, day), nil), storage.ErrEventAlreadyExists)
+
+	require.NoError(t, s.RestoreEvent(ctx, event1, nil))
+	require.ErrorIs(t, s.RestoreEvent(ctx, event1, nil), storage.ErrEventNotExists)
+	require.ErrorIs(t, s.RestoreEvent(ctx, missing, nil), storage.ErrEventNotExists)
+
+	events, err = s.ListEventsForDay(ctx, day)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1, event2}, events)
+
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event2}, nil))
+
+	// The margins hide the difference between the clocks of the test and of a database server.
+	require.NoError(t, s.PurgeDeletedEvents(ctx, time.Now().Add(-48*time.Hour)))
+
+	trash, err = s.ListDeletedEvents(ctx)
+	require.NoError(t, err)
+	requireIDs(t, []string{event2}, trash)
+
+	require.NoError(t, s.PurgeDeletedEvents(ctx, time.Now().Add(48*time.Hour)))
+
+	trash, err = s.ListDeletedEvents(ctx)
+	require.NoError(t, err)
+	requireIDs(t, nil, trash)
+
+	_, err = s.GetEvent(ctx, event2)
+	require.ErrorIs(t, err, storage.ErrEventNotExists)
+
+	_, err = s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+}
+
+func testPeriods(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	// Inserted out of order to check the ordering by start date.
+	mustCreate(t, s,
+		newEvent(event8, date(time.March, 1, 0, 0)),
+		newEvent(event3, date(time.February, 3, 23, 59)),
+		newEvent(event1, date(time.February, 2, 23, 59)),
+		newEvent(event5, date(time.February, 10, 0, 0)),
+		newEvent(event2, date(time.February, 3, 0, 0)),
+		newEvent(event4, date(time.February, 9, 23, 59)),
+		newEvent(event6, date(time.February, 28, 23, 59)),
+		newEvent(event7, date(time.January, 31, 23, 59)),
+	)
+
+	for _, listDate := range []time.Time{date(time.February, 3, 0, 0), date(time.February, 3, 15, 45)} {
+		events, err := s.ListEventsForDay(ctx, listDate)
+		require.NoError(t, err)
+		requireIDs(t, []string{event2, event3}, events)
+
+		events, err = s.ListEventsForWeek(ctx, listDate)
+		require.NoError(t, err)
+		requireIDs(t, []string{event2, event3, event4}, events)
+
+		events, err = s.ListEventsForMonth(ctx, listDate)
+		require.NoError(t, err)
+		requireIDs(t, []string{event1, event2, event3, event4, event5, event6}, events)
+	}
+
+	events, err := s.ListEventsForDay(ctx, date(time.February, 4, 0, 0))
+	require.NoError(t, err)
+	requireIDs(t, nil, events)
+
+	// Events starting at the same moment are ordered by ID.
+	mustCreate(t, s, newEvent("00000000-0000-0000-0000-000000000000", date(time.February, 3, 0, 0)))
+
+	events, err = s.ListEventsForDay(ctx, date(time.February, 3, 0, 0))
+	require.NoError(t, err)
+	requireIDs(t, []string{"00000000-0000-0000-0000-000000000000", event2, event3}, events)
+}
+
+func testNotify(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	mustCreateCalendar(t, s, calendar1)
+
+	notified := newEvent(event1, date(time.February, 4, 9, 0))
+	notified.NotifyDays = sql.NullInt32{Int32: 3, Valid: true}
+	notified.Description = sql.NullString{String: "description", Valid: true}
+	notified.UserID = sql.NullString{String: "alice", Valid: true}
+	notified.CalendarID = sql.NullString{String: calendar1, Valid: true}
+
+	tooLate := newEvent(event2, date(time.February, 5, 9, 0))
+	tooLate.NotifyDays = sql.NullInt32{Int32: 3, Valid: true}
+
+	zeroDays := newEvent(event3, date(time.February, 1, 18, 0))
+	zeroDays.NotifyDays = sql.NullInt32{Int32: 0, Valid: true}
+
+	noNotify := newEvent(event4, date(time.February, 1, 18, 0))
+
+	deleted := newEvent(event5, date(time.February, 4, 12, 0))
+	deleted.NotifyDays = sql.NullInt32{Int32: 3, Valid: true}
+
+	sameDay := newEvent(event6, date(time.February, 4, 23, 59))
+	sameDay.NotifyDays = sql.NullInt32{Int32: 3, Valid: true}
+
+	mustCreate(t, s, notified, tooLate, zeroDays, noNotify, deleted, sameDay)
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event5}, nil))
+
+	events, err := s.ListEventsForNotify(ctx, date(time.February, 1, 10, 0))
+	require.NoError(t, err)
+	requireIDs(t, []string{event1, event6}, events)
+	requireEvent(t, notified, events[0])
+}
+
+func testDeleteOldEvents(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	now := date(time.February, 1, 12, 0)
+
+	old := newEvent(event1, now.AddDate(-1, 0, -2))
+	recent := newEvent(event2, now.AddDate(-1, 0, 1))
+
+	mustCreate(t, s, old, recent)
+	require.NoError(t, s.DeleteOldEvents(ctx, now))
+
+	_, err := s.GetEvent(ctx, event1)
+	require.ErrorIs(t, err, storage.ErrEventNotExists)
+
+	_, err = s.GetEvent(ctx, event2)
+	require.NoError(t, err)
+}
+
+func testBatch(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	start := date(time.February, 1, 9, 0)
+
+	mustCreate(t, s, newEvent(event1, start), newEvent(event2, start))
+
+	updated := newEvent(event1, start)
+	updated.Title = "updated"
+
+	errs, err := s.ApplyBatch(ctx, []storage.Operation{
+		{Type: storage.OpUpdate, Event: updated},
+		{Type: storage.OpDelete, Event: storage.Event{ID: event2}},
+		{Type: storage.OpCreate, Event: newEvent(event1, start)},
+	}, true)
+	require.ErrorIs(t, err, storage.ErrBatchAborted)
+	require.Len(t, errs, 3)
+	require.ErrorIs(t, errs[0], storage.ErrBatchAborted)
+	require.ErrorIs(t, errs[1], storage.ErrBatchAborted)
+	require.ErrorIs(t, errs[2], storage.ErrEventAlreadyExists)
+
+	got, err := s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	require.Equal(t, "event 1", got.Title)
+
+	got, err = s.GetEvent(ctx, event2)
+	require.NoError(t, err)
+	require.False(t, got.DeletedAt.Valid)
+
+	errs, err = s.ApplyBatch(ctx, []storage.Operation{
+		{Type: storage.OpUpdate, Event: updated},
+		{Type: storage.OpUpdate, Event: newEvent(missing, start)},
+		{Type: storage.OpDelete, Event: storage.Event{ID: event2}},
+		{Type: storage.OpCreate, Event: newEvent(event3, start)},
+		{Type: "rename", Event: newEvent(event4, start)},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, errs, 5)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], storage.ErrEventNotExists)
+	require.NoError(t, errs[2])
+	require.NoError(t, errs[3])
+	require.ErrorIs(t, errs[4], storage.ErrUnknownOperation)
+
+	got, err = s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	require.Equal(t, "updated", got.Title)
+
+	events, err := s.ListEventsForDay(ctx, start)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1, event3}, events)
+}
+
+func testAudit(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	start := date(time.February, 1, 9, 0)
+
+	audit := func(id, actor, action string) *storage.AuditEntry {
+		return &storage.AuditEntry{EventID: id, Actor: actor, Action: action, After: []byte(`{"title": "first"}`)}
+	}
+
+	require.NoError(t, s.CreateEvent(ctx, newEvent(event1, start), audit(event1, "alice", "create")))
+	require.NoError(t, s.CreateEvent(ctx, newEvent(event2, start), audit(event2, "bob", "create")))
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event1}, &storage.AuditEntry{
+		EventID: event1, Actor: "bob", Action: "delete", Before: []byte(`{"title": "first"}`),
+	}))
+
+	// A failed change leaves no entry.
+	require.ErrorIs(t, s.UpdateEvent(ctx, event1, newEvent(event1, start), audit(event1, "bob", "update")),
+		storage.ErrEventNotExists)
+	require.ErrorIs(t, s.CreateEvent(ctx, newEvent(event2, start), audit(event2, "bob", "create")),
+		storage.ErrEventAlreadyExists)
+
+	entries, err := s.ListAuditEntries(ctx, event1)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, "create", entries[0].Action)
+	require.Equal(t, "alice", entries[0].Actor)
+	require.Equal(t, event1, entries[0].EventID)
+	require.Empty(t, entries[0].Before)
+	require.JSONEq(t, `{"title": "first"}`, string(entries[0].After))
+	require.False(t, entries[0].CreatedAt.IsZero())
+
+	require.Equal(t, "delete", entries[1].Action)
+	require.JSONEq(t, `{"title": "first"}`, string(entries[1].Before))
+	require.Empty(t, entries[1].After)
+	require.Greater(t, entries[1].ID, entries[0].ID)
+
+	require.NoError(t, s.RestoreEvent(ctx, event1, audit(event1, "alice", "restore")))
+
+	// Batches record the entries of the applied operations only.
+	_, err = s.ApplyBatch(ctx, []storage.Operation{
+		{Type: storage.OpUpdate, Event: newEvent(event2, start), Audit: audit(event2, "bob", "update")},
+		{Type: storage.OpCreate, Event: newEvent(event1, start), Audit: audit(event1, "bob", "create")},
+	}, true)
+	require.ErrorIs(t, err, storage.ErrBatchAborted)
+
+	_, err = s.ApplyBatch(ctx, []storage.Operation{
+		{Type: storage.OpCreate, Event: newEvent(event1, start), Audit: audit(event1, "bob", "create")},
+		{Type: storage.OpUpdate, Event: newEvent(event2, start), Audit: audit(event2, "bob", "update")},
+	}, false)
+	require.NoError(t, err)
+
+	entries, err = s.ListAuditEntries(ctx, event1)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "restore", entries[2].Action)
+
+	entries, err = s.ListAuditEntries(ctx, event2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "update", entries[1].Action)
+
+	entries, err = s.ListAuditEntries(ctx, missing)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func testCalendars(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	work := storage.Calendar{
+		ID: calendar1, OwnerID: "alice", Name: "work", TimeZone: "Europe/Moscow",
+		Color: sql.NullString{String: "#FF0000", Valid: true},
+	}
+
+	require.NoError(t, s.CreateCalendar(ctx, work))
+	require.ErrorIs(t, s.CreateCalendar(ctx, work), storage.ErrCalendarExists)
+
+	got, err := s.GetCalendar(ctx, calendar1)
+	require.NoError(t, err)
+	require.Equal(t, work, *got)
+
+	_, err = s.GetCalendar(ctx, missing)
+	require.ErrorIs(t, err, storage.ErrCalendarNotExists)
+
+	// The owner never changes.
+	renamed := work
+	renamed.Name = "job"
+	renamed.OwnerID = "mallory"
+	renamed.Color = sql.NullString{}
+	require.NoError(t, s.UpdateCalendar(ctx, renamed))
+
+	got, err = s.GetCalendar(ctx, calendar1)
+	require.NoError(t, err)
+	require.Equal(t, "job", got.Name)
+	require.Equal(t, "alice", got.OwnerID)
+	require.False(t, got.Color.Valid)
+
+	require.ErrorIs(t, s.UpdateCalendar(ctx, storage.Calendar{ID: missing, Name: "x"}), storage.ErrCalendarNotExists)
+
+	require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{
+		ID: calendar2, OwnerID: "bob", Name: "family", TimeZone: "UTC",
+	}))
+	require.NoError(t, s.CreateCalendar(ctx, storage.Calendar{
+		ID: calendar3, OwnerID: "alice", Name: "archive", TimeZone: "UTC",
+	}))
+	require.NoError(t, s.SetShare(ctx, storage.Share{CalendarID: calendar2, UserID: "alice", Permission: "read"}))
+
+	calendars, err := s.ListCalendars(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, calendars, 3)
+	require.Equal(t, "archive", calendars[0].Name)
+	require.Equal(t, "family", calendars[1].Name)
+	require.Equal(t, "job", calendars[2].Name)
+
+	calendars, err = s.ListCalendars(ctx, "carol")
+	require.NoError(t, err)
+	require.Empty(t, calendars)
+
+	require.NoError(t, s.DeleteCalendar(ctx, calendar3))
+	require.ErrorIs(t, s.DeleteCalendar(ctx, calendar3), storage.ErrCalendarNotExists)
+
+	_, err = s.GetCalendar(ctx, calendar3)
+	require.ErrorIs(t, err, storage.ErrCalendarNotExists)
+}
+
+func testShares(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	mustCreateCalendar(t, s, calendar1)
+
+	require.NoError(t, s.SetShare(ctx, storage.Share{CalendarID: calendar1, UserID: "carol", Permission: "read"}))
+	require.NoError(t, s.SetShare(ctx, storage.Share{CalendarID: calendar1, UserID: "bob", Permission: "read"}))
+	require.NoError(t, s.SetShare(ctx, storage.Share{CalendarID: calendar1, UserID: "carol", Permission: "write"}))
+
+	err := s.SetShare(ctx, storage.Share{CalendarID: missing, UserID: "bob", Permission: "read"})
+	require.ErrorIs(t, err, storage.ErrCalendarNotExists)
+
+	shares, err := s.ListShares(ctx, calendar1)
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Share{
+		{CalendarID: calendar1, UserID: "bob", Permission: "read"},
+		{CalendarID: calendar1, UserID: "carol", Permission: "write"},
+	}, shares)
+
+	require.NoError(t, s.DeleteShare(ctx, calendar1, "bob"))
+	require.ErrorIs(t, s.DeleteShare(ctx, calendar1, "bob"), storage.ErrShareNotExists)
+	require.ErrorIs(t, s.DeleteShare(ctx, missing, "bob"), storage.ErrShareNotExists)
+
+	shares, err = s.ListShares(ctx, missing)
+	require.NoError(t, err)
+	require.Empty(t, shares)
+
+	// Shares go away together with the calendar.
+	require.NoError(t, s.DeleteCalendar(ctx, calendar1))
+
+	shares, err = s.ListShares(ctx, calendar1)
+	require.NoError(t, err)
+	require.Empty(t, shares)
+}
+
+func testCalendarEvents(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	mustCreateCalendar(t, s, calendar1)
+
+	late := newEventIn(event1, calendar1)
+	late.StartDate = date(time.February, 2, 9, 0)
+
+	mustCreate(t, s, late, newEventIn(event2, calendar1), newEventIn(event3, calendar1))
+	mustCreate(t, s, newEvent(event4, late.StartDate))
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event3}, nil))
+
+	events, err := s.ListCalendarEvents(ctx, calendar1)
+	require.NoError(t, err)
+	requireIDs(t, []string{event2, event1}, events)
+
+	events, err = s.ListCalendarEvents(ctx, missing)
+	require.NoError(t, err)
+	requireIDs(t, nil, events)
+
+	require.ErrorIs(t, s.DeleteCalendar(ctx, calendar1), storage.ErrCalendarNotEmpty)
+
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event1}, nil))
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event2}, nil))
+	require.NoError(t, s.DeleteCalendar(ctx, calendar1))
+
+	// Events in the trash lose the reference to the deleted calendar.
+	got, err := s.GetEvent(ctx, event3)
+	require.NoError(t, err)
+	require.False(t, got.CalendarID.Valid)
+	require.True(t, got.DeletedAt.Valid)
+}
+
+func testTags(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	mustCreateCalendar(t, s, calendar1)
+
+	event := newEventIn(event1, calendar1)
+	event.Category = sql.NullString{String: "meeting", Valid: true}
+	event.Color = sql.NullString{String: "#ff0000", Valid: true}
+	event.Tags = storage.Tags{"urgent", "work"}
+
+	mustCreate(t, s, event)
+
+	got, err := s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	requireEvent(t, event, got)
+
+	event.Tags = storage.Tags{"work", "workout"}
+	require.NoError(t, s.UpdateEvent(ctx, event1, event, nil))
+
+	events, err := s.ListEventsForDay(ctx, event.StartDate)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	requireEvent(t, event, events[0])
+
+	other := newEvent(event2, event.StartDate)
+	other.Tags = storage.Tags{"work", "w_x"}
+
+	results, err := s.ApplyBatch(ctx, []storage.Operation{{Type: storage.OpCreate, Event: other}}, true)
+	require.NoError(t, err)
+	require.Equal(t, []error{nil}, results)
+
+	trashed := newEvent(event3, event.StartDate)
+	trashed.Tags = storage.Tags{"wood"}
+	mustCreate(t, s, trashed)
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event3}, nil))
+
+	usages, err := s.ListTags(ctx, "wo")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.TagUsage{
+		{Tag: "work", Count: 1},
+		{Tag: "work", CalendarID: sql.NullString{String: calendar1, Valid: true}, Count: 1},
+		{Tag: "workout", CalendarID: sql.NullString{String: calendar1, Valid: true}, Count: 1},
+	}, usages)
+
+	// LIKE wildcards in the prefix are matched literally.
+	usages, err = s.ListTags(ctx, "w_")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.TagUsage{{Tag: "w_x", Count: 1}}, usages)
+
+	event.Tags = nil
+	require.NoError(t, s.UpdateEvent(ctx, event1, event, nil))
+
+	got, err = s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	require.Nil(t, got.Tags)
+
+	usages, err = s.ListTags(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, usages, 2)
+}
+
+func newEventAt(id string, start time.Time, latitude, longitude float64) storage.Event {
+	event := newEvent(id, start)
+	event.Latitude = sql.NullFloat64{Float64: latitude, Valid: true}
+	event.Longitude = sql.NullFloat64{Float64: longitude, Valid: true}
+
+	return event
+}
+
+func testNear(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	start := date(time.March, 3, 10, 0)
+	from, to := storage.DayRange(start)
+
+	office := newEventAt(event1, start, 55.7558, 37.6173)
+	office.Address = sql.NullString{String: "Red Square, Moscow", Valid: true}
+	office.MeetingURL = sql.NullString{String: "https://meet.example.com/abc", Valid: true}
+
+	// About 1.1 km north of the office.
+	nearby := newEventAt(event2, start.Add(time.Hour), 55.7658, 37.6173)
+	faraway := newEventAt(event3, start, 59.9343, 30.3351)
+	online := newEvent(event4, start)
+	tomorrow := newEventAt(event5, start.AddDate(0, 0, 1), 55.7558, 37.6173)
+
+	mustCreate(t, s, office, nearby, faraway, online, tomorrow)
+
+	got, err := s.GetEvent(ctx, event1)
+	require.NoError(t, err)
+	requireEvent(t, office, got)
+
+	center := storage.Point{Latitude: 55.7558, Longitude: 37.6173}
+
+	events, err := s.ListEventsNear(ctx, center, 500, from, to)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1}, events)
+
+	events, err = s.ListEventsNear(ctx, center, 2000, from, to)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1, event2}, events)
+
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event2}, nil))
+
+	events, err = s.ListEventsNear(ctx, center, 2000, from, to)
+	require.NoError(t, err)
+	requireIDs(t, []string{event1}, events)
+
+	// Events across the antimeridian are near each other.
+	east := newEventAt(event6, start, 0, 179.999)
+	west := newEventAt(event7, start, 0, -179.999)
+	mustCreate(t, s, east, west)
+
+	events, err = s.ListEventsNear(ctx, storage.Point{Latitude: 0, Longitude: 180}, 1000, from, to)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.ElementsMatch(t, []string{event6, event7}, []string{events[0].ID, events[1].ID})
+}
+
+func newAttachment(id, eventID string, created time.Time) storage.Attachment {
+	return storage.Attachment{
+		ID:          id,
+		EventID:     eventID,
+		Name:        "agenda.pdf",
+		ContentType: "application/pdf",
+		Size:        1024,
+		CreatedAt:   created,
+	}
+}
+
+func requireAttachmentIDs(t *testing.T, expected []string, attachments []*storage.Attachment) {
+	t.Helper()
+
+	ids := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		ids = append(ids, attachment.ID)
+	}
+
+	if len(expected) == 0 {
+		require.Empty(t, ids)
+		return
+	}
+
+	require.Equal(t, expected, ids)
+}
+
+func testAttachments(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	created := date(time.March, 1, 9, 0)
+
+	mustCreate(t, s, newEvent(event1, created), newEvent(event2, created))
+
+	first := newAttachment(attachment1, event1, created.Add(time.Minute))
+	require.NoError(t, s.CreateAttachment(ctx, first))
+	require.NoError(t, s.CreateAttachment(ctx, newAttachment(attachment2, event1, created)))
+	require.NoError(t, s.CreateAttachment(ctx, newAttachment(attachment3, event2, created)))
+
+	err := s.CreateAttachment(ctx, newAttachment(missing, missing, created))
+	require.ErrorIs(t, err, storage.ErrEventNotExists)
+
+	got, err := s.GetAttachment(ctx, attachment1)
+	require.NoError(t, err)
+
+	got.CreatedAt = got.CreatedAt.UTC()
+	require.Equal(t, first, *got)
+
+	_, err = s.GetAttachment(ctx, missing)
+	require.ErrorIs(t, err, storage.ErrAttachmentNotExists)
+
+	attachments, err := s.ListAttachments(ctx, event1)
+	require.NoError(t, err)
+	requireAttachmentIDs(t, []string{attachment2, attachment1}, attachments)
+
+	require.NoError(t, s.DeleteAttachment(ctx, attachment2))
+	require.ErrorIs(t, s.DeleteAttachment(ctx, attachment2), storage.ErrAttachmentNotExists)
+
+	// Events in the trash cannot get attachments but keep theirs.
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event2}, nil))
+	require.ErrorIs(t, s.CreateAttachment(ctx, newAttachment(missing, event2, created)), storage.ErrEventNotExists)
+
+	orphaned, err := s.ListOrphanedAttachments(ctx)
+	require.NoError(t, err)
+	requireAttachmentIDs(t, nil, orphaned)
+
+	// Purged events leave their attachments behind for the blob cleanup.
+	require.NoError(t, s.PurgeDeletedEvents(ctx, time.Now().Add(48*time.Hour)))
+
+	orphaned, err = s.ListOrphanedAttachments(ctx)
+	require.NoError(t, err)
+	requireAttachmentIDs(t, []string{attachment3}, orphaned)
+
+	require.NoError(t, s.DeleteAttachment(ctx, attachment3))
+
+	orphaned, err = s.ListOrphanedAttachments(ctx)
+	require.NoError(t, err)
+	requireAttachmentIDs(t, nil, orphaned)
+}
+
+func testPreferences(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetPreferences(ctx, "alice")
+	require.ErrorIs(t, err, storage.ErrPreferencesNotExists)
+
+	preferences := storage.Preferences{
+		UserID:     "alice",
+		Channel:    "email",
+		Address:    "alice@example.com",
+		QuietStart: "22:00",
+		QuietEnd:   "07:30",
+		TimeZone:   "Europe/Moscow",
+		Delivery:   "immediate",
+		DigestTime: "08:00",
+	}
+	require.NoError(t, s.SetPreferences(ctx, preferences))
+
+	got, err := s.GetPreferences(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, preferences, *got)
+
+	// Setting again replaces all the preferences.
+	preferences = storage.Preferences{
+		UserID: "alice", Channel: "sms", Address: "+15550100", TimeZone: "UTC", Delivery: "digest", DigestTime: "07:15",
+	}
+	require.NoError(t, s.SetPreferences(ctx, preferences))
+
+	got, err = s.GetPreferences(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, preferences, *got)
+
+	bob := storage.Preferences{UserID: "bob", Channel: "push", TimeZone: "UTC", Delivery: "digest", DigestTime: "09:00"}
+	require.NoError(t, s.SetPreferences(ctx, bob))
+	require.NoError(t, s.SetPreferences(ctx, storage.Preferences{UserID: "carol", Delivery: "immediate"}))
+
+	digests, err := s.ListPreferences(ctx, "digest")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Preferences{&preferences, &bob}, digests)
+
+	require.NoError(t, s.DeletePreferences(ctx, "alice"))
+	require.ErrorIs(t, s.DeletePreferences(ctx, "alice"), storage.ErrPreferencesNotExists)
+
+	_, err = s.GetPreferences(ctx, "alice")
+	require.ErrorIs(t, err, storage.ErrPreferencesNotExists)
+}
+
+func testOptOuts(t *testing.T, s app.Storage) {
+	ctx := context.Background()
+	start := date(time.March, 1, 9, 0)
+
+	mustCreate(t, s, newEvent(event1, start), newEvent(event2, start))
+
+	require.NoError(t, s.SetOptOut(ctx, storage.OptOut{EventID: event2, UserID: "alice"}))
+	require.NoError(t, s.SetOptOut(ctx, storage.OptOut{EventID: event1, UserID: "alice"}))
+	require.NoError(t, s.SetOptOut(ctx, storage.OptOut{EventID: event1, UserID: "alice"}))
+	require.NoError(t, s.SetOptOut(ctx, storage.OptOut{EventID: event1, UserID: "bob"}))
+	require.ErrorIs(t, s.SetOptOut(ctx, storage.OptOut{EventID: missing, UserID: "alice"}), storage.ErrEventNotExists)
+
+	optOuts, err := s.ListOptOuts(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.OptOut{
+		{EventID: event1, UserID: "alice"},
+		{EventID: event2, UserID: "alice"},
+	}, optOuts)
+
+	require.NoError(t, s.DeleteOptOut(ctx, event1, "alice"))
+	require.ErrorIs(t, s.DeleteOptOut(ctx, event1, "alice"), storage.ErrOptOutNotExists)
+
+	// Opt-outs are kept in the trash and removed with the purged event.
+	require.NoError(t, s.DeleteEvent(ctx, storage.Event{ID: event2}, nil))
+
+	optOuts, err = s.ListOptOuts(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.OptOut{{EventID: event2, UserID: "alice"}}, optOuts)
+
+	require.NoError(t, s.PurgeDeletedEvents(ctx, time.Now().Add(48*time.Hour)))
+
+	optOuts, err = s.ListOptOuts(ctx, "alice")
+	require.NoError(t, err)
+	require.Empty(t, optOuts)
+
+	optOuts, err = s.ListOptOuts(ctx, "bob")
+	require.NoError(t, err)
+	require.Equal(t, []*storage.OptOut{{EventID: event1, UserID: "bob"}}, optOuts)
+}
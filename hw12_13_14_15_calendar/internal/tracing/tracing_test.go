@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/evg555/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		shutdown, err := Init(ctx, config.TracingConf{Exporter: ExporterNone}, "test")
+		require.NoError(t, err)
+		require.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		shutdown, err := Init(ctx, config.TracingConf{Exporter: ExporterStdout}, "test")
+		require.NoError(t, err)
+		require.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("invalid exporter", func(t *testing.T) {
+		_, err := Init(ctx, config.TracingConf{Exporter: "invalid"}, "test")
+		require.ErrorIs(t, err, ErrExporterNotExist)
+	})
+}
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS events_deleted_at_idx ON events (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS events_deleted_at_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS deleted_at;
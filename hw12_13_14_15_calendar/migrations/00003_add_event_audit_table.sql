@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS event_audit (
+    id BIGSERIAL PRIMARY KEY,
+    event_uuid UUID NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    snapshot_before JSONB,
+    snapshot_after JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS event_audit_event_uuid_idx ON event_audit (event_uuid, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_audit;
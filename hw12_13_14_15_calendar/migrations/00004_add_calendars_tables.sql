@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS calendars (
+    uuid UUID PRIMARY KEY,
+    owner_id VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(7),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC'
+);
+
+CREATE INDEX IF NOT EXISTS calendars_owner_id_idx ON calendars (owner_id);
+
+CREATE TABLE IF NOT EXISTS calendar_shares (
+    calendar_uuid UUID NOT NULL REFERENCES calendars (uuid) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    permission VARCHAR(16) NOT NULL,
+    PRIMARY KEY (calendar_uuid, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_shares_user_id_idx ON calendar_shares (user_id);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS calendar_uuid UUID REFERENCES calendars (uuid) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS events_calendar_uuid_idx ON events (calendar_uuid);
+
+-- +goose Down
+DROP INDEX IF EXISTS events_calendar_uuid_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS calendar_uuid;
+DROP TABLE IF EXISTS calendar_shares;
+DROP TABLE IF EXISTS calendars;
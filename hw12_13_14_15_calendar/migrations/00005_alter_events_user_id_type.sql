@@ -0,0 +1,6 @@
+-- +goose Up
+-- User IDs are free-form strings like calendar owners, not UUIDs.
+ALTER TABLE events ALTER COLUMN user_id TYPE VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE events ALTER COLUMN user_id TYPE UUID USING user_id::UUID;
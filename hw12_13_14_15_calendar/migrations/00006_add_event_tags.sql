@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS color VARCHAR(7);
+CREATE INDEX IF NOT EXISTS events_category_idx ON events (category) WHERE category IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS event_tags (
+    event_uuid UUID NOT NULL REFERENCES events (uuid) ON DELETE CASCADE,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (event_uuid, tag)
+);
+
+-- text_pattern_ops serves the prefix search of tag autocomplete.
+CREATE INDEX IF NOT EXISTS event_tags_tag_idx ON event_tags (tag text_pattern_ops);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_tags;
+DROP INDEX IF EXISTS events_category_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS color;
+ALTER TABLE events DROP COLUMN IF EXISTS category;
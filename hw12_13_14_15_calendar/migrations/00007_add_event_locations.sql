@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN IF NOT EXISTS address VARCHAR(255);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS meeting_url VARCHAR(2048);
+
+-- Serves the bounding box of the queries for events near a point.
+CREATE INDEX IF NOT EXISTS events_latitude_longitude_idx ON events (latitude, longitude) WHERE latitude IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS events_latitude_longitude_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS meeting_url;
+ALTER TABLE events DROP COLUMN IF EXISTS longitude;
+ALTER TABLE events DROP COLUMN IF EXISTS latitude;
+ALTER TABLE events DROP COLUMN IF EXISTS address;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(255) PRIMARY KEY,
+    channel VARCHAR(16) NOT NULL DEFAULT '',
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    quiet_start VARCHAR(5) NOT NULL DEFAULT '',
+    quiet_end VARCHAR(5) NOT NULL DEFAULT '',
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    delivery VARCHAR(16) NOT NULL DEFAULT 'immediate'
+);
+
+CREATE TABLE IF NOT EXISTS event_opt_outs (
+    event_uuid UUID NOT NULL REFERENCES events (uuid) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (event_uuid, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS event_opt_outs_user_id_idx ON event_opt_outs (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_opt_outs;
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS digest_time VARCHAR(5) NOT NULL DEFAULT '08:00';
+
+-- +goose Down
+ALTER TABLE notification_preferences DROP COLUMN IF EXISTS digest_time;
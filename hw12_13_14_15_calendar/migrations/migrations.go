@@ -0,0 +1,8 @@
+// Package migrations holds the goose migrations of the PostgreSQL schema. They are embedded
+// into the binaries, so `calendar migrate` needs no files next to it.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS